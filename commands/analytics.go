@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/ququzone/hermes-patch/hermes/analytics"
+)
+
+type DevAnalytics struct {
+	fixtures string
+	listen   string
+	token    string
+}
+
+func NewDevAnalytics() *DevAnalytics {
+	return &DevAnalytics{}
+}
+
+func (c *DevAnalytics) Command() *cli.Command {
+	return &cli.Command{
+		Name:  "dev-analytics",
+		Usage: "serve the Hermes bookkeeping query from fixture files for local development",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "fixtures",
+				Aliases:     []string{"f"},
+				Usage:       "fixtures JSON file",
+				Required:    true,
+				Destination: &c.fixtures,
+			},
+			&cli.StringFlag{
+				Name:        "listen",
+				Aliases:     []string{"l"},
+				Usage:       "listen address",
+				Value:       "127.0.0.1:8089",
+				Destination: &c.listen,
+			},
+			&cli.StringFlag{
+				Name:        "token",
+				Usage:       "bearer token required from clients, empty to accept any",
+				Destination: &c.token,
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			fixtures, err := analytics.LoadFixtures(c.fixtures)
+			if err != nil {
+				return err
+			}
+			log.Printf("serving %d epochs of bookkeeping on http://%s\n", len(fixtures.Epochs), c.listen)
+			if err := http.ListenAndServe(c.listen, analytics.NewServer(fixtures, c.token)); err != nil {
+				return fmt.Errorf("serve analytics error: %v", err)
+			}
+			return nil
+		},
+	}
+}
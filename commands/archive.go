@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+	"github.com/ququzone/hermes-patch/hermes/cmd/retention"
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+type Archive struct {
+	beforeEpoch uint64
+	dryRun      bool
+	fromEpoch   uint64
+	toEpoch     uint64
+}
+
+func NewArchive() *Archive {
+	return &Archive{}
+}
+
+func (c *Archive) Command() *cli.Command {
+	return &cli.Command{
+		Name:  "archive",
+		Usage: "archive old completed and merged records, or restore them",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "run",
+				Usage: "move the records older than retention.keepEpochs to the archive tables",
+				Flags: []cli.Flag{
+					&cli.Uint64Flag{
+						Name:        "before-epoch",
+						Usage:       "archive the records of end epochs before this one instead",
+						Destination: &c.beforeEpoch,
+					},
+					&cli.BoolFlag{
+						Name:        "dry-run",
+						Usage:       "count the records without moving them",
+						Destination: &c.dryRun,
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					if err := loadConfig(ctx); err != nil {
+						return err
+					}
+					err := dao.ConnectDatabase()
+					if err != nil {
+						log.Fatalf("create database error: %v\n", err)
+					}
+					cfg := config.Get().Retention
+					before := c.beforeEpoch
+					if before == 0 {
+						if before, err = retention.Before(cfg); err != nil {
+							log.Fatalf("archive error: %v\n", err)
+						}
+					}
+					if before == 0 {
+						fmt.Println("no record is old enough to archive")
+						return nil
+					}
+					results, err := retention.Archive(cfg, before, c.dryRun)
+					verb := "archived"
+					if c.dryRun {
+						verb = "would archive"
+					}
+					for _, r := range results {
+						fmt.Printf("%s %d %s before end epoch %d\n", verb, r.Rows, r.Table, before)
+					}
+					if err != nil {
+						log.Fatalf("archive error: %v\n", err)
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "restore",
+				Usage: "move archived records back, from export files or from the archive tables",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:  "file",
+						Usage: "export file to restore, repeat it for several files",
+					},
+					&cli.Uint64Flag{
+						Name:        "from-epoch",
+						Usage:       "first end epoch restored from the archive tables",
+						Destination: &c.fromEpoch,
+					},
+					&cli.Uint64Flag{
+						Name:        "to-epoch",
+						Usage:       "last end epoch restored from the archive tables",
+						Destination: &c.toEpoch,
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					files := ctx.StringSlice("file")
+					if len(files) == 0 && (c.toEpoch == 0 || c.fromEpoch > c.toEpoch) {
+						return errors.New("give --file, or --from-epoch and --to-epoch")
+					}
+					if err := loadConfig(ctx); err != nil {
+						return err
+					}
+					err := dao.ConnectDatabase()
+					if err != nil {
+						log.Fatalf("create database error: %v\n", err)
+					}
+					for _, f := range files {
+						r, err := retention.RestoreFile(f)
+						if err != nil {
+							log.Fatalf("restore error: %v\n", err)
+						}
+						fmt.Printf("restored %d %s from %s\n", r.Rows, r.Table, f)
+					}
+					if c.toEpoch == 0 {
+						return nil
+					}
+					results, err := retention.RestoreEpochs(c.fromEpoch, c.toEpoch)
+					for _, r := range results {
+						fmt.Printf("restored %d %s of end epochs %d to %d\n", r.Rows, r.Table, c.fromEpoch, c.toEpoch)
+					}
+					if err != nil {
+						log.Fatalf("restore error: %v\n", err)
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
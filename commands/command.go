@@ -13,5 +13,15 @@ func Commonds() []*cli.Command {
 		NewReward().Command(),
 		NewSender().Command(),
 		NewMerge().Command(),
+		NewConfig().Command(),
+		NewDevAnalytics().Command(),
+		NewReconcile().Command(),
+		NewMigrateSignatures().Command(),
+		NewKeys().Command(),
+		NewSigner().Command(),
+		NewDB().Command(),
+		NewArchive().Command(),
+		NewExport().Command(),
+		NewFunding().Command(),
 	}
 }
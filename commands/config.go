@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+type Config struct{}
+
+func NewConfig() *Config {
+	return &Config{}
+}
+
+func (c *Config) Command() *cli.Command {
+	return &cli.Command{
+		Name:  "config",
+		Usage: "inspect hermes-patch configuration",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "check",
+				Usage: "report every problem of the configuration",
+				Action: func(ctx *cli.Context) error {
+					cfg, err := config.Load(ctx.String("config"))
+					if err != nil {
+						return err
+					}
+					if err := cfg.Validate(); err != nil {
+						problems, ok := err.(config.Problems)
+						if !ok {
+							return err
+						}
+						for _, p := range problems {
+							fmt.Println(p.Error())
+						}
+						return fmt.Errorf("found %d config problems", len(problems))
+					}
+					fmt.Println("config is valid")
+					return nil
+				},
+			},
+		},
+	}
+}
+
+func loadConfig(ctx *cli.Context) error {
+	return config.Init(ctx.String("config"))
+}
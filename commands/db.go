@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+type DB struct {
+	to    uint
+	steps int
+}
+
+func NewDB() *DB {
+	return &DB{}
+}
+
+func (c *DB) Command() *cli.Command {
+	return &cli.Command{
+		Name:  "db",
+		Usage: "manage the record database",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "migrate",
+				Usage: "apply or roll back versioned schema migrations",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "up",
+						Usage: "apply the pending migrations",
+						Flags: []cli.Flag{
+							&cli.UintFlag{
+								Name:        "to",
+								Usage:       "last version to apply, every pending migration by default",
+								Destination: &c.to,
+							},
+						},
+						Action: func(ctx *cli.Context) error {
+							if err := openDatabase(ctx); err != nil {
+								return err
+							}
+							done, err := dao.MigrateUp(c.to)
+							for _, m := range done {
+								fmt.Printf("applied %d %s\n", m.Version, m.Name)
+							}
+							if err != nil {
+								log.Fatalf("migrate up error: %v\n", err)
+							}
+							if len(done) == 0 {
+								fmt.Println("no pending migration")
+							}
+							return nil
+						},
+					},
+					{
+						Name:  "down",
+						Usage: "roll back the last applied migrations",
+						Flags: []cli.Flag{
+							&cli.IntFlag{
+								Name:        "steps",
+								Usage:       "number of migrations to roll back",
+								Value:       1,
+								Destination: &c.steps,
+							},
+						},
+						Action: func(ctx *cli.Context) error {
+							if err := openDatabase(ctx); err != nil {
+								return err
+							}
+							done, err := dao.MigrateDown(c.steps)
+							for _, m := range done {
+								fmt.Printf("rolled back %d %s\n", m.Version, m.Name)
+							}
+							if err != nil {
+								log.Fatalf("migrate down error: %v\n", err)
+							}
+							return nil
+						},
+					},
+					{
+						Name:  "status",
+						Usage: "list the migrations and when they were applied",
+						Action: func(ctx *cli.Context) error {
+							if err := openDatabase(ctx); err != nil {
+								return err
+							}
+							states, err := dao.MigrationStatus()
+							if err != nil {
+								log.Fatalf("migration status error: %v\n", err)
+							}
+							for _, state := range states {
+								applied := "pending"
+								if state.AppliedAt != nil {
+									applied = state.AppliedAt.Format("2006-01-02 15:04:05")
+								}
+								fmt.Printf("%d\t%s\t%s\n", state.Version, applied, state.Name)
+							}
+							return nil
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// openDatabase opens the configured database without checking its schema
+func openDatabase(ctx *cli.Context) error {
+	if err := loadConfig(ctx); err != nil {
+		return err
+	}
+	cfg := config.Get().Database
+	if err := dao.Open(cfg.Dialect, cfg.Conn); err != nil {
+		log.Fatalf("create database error: %v\n", err)
+	}
+	return nil
+}
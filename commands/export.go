@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+	"github.com/ququzone/hermes-patch/hermes/cmd/distribute"
+)
+
+type Export struct {
+	epoch    uint64
+	delegate string
+	format   string
+	output   string
+}
+
+func NewExport() *Export {
+	return &Export{}
+}
+
+func (c *Export) Command() *cli.Command {
+	return &cli.Command{
+		Name:  "export",
+		Usage: "export what every voter was paid in an end epoch, one row per voter per delegate",
+		Flags: []cli.Flag{
+			&cli.Uint64Flag{
+				Name:        "epoch",
+				Usage:       "end epoch to export",
+				Required:    true,
+				Destination: &c.epoch,
+			},
+			&cli.StringFlag{
+				Name:        "delegate",
+				Usage:       "only export the voters of this delegate",
+				Destination: &c.delegate,
+			},
+			&cli.StringFlag{
+				Name:        "format",
+				Usage:       "csv or json",
+				Value:       "csv",
+				Destination: &c.format,
+			},
+			&cli.StringFlag{
+				Name:        "output",
+				Usage:       "file to write, standard output by default",
+				Destination: &c.output,
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			var write func(io.Writer, []*distribute.Payout) error
+			switch c.format {
+			case "csv":
+				write = distribute.WritePayoutsCSV
+			case "json":
+				write = distribute.WritePayoutsJSON
+			default:
+				return fmt.Errorf("unknown format %s, use csv or json", c.format)
+			}
+			if err := loadConfig(ctx); err != nil {
+				return err
+			}
+			err := dao.ConnectDatabase()
+			if err != nil {
+				log.Fatalf("create database error: %v\n", err)
+			}
+
+			payouts, err := distribute.Payouts(c.epoch, c.delegate)
+			if err != nil {
+				log.Fatalf("export error: %v\n", err)
+			}
+			w := os.Stdout
+			if c.output != "" {
+				if w, err = os.Create(c.output); err != nil {
+					log.Fatalf("create output file error: %v\n", err)
+				}
+			}
+			if err := write(w, payouts); err != nil {
+				log.Fatalf("write payouts error: %v\n", err)
+			}
+			if err := w.Close(); err != nil {
+				log.Fatalf("close output file error: %v\n", err)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+	"github.com/ququzone/hermes-patch/hermes/cmd/distribute"
+)
+
+type Funding struct {
+	hash   string
+	failed bool
+}
+
+func NewFunding() *Funding {
+	return &Funding{}
+}
+
+func (c *Funding) Command() *cli.Command {
+	return &cli.Command{
+		Name:  "funding",
+		Usage: "manage the transfers funding the sender account",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "resolve",
+				Usage:     "settle a funding transfer whose nonce was used by another action",
+				ArgsUsage: "ID",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "hash",
+						Usage:       "hash of the action that sent the transfer",
+						Destination: &c.hash,
+					},
+					&cli.BoolFlag{
+						Name:        "failed",
+						Usage:       "the transfer was not sent, send it again with a new nonce",
+						Destination: &c.failed,
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					id, err := strconv.ParseUint(ctx.Args().First(), 10, 64)
+					if err != nil {
+						return fmt.Errorf("parse ID error: %v", err)
+					}
+					if (c.hash == "") == !c.failed {
+						return errors.New("give --hash or --failed")
+					}
+					if err := loadConfig(ctx); err != nil {
+						return err
+					}
+					if err := dao.ConnectDatabase(); err != nil {
+						log.Fatalf("create database error: %v\n", err)
+					}
+					if err := distribute.ResolveFunding(uint(id), c.hash); err != nil {
+						log.Fatalf("resolve funding transfer error: %v\n", err)
+					}
+					fmt.Printf("funding transfer %d resolved\n", id)
+					return nil
+				},
+			},
+		},
+	}
+}
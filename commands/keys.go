@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+type Keys struct {
+	dryRun bool
+}
+
+func NewKeys() *Keys {
+	return &Keys{}
+}
+
+func (c *Keys) Command() *cli.Command {
+	return &cli.Command{
+		Name:  "keys",
+		Usage: "manage the RSA keys signing database records",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "list",
+				Usage: "list the IDs of the configured keys",
+				Action: func(ctx *cli.Context) error {
+					if err := loadConfig(ctx); err != nil {
+						return err
+					}
+					ring, err := dao.LoadKeyring(config.Get().Database)
+					if err != nil {
+						return err
+					}
+					for _, id := range ring.IDs() {
+						if id == ring.CurrentID() {
+							fmt.Printf("%s (signing)\n", id)
+							continue
+						}
+						fmt.Println(id)
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "rotate",
+				Usage: "re-sign new and pending records and unfinished chunks with the current key after verifying them under their key",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:        "dry-run",
+						Usage:       "verify the records and report without writing",
+						Destination: &c.dryRun,
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					if err := loadConfig(ctx); err != nil {
+						return err
+					}
+					err := dao.ConnectDatabase()
+					if err != nil {
+						log.Fatalf("create database error: %v\n", err)
+					}
+					fmt.Printf("signing key: %s\n", dao.Keyring().CurrentID())
+					return resign(c.dryRun, dao.RotateDropRecordKeys, dao.RotateSmallRecordKeys, dao.RotateChunkKeys)
+				},
+			},
+		},
+	}
+}
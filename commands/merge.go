@@ -6,17 +6,17 @@ import (
 	"math/big"
 	"os"
 
-	"github.com/iotexproject/iotex-address/address"
 	"github.com/urfave/cli/v2"
 
 	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
 	"github.com/ququzone/hermes-patch/hermes/cmd/distribute"
-	"github.com/ququzone/hermes-patch/hermes/util"
+	"github.com/ququzone/hermes-patch/hermes/config"
 )
 
 type Merge struct {
-	password string
-	previous *big.Int
+	password  string
+	previous  *big.Int
+	reference string
 }
 
 func NewMerge() *Merge {
@@ -29,10 +29,9 @@ func (c *Merge) Command() *cli.Command {
 		Aliases: []string{"m"},
 		Flags: []cli.Flag{
 			&cli.StringFlag{
-				Name:     "password",
-				Aliases:  []string{"p"},
-				Usage:    "password file path",
-				Required: true,
+				Name:    "password",
+				Aliases: []string{"p"},
+				Usage:   "keystore password file path, not needed with a remote signer",
 				Action: func(ctx *cli.Context, s string) error {
 					data, err := os.ReadFile(s)
 					if err != nil {
@@ -59,29 +58,37 @@ func (c *Merge) Command() *cli.Command {
 					return nil
 				},
 			},
+			&cli.StringFlag{
+				Name:        "reference",
+				Aliases:     []string{"r"},
+				Usage:       "identifier of the previous balance, such as its end epoch, needed when it is not zero",
+				Destination: &c.reference,
+			},
 		},
 		Action: func(ctx *cli.Context) error {
+			if err := loadConfig(ctx); err != nil {
+				return err
+			}
+			cfg := config.Get()
+			serveMetrics()
+
 			err := dao.ConnectDatabase()
 			if err != nil {
 				log.Fatalf("create database error: %v\n", err)
 			}
 
-			notifier, err := distribute.NewNotifier(util.MustFetchNonEmptyParam("LARK_ENDPOINT"), util.MustFetchNonEmptyParam("LARK_KEY"))
+			notifier, err := newAlerter(ctx.Context)
 			if err != nil {
 				log.Fatalf("new notifier error: %v\n", err)
 			}
+			defer notifier.Flush()
 
-			acc, err := util.ReadAccount(c.password)
+			acc, err := loadAccount(ctx.Context, c.password)
 			if err != nil {
 				log.Fatalf("read account error: %v\n", err)
 			}
 
-			sender, err := address.FromString(util.MustFetchNonEmptyParam("SENDER_ADDR"))
-			if err != nil {
-				log.Fatalf("get sender address error: %v\n", err)
-			}
-
-			return distribute.Merge(notifier, acc, sender, c.previous)
+			return distribute.Merge(ctx.Context, notifier, acc, cfg.Distribution.SenderAddress.Address(), c.previous, c.reference)
 		},
 	}
 }
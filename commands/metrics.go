@@ -0,0 +1,13 @@
+package commands
+
+import (
+	"github.com/ququzone/hermes-patch/hermes/config"
+	"github.com/ququzone/hermes-patch/hermes/metrics"
+)
+
+// serveMetrics starts the /metrics endpoint when metrics.addr is configured
+func serveMetrics() {
+	if addr := config.Get().Metrics.Addr; addr != "" {
+		metrics.Serve(addr)
+	}
+}
@@ -0,0 +1,30 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"github.com/ququzone/hermes-patch/hermes/config"
+	"github.com/ququzone/hermes-patch/hermes/notify"
+)
+
+// newAlerter returns an alerter of the configured notifiers sending digests
+// until ctx is done, the caller flushes the last digest
+func newAlerter(ctx context.Context) (*notify.Alerter, error) {
+	cfg := config.Get().Notify
+	n, err := notify.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	dedupe := time.Duration(cfg.DedupeMinutes) * time.Minute
+	if dedupe == 0 {
+		dedupe = 30 * time.Minute
+	}
+	digest := time.Duration(cfg.DigestMinutes) * time.Minute
+	if digest == 0 {
+		digest = time.Hour
+	}
+	alerter := notify.NewAlerter(n, dedupe)
+	go alerter.Run(ctx, digest)
+	return alerter, nil
+}
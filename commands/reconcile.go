@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+
+	"github.com/iotexproject/iotex-antenna-go/v2/account"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ququzone/hermes-patch/hermes/chain"
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+	"github.com/ququzone/hermes-patch/hermes/cmd/distribute"
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+type Reconcile struct {
+	fromEpoch uint64
+	toEpoch   uint64
+}
+
+func NewReconcile() *Reconcile {
+	return &Reconcile{}
+}
+
+func (c *Reconcile) Command() *cli.Command {
+	return &cli.Command{
+		Name:  "reconcile",
+		Usage: "check the completed drop records, small records and distribution chunks of an epoch range against the chain",
+		Flags: []cli.Flag{
+			&cli.Uint64Flag{
+				Name:        "from-epoch",
+				Usage:       "first end epoch to check",
+				Required:    true,
+				Destination: &c.fromEpoch,
+			},
+			&cli.Uint64Flag{
+				Name:        "to-epoch",
+				Usage:       "last end epoch to check",
+				Required:    true,
+				Destination: &c.toEpoch,
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if c.fromEpoch > c.toEpoch {
+				return fmt.Errorf("from epoch %d is after to epoch %d", c.fromEpoch, c.toEpoch)
+			}
+			if err := loadConfig(ctx); err != nil {
+				return err
+			}
+			cfg := config.Get()
+
+			conn, err := chain.Dial(cfg.Chain)
+			if err != nil {
+				log.Fatalf("construct grpc connection error: %v\n", err)
+			}
+			defer conn.Close()
+			emptyAccount, err := account.NewAccount()
+			if err != nil {
+				log.Fatalf("new empty account error: %v\n", err)
+			}
+			client := chain.NewClient(conn, emptyAccount)
+
+			err = dao.ConnectDatabase()
+			if err != nil {
+				log.Fatalf("create database error: %v\n", err)
+			}
+
+			r, err := c.load()
+			if err != nil {
+				log.Fatalf("load records error: %v\n", err)
+			}
+			findings, err := distribute.Reconcile(ctx.Context, client, r)
+			if err != nil {
+				log.Fatalf("reconcile error: %v\n", err)
+			}
+			report := &dao.ReconcileReport{
+				FromEpoch: c.fromEpoch,
+				ToEpoch:   c.toEpoch,
+				Records:   r.Records(),
+			}
+			if err := dao.SaveReconcileReport(report, findings); err != nil {
+				log.Fatalf("save reconcile report error: %v\n", err)
+			}
+
+			fmt.Printf("Reconcile report %d: %d records of end epochs %d to %d, %d findings\n\n",
+				report.ID, report.Records, c.fromEpoch, c.toEpoch, len(findings))
+			if len(findings) > 0 {
+				distribute.PrintFindings(os.Stdout, findings)
+			}
+			return nil
+		},
+	}
+}
+
+func (c *Reconcile) load() (*distribute.Reconciliation, error) {
+	drops, err := dao.FindCompletedDropRecords(c.fromEpoch, c.toEpoch)
+	if err != nil {
+		return nil, err
+	}
+	smalls, err := dao.FindCompletedSmallRecords(c.fromEpoch, c.toEpoch)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := dao.FindCompletedDistributionChunks(c.fromEpoch, c.toEpoch)
+	if err != nil {
+		return nil, err
+	}
+	runs, err := dao.FindDistributionRuns(c.fromEpoch, c.toEpoch)
+	if err != nil {
+		return nil, err
+	}
+	tips := make(map[uint64]*big.Int)
+	for _, run := range runs {
+		if tip, ok := new(big.Int).SetString(run.Tip, 10); ok {
+			tips[run.EndEpoch] = tip
+		}
+	}
+	return &distribute.Reconciliation{
+		Drops:  drops,
+		Smalls: smalls,
+		Chunks: chunks,
+		Tips:   tips,
+	}, nil
+}
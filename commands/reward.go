@@ -1,26 +1,25 @@
 package commands
 
 import (
-	"context"
 	"fmt"
 	"log"
 	"os"
 	"time"
 
-	"github.com/iotexproject/iotex-address/address"
 	"github.com/iotexproject/iotex-antenna-go/v2/account"
-	"github.com/iotexproject/iotex-antenna-go/v2/iotex"
-	"github.com/iotexproject/iotex-proto/golang/iotexapi"
 	"github.com/urfave/cli/v2"
-	"google.golang.org/grpc"
 
+	"github.com/ququzone/hermes-patch/hermes/chain"
 	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
 	"github.com/ququzone/hermes-patch/hermes/cmd/distribute"
+	"github.com/ququzone/hermes-patch/hermes/config"
 	"github.com/ququzone/hermes-patch/hermes/util"
 )
 
 type Reward struct {
 	password string
+	dryRun   bool
+	report   string
 }
 
 func NewReward() *Reward {
@@ -33,10 +32,9 @@ func (c *Reward) Command() *cli.Command {
 		Aliases: []string{"r"},
 		Flags: []cli.Flag{
 			&cli.StringFlag{
-				Name:     "password",
-				Aliases:  []string{"p"},
-				Usage:    "password file path",
-				Required: true,
+				Name:    "password",
+				Aliases: []string{"p"},
+				Usage:   "keystore password file path, not needed with a remote signer",
 				Action: func(ctx *cli.Context, s string) error {
 					data, err := os.ReadFile(s)
 					if err != nil {
@@ -49,102 +47,124 @@ func (c *Reward) Command() *cli.Command {
 					return nil
 				},
 			},
+			&cli.BoolFlag{
+				Name:        "dry-run",
+				Usage:       "calculate the next distribution and report it without sending rewards or keeping database changes",
+				Destination: &c.dryRun,
+			},
+			&cli.StringFlag{
+				Name:        "report",
+				Usage:       "write the dry run report as JSON to this file",
+				Destination: &c.report,
+			},
 		},
 		Action: func(ctx *cli.Context) error {
-			tls := util.MustFetchNonEmptyParam("RPC_TLS")
-			endpoint := util.MustFetchNonEmptyParam("IO_ENDPOINT")
-			var conn *grpc.ClientConn
-			var err error
-			if tls == "true" {
-				conn, err = iotex.NewDefaultGRPCConn(endpoint)
-				if err != nil {
-					log.Fatalf("construct grpc connection error: %v\n", err)
-				}
-			} else {
-				conn, err = iotex.NewGRPCConnWithoutTLS(endpoint)
-				if err != nil {
-					log.Fatalf("construct grpc connection error: %v\n", err)
-				}
+			if err := loadConfig(ctx); err != nil {
+				return err
 			}
+			cfg := config.Get()
+			serveMetrics()
 
+			conn, err := chain.Dial(cfg.Chain)
+			if err != nil {
+				log.Fatalf("construct grpc connection error: %v\n", err)
+			}
 			defer conn.Close()
 			emptyAccount, err := account.NewAccount()
 			if err != nil {
 				log.Fatalf("new empty account error: %v\n", err)
 			}
-			client := iotex.NewAuthedClient(iotexapi.NewAPIServiceClient(conn), 1, emptyAccount)
+			client := chain.NewClient(conn, emptyAccount)
 
 			err = dao.ConnectDatabase()
 			if err != nil {
 				log.Fatalf("create database error: %v\n", err)
 			}
 
-			notifier, err := distribute.NewNotifier(util.MustFetchNonEmptyParam("LARK_ENDPOINT"), util.MustFetchNonEmptyParam("LARK_KEY"))
+			notifier, err := newAlerter(ctx.Context)
 			if err != nil {
 				log.Fatalf("new notifier error: %v\n", err)
 			}
+			defer notifier.Flush()
 
-			acc, err := util.ReadAccount(c.password)
+			acc, err := loadAccount(ctx.Context, c.password)
 			if err != nil {
 				log.Fatalf("read account error: %v\n", err)
 			}
 
+			if c.dryRun {
+				return c.dryRunReward(acc)
+			}
+
 			retry := 0
-			for {
+			for ctx.Context.Err() == nil {
 				lastEndEpoch, err := distribute.GetLastEndEpoch(client)
 				if err != nil {
 					log.Printf("get last end epoch error: %v\n", err)
 					retry++
-					time.Sleep(5 * time.Minute)
+					util.Sleep(ctx.Context, 5*time.Minute)
 					continue
 				}
 				startEpoch := lastEndEpoch + 1
 
-				resp, err := client.API().GetChainMeta(context.Background(), &iotexapi.GetChainMetaRequest{})
+				meta, err := client.ChainMeta(ctx.Context)
 				if err != nil {
 					log.Printf("get chain meta error: %v\n", err)
 					retry++
-					time.Sleep(5 * time.Minute)
+					util.Sleep(ctx.Context, 5*time.Minute)
 					continue
 				}
-				curEpoch := resp.ChainMeta.Epoch.Num
+				curEpoch := meta.Epoch.Num
 
 				endEpoch := startEpoch + 23
 
 				if endEpoch+2 > curEpoch {
-					resp, err := client.API().GetChainMeta(context.Background(), &iotexapi.GetChainMetaRequest{})
+					meta, err := client.ChainMeta(ctx.Context)
 					if err != nil {
 						log.Printf("get chain meta error: %v\n", err)
 						retry++
-						time.Sleep(5 * time.Minute)
+						util.Sleep(ctx.Context, 5*time.Minute)
 						continue
 					}
-					curEpoch = resp.ChainMeta.Epoch.Num
+					curEpoch = meta.Epoch.Num
 					if endEpoch+2-curEpoch > 0 {
 						duration := time.Duration(endEpoch + 2 - curEpoch)
 						log.Printf("waiting %d hours for next distribute", duration)
-						time.Sleep(duration * time.Hour)
+						util.Sleep(ctx.Context, duration*time.Hour)
 						continue
 					}
 				}
 
-				sender, err := address.FromString(util.MustFetchNonEmptyParam("SENDER_ADDR"))
-				if err != nil {
-					log.Printf("get sender address error: %v\n", err)
-					retry++
-					continue
+				err = distribute.Reward(ctx.Context, notifier, acc, nil, 0, cfg.Distribution.SenderAddress.Address())
+				if err != nil && ctx.Context.Err() != nil {
+					log.Printf("distribute reward stopped: %v\n", err)
+					break
 				}
-
-				err = distribute.Reward(notifier, acc, nil, 0, sender)
 				if err != nil {
 					log.Printf("distribute reward error: %v\n", err)
-					notifier.SendMessage(fmt.Sprintf("Send rewards error %v", err))
+					notifier.Errorf("Send rewards error %v", err)
 					retry++
-					time.Sleep(5 * time.Minute)
+					util.Sleep(ctx.Context, 5*time.Minute)
 					continue
 				}
 				retry = 0
 			}
+			log.Println("reward stopped")
+			return nil
 		},
 	}
 }
+
+func (c *Reward) dryRunReward(acc account.Account) error {
+	report, err := distribute.DryRun(acc)
+	if err != nil {
+		return fmt.Errorf("dry run reward error: %v", err)
+	}
+	report.Print(os.Stdout)
+	if c.report != "" {
+		if err := report.Save(c.report); err != nil {
+			return fmt.Errorf("save report error: %v", err)
+		}
+	}
+	return nil
+}
@@ -2,25 +2,22 @@ package reward
 
 import (
 	"context"
-	"encoding/hex"
-	"errors"
 	"fmt"
 	"math/big"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	ethCrypto "github.com/ethereum/go-ethereum/crypto"
 	"github.com/iotexproject/go-pkgs/crypto"
-	"github.com/iotexproject/go-pkgs/hash"
-	"github.com/iotexproject/iotex-proto/golang/iotexapi"
-	"github.com/iotexproject/iotex-proto/golang/protocol"
 	"github.com/urfave/cli/v2"
 
 	"github.com/iotexproject/iotex-address/address"
 	"github.com/iotexproject/iotex-antenna-go/v2/account"
-	"github.com/iotexproject/iotex-antenna-go/v2/iotex"
+
+	"github.com/ququzone/hermes-patch/hermes/chain"
+	"github.com/ququzone/hermes-patch/hermes/config"
+	"github.com/ququzone/hermes-patch/hermes/signer"
 )
 
 type Claimer struct {
@@ -68,26 +65,13 @@ func (c *Claimer) Command() *cli.Command {
 			}
 			c.recipient = receiver
 
-			password, err := os.ReadFile("./pass")
+			cfg, err := config.Load(ctx.String("config"))
 			if err != nil {
-				return fmt.Errorf("read password error: %v", err)
+				return err
 			}
-
-			data, err := os.ReadFile("./key")
+			acc, err := signer.Load(ctx.Context, cfg.Signer, readLocalAccount)
 			if err != nil {
-				return fmt.Errorf("read keystore error: %v", err)
-			}
-			key, err := keystore.DecryptKey(data, string(password))
-			if err != nil {
-				return fmt.Errorf("decrypt keystore error: %v", err)
-			}
-			pk, err := crypto.BytesToPrivateKey(ethCrypto.FromECDSA(key.PrivateKey))
-			if err != nil {
-				return fmt.Errorf("decrypt private key error: %v", err)
-			}
-			acc, err := account.PrivateKeyToAccount(pk)
-			if err != nil {
-				return fmt.Errorf("private key to account error: %v", err)
+				return err
 			}
 
 			return c.claim(acc)
@@ -95,12 +79,38 @@ func (c *Claimer) Command() *cli.Command {
 	}
 }
 
+// readLocalAccount decrypts the ./key keystore with the ./pass password
+func readLocalAccount() (account.Account, error) {
+	password, err := os.ReadFile("./pass")
+	if err != nil {
+		return nil, fmt.Errorf("read password error: %v", err)
+	}
+
+	data, err := os.ReadFile("./key")
+	if err != nil {
+		return nil, fmt.Errorf("read keystore error: %v", err)
+	}
+	key, err := keystore.DecryptKey(data, string(password))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt keystore error: %v", err)
+	}
+	pk, err := crypto.BytesToPrivateKey(ethCrypto.FromECDSA(key.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt private key error: %v", err)
+	}
+	acc, err := account.PrivateKeyToAccount(pk)
+	if err != nil {
+		return nil, fmt.Errorf("private key to account error: %v", err)
+	}
+	return acc, nil
+}
+
 func (c *Claimer) claim(acc account.Account) error {
-	conn, err := iotex.NewDefaultGRPCConn(c.grpc)
+	conn, err := chain.Dial(config.Chain{Endpoint: c.grpc, TLS: true})
 	if err != nil {
 		return fmt.Errorf("new grpc connection error: %v", err)
 	}
-	client := iotex.NewAuthedClient(iotexapi.NewAPIServiceClient(conn), 1, acc)
+	client := chain.NewClient(conn, acc)
 
 	if c.interval == 0 {
 		err := claimAndTransfer(client, c.recipient)
@@ -118,7 +128,7 @@ func (c *Claimer) claim(acc account.Account) error {
 	}
 }
 
-func claimAndTransfer(c iotex.AuthedClient, recipient address.Address) error {
+func claimAndTransfer(c chain.Client, recipient address.Address) error {
 	unclaimedBalance, err := getUnclaimedBalance(c)
 	if err != nil {
 		return err
@@ -134,13 +144,10 @@ func claimAndTransfer(c iotex.AuthedClient, recipient address.Address) error {
 		return err
 	}
 
-	acc, err := c.API().GetAccount(context.Background(), &iotexapi.GetAccountRequest{
-		Address: c.Account().Address().String(),
-	})
+	balance, err := c.Balance(context.Background(), c.Address())
 	if err != nil {
 		return err
 	}
-	balance, _ := new(big.Int).SetString(acc.AccountMeta.Balance, 10)
 
 	err = transfer(c, recipient, new(big.Int).Sub(balance, minAmount))
 	if err != nil {
@@ -150,31 +157,18 @@ func claimAndTransfer(c iotex.AuthedClient, recipient address.Address) error {
 	return nil
 }
 
-func getUnclaimedBalance(c iotex.AuthedClient) (*big.Int, error) {
-	request := &iotexapi.ReadStateRequest{
-		ProtocolID: []byte(protocol.RewardingProtocolID),
-		MethodName: []byte(protocol.ReadUnclaimedBalanceMethodName),
-		Arguments:  [][]byte{[]byte(c.Account().Address().String())},
-	}
-	response, err := c.API().ReadState(context.Background(), request)
-	if err != nil {
-		return nil, err
-	}
-	unclaimedBlance, ok := big.NewInt(0).SetString(string(response.Data), 10)
-	if !ok {
-		return nil, errors.New("failed to convert string to big int")
-	}
-	return unclaimedBlance, nil
+func getUnclaimedBalance(c chain.Client) (*big.Int, error) {
+	return c.UnclaimedBalance(context.Background(), c.Address())
 }
 
-func claim(c iotex.AuthedClient, unclaimedBalance *big.Int) error {
+func claim(c chain.Client, unclaimedBalance *big.Int) error {
 	ctx := context.Background()
-	hash, err := c.ClaimReward(unclaimedBalance).Call(ctx)
+	hash, err := c.ClaimReward(ctx, unclaimedBalance, chain.Opts{})
 	if err != nil {
 		return err
 	}
 
-	err = checkActionReceipt(c, hash)
+	_, err = chain.CheckReceipt(ctx, c, hash, chain.DefaultPolling)
 	if err != nil {
 		return err
 	}
@@ -182,41 +176,17 @@ func claim(c iotex.AuthedClient, unclaimedBalance *big.Int) error {
 	return nil
 }
 
-func transfer(c iotex.AuthedClient, recipient address.Address, amount *big.Int) error {
+func transfer(c chain.Client, recipient address.Address, amount *big.Int) error {
 	ctx := context.Background()
-	hash, err := c.Transfer(recipient, amount).Call(ctx)
+	hash, err := c.Transfer(ctx, recipient, amount, chain.Opts{})
 	if err != nil {
 		return err
 	}
 
-	err = checkActionReceipt(c, hash)
+	_, err = chain.CheckReceipt(ctx, c, hash, chain.DefaultPolling)
 	if err != nil {
 		return err
 	}
 	fmt.Printf("successfully transfer %s rewards to %s\n", amount.String(), recipient.String())
 	return nil
 }
-
-func checkActionReceipt(c iotex.AuthedClient, hash hash.Hash256) error {
-	time.Sleep(5 * time.Second)
-	var resp *iotexapi.GetReceiptByActionResponse
-	var err error
-	for i := 0; i < 120; i++ {
-		resp, err = c.API().GetReceiptByAction(context.Background(), &iotexapi.GetReceiptByActionRequest{
-			ActionHash: hex.EncodeToString(hash[:]),
-		})
-		if err != nil {
-			if strings.Contains(err.Error(), "code = NotFound") {
-				time.Sleep(2 * time.Second)
-				continue
-			}
-			return err
-		}
-		if resp.ReceiptInfo.Receipt.Status != 1 {
-			return fmt.Errorf("action %x check receipt failed", hash)
-		}
-		return nil
-	}
-	fmt.Printf("action %x check receipt not found\n", hash)
-	return err
-}
@@ -3,17 +3,23 @@ package commands
 import (
 	"fmt"
 	"log"
+	"math/big"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/iotexproject/iotex-antenna-go/v2/account"
 	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
 	"github.com/ququzone/hermes-patch/hermes/cmd/distribute"
-	"github.com/ququzone/hermes-patch/hermes/util"
+	"github.com/ququzone/hermes-patch/hermes/config"
 	"github.com/urfave/cli/v2"
 )
 
 type Sender struct {
-	password string
+	passwords   []string
+	keystores   []string
+	keystoreDir string
 }
 
 func NewSender() *Sender {
@@ -26,50 +32,137 @@ func (c *Sender) Command() *cli.Command {
 		Aliases: []string{"s"},
 		Flags: []cli.Flag{
 
-			&cli.StringFlag{
-				Name:     "password",
-				Aliases:  []string{"p"},
-				Usage:    "password file path",
-				Required: true,
-				Action: func(ctx *cli.Context, s string) error {
-					data, err := os.ReadFile(s)
-					if err != nil {
-						return fmt.Errorf("read password file error: %v", err)
-					}
-					c.password = string(data)
-					if err := os.Remove(s); err != nil {
-						return fmt.Errorf("remove password file error: %v", err)
+			&cli.StringSliceFlag{
+				Name:    "password",
+				Aliases: []string{"p"},
+				Usage:   "keystore password file path, once for all keystores or once per keystore, not needed with a remote signer",
+				Action: func(ctx *cli.Context, files []string) error {
+					for _, s := range files {
+						data, err := os.ReadFile(s)
+						if err != nil {
+							return fmt.Errorf("read password file error: %v", err)
+						}
+						c.passwords = append(c.passwords, string(data))
+						if err := os.Remove(s); err != nil {
+							return fmt.Errorf("remove password file error: %v", err)
+						}
 					}
 					return nil
 				},
 			},
+			&cli.StringSliceFlag{
+				Name:    "keystore",
+				Aliases: []string{"k"},
+				Usage:   "keystore file of a sender account, repeat it to shard records across accounts",
+				Action: func(ctx *cli.Context, files []string) error {
+					c.keystores = files
+					return nil
+				},
+			},
+			&cli.StringFlag{
+				Name:        "keystore-dir",
+				Usage:       "directory whose keystore files are all sender accounts",
+				Destination: &c.keystoreDir,
+			},
 		},
 		Action: func(ctx *cli.Context) error {
+			if err := loadConfig(ctx); err != nil {
+				return err
+			}
+			serveMetrics()
+
 			err := dao.ConnectDatabase()
 			if err != nil {
 				log.Fatalf("create database error: %v\n", err)
 			}
 
-			notifier, err := distribute.NewNotifier(util.MustFetchNonEmptyParam("LARK_ENDPOINT"), util.MustFetchNonEmptyParam("LARK_KEY"))
+			notifier, err := newAlerter(ctx.Context)
 			if err != nil {
 				log.Fatalf("new notifier error: %v\n", err)
 			}
+			defer notifier.Flush()
 
-			acc, err := util.ReadAccount(c.password)
+			accounts, err := c.accounts(ctx)
 			if err != nil {
 				log.Fatalf("read account error: %v\n", err)
 			}
 
-			sender, err := distribute.NewSender(notifier, []account.Account{acc})
+			sender, err := distribute.NewSender(notifier, accounts)
 			if err != nil {
-				log.Fatalf("new notifier error: %v\n", err)
+				log.Fatalf("new sender error: %v\n", err)
 			}
-			go sender.Send()
-
-			forever := make(chan bool)
-			<-forever
+			unfunded, err := sender.Unfunded(ctx.Context, minSenderBalance(config.Get()))
+			if err != nil {
+				log.Fatalf("check sender balances error: %v\n", err)
+			}
+			if len(unfunded) > 0 {
+				log.Fatalf("sender accounts are not funded: %s\n", strings.Join(unfunded, ", "))
+			}
+			log.Printf("sending with %d accounts\n", len(accounts))
+			sender.Send(ctx.Context)
+			log.Println("sender stopped")
 
 			return nil
 		},
 	}
 }
+
+// accounts returns the accounts of the keystore flags, or the payout account
+// when no keystore is given
+func (c *Sender) accounts(ctx *cli.Context) ([]account.Account, error) {
+	keystores := append([]string(nil), c.keystores...)
+	if c.keystoreDir != "" {
+		entries, err := os.ReadDir(c.keystoreDir)
+		if err != nil {
+			return nil, fmt.Errorf("read keystore directory error: %v", err)
+		}
+		var files []string
+		for _, entry := range entries {
+			if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+				files = append(files, filepath.Join(c.keystoreDir, entry.Name()))
+			}
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no keystore in %s", c.keystoreDir)
+		}
+		sort.Strings(files)
+		keystores = append(keystores, files...)
+	}
+
+	if len(keystores) == 0 {
+		password := ""
+		if len(c.passwords) > 0 {
+			password = c.passwords[0]
+		}
+		acc, err := loadAccount(ctx.Context, password)
+		if err != nil {
+			return nil, err
+		}
+		return []account.Account{acc}, nil
+	}
+
+	if len(c.passwords) != 1 && len(c.passwords) != len(keystores) {
+		return nil, fmt.Errorf("%d passwords for %d keystores, give one for all or one per keystore", len(c.passwords), len(keystores))
+	}
+	accounts := make([]account.Account, len(keystores))
+	for i, path := range keystores {
+		password := c.passwords[0]
+		if len(c.passwords) > 1 {
+			password = c.passwords[i]
+		}
+		acc, err := readKeystore(path, password)
+		if err != nil {
+			return nil, fmt.Errorf("keystore %s: %v", path, err)
+		}
+		accounts[i] = acc
+	}
+	return accounts, nil
+}
+
+// minSenderBalance returns the balance a sender account needs to start
+func minSenderBalance(cfg *config.Config) *big.Int {
+	if minimum := cfg.Distribution.MinSenderBalance.Int(); minimum != nil {
+		return minimum
+	}
+	return new(big.Int).Mul(cfg.Gas.Price.Int(), new(big.Int).SetUint64(cfg.Gas.Limit))
+}
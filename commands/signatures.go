@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+)
+
+type MigrateSignatures struct {
+	dryRun bool
+}
+
+func NewMigrateSignatures() *MigrateSignatures {
+	return &MigrateSignatures{}
+}
+
+func (c *MigrateSignatures) Command() *cli.Command {
+	return &cli.Command{
+		Name:  "migrate-signatures",
+		Usage: "re-sign drop and small records and distribution chunks with the current signature version after verifying their legacy signature",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:        "dry-run",
+				Usage:       "verify the legacy signatures and report without writing",
+				Destination: &c.dryRun,
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if err := loadConfig(ctx); err != nil {
+				return err
+			}
+			err := dao.ConnectDatabaseToResign()
+			if err != nil {
+				log.Fatalf("create database error: %v\n", err)
+			}
+
+			return resign(c.dryRun, dao.MigrateDropRecordSignatures, dao.MigrateSmallRecordSignatures, dao.MigrateChunkSignatures)
+		},
+	}
+}
+
+// resign runs the re-signing of each table and reports the rows left untouched
+func resign(dryRun bool, migrations ...func(bool) (*dao.SignatureMigration, error)) error {
+	invalid := 0
+	for _, migrate := range migrations {
+		result, err := migrate(dryRun)
+		if err != nil {
+			log.Fatalf("re-sign records error: %v\n", err)
+		}
+		fmt.Printf("%s: %d re-signed, %d invalid\n", result.Table, result.Migrated, len(result.Invalid))
+		for _, id := range result.Invalid {
+			fmt.Printf("  %s %d failed verification\n", result.Table, id)
+		}
+		invalid += len(result.Invalid)
+	}
+	if dryRun {
+		fmt.Println("dry run, nothing was written")
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d records failed verification and were left untouched", invalid)
+	}
+	return nil
+}
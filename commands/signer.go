@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/iotexproject/iotex-antenna-go/v2/account"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ququzone/hermes-patch/hermes/config"
+	"github.com/ququzone/hermes-patch/hermes/signer"
+	"github.com/ququzone/hermes-patch/hermes/util"
+)
+
+// loadAccount returns the payout account, signed by the remote signer when
+// one is configured and by the ./key keystore otherwise
+func loadAccount(ctx context.Context, password string) (account.Account, error) {
+	return signer.Load(ctx, config.Get().Signer, func() (account.Account, error) {
+		if password == "" {
+			return nil, errors.New("password is required without a remote signer")
+		}
+		return util.ReadAccount(password)
+	})
+}
+
+type Signer struct {
+	keystore string
+	password string
+	listen   string
+	cert     string
+	key      string
+	clientCA string
+}
+
+func NewSigner() *Signer {
+	return &Signer{}
+}
+
+func (c *Signer) Command() *cli.Command {
+	return &cli.Command{
+		Name:  "signer",
+		Usage: "reference transaction signer holding the payout key",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "serve",
+				Usage: "sign action hashes over HTTPS for clients with a certificate of the client CA",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "keystore",
+						Aliases:     []string{"k"},
+						Usage:       "keystore file",
+						Value:       "./key",
+						Destination: &c.keystore,
+					},
+					&cli.StringFlag{
+						Name:     "password",
+						Aliases:  []string{"p"},
+						Usage:    "keystore password file path",
+						Required: true,
+						Action: func(ctx *cli.Context, s string) error {
+							data, err := os.ReadFile(s)
+							if err != nil {
+								return fmt.Errorf("read password file error: %v", err)
+							}
+							c.password = string(data)
+							if err := os.Remove(s); err != nil {
+								return fmt.Errorf("remove password file error: %v", err)
+							}
+							return nil
+						},
+					},
+					&cli.StringFlag{
+						Name:        "listen",
+						Aliases:     []string{"l"},
+						Usage:       "listen address",
+						Value:       "127.0.0.1:8443",
+						Destination: &c.listen,
+					},
+					&cli.StringFlag{
+						Name:        "cert",
+						Usage:       "server certificate file",
+						Required:    true,
+						Destination: &c.cert,
+					},
+					&cli.StringFlag{
+						Name:        "key",
+						Usage:       "server private key file",
+						Required:    true,
+						Destination: &c.key,
+					},
+					&cli.StringFlag{
+						Name:        "client-ca",
+						Usage:       "CA certificate file of the allowed clients",
+						Required:    true,
+						Destination: &c.clientCA,
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					acc, err := readKeystore(c.keystore, c.password)
+					if err != nil {
+						return err
+					}
+					tlsConfig, err := signer.ServerTLS(c.cert, c.key, c.clientCA)
+					if err != nil {
+						return err
+					}
+					server := &http.Server{
+						Addr:      c.listen,
+						Handler:   signer.NewServer(signer.NewLocal(acc)),
+						TLSConfig: tlsConfig,
+					}
+					go func() {
+						<-ctx.Context.Done()
+						server.Close()
+					}()
+					log.Printf("signing for %s on https://%s\n", acc.Address().String(), c.listen)
+					if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+						return fmt.Errorf("serve signer error: %v", err)
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
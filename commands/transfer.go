@@ -2,24 +2,22 @@ package commands
 
 import (
 	"context"
-	"encoding/hex"
 	"fmt"
 	"math/big"
 	"os"
-	"strings"
 	"syscall"
-	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	ethCrypto "github.com/ethereum/go-ethereum/crypto"
 	"github.com/iotexproject/go-pkgs/crypto"
-	"github.com/iotexproject/go-pkgs/hash"
 	"github.com/iotexproject/iotex-address/address"
 	"github.com/iotexproject/iotex-antenna-go/v2/account"
-	"github.com/iotexproject/iotex-antenna-go/v2/iotex"
-	"github.com/iotexproject/iotex-proto/golang/iotexapi"
 	"github.com/urfave/cli/v2"
 	"golang.org/x/term"
+
+	"github.com/ququzone/hermes-patch/hermes/chain"
+	"github.com/ququzone/hermes-patch/hermes/config"
+	"github.com/ququzone/hermes-patch/hermes/signer"
 )
 
 type Transfer struct {
@@ -50,10 +48,9 @@ func (c *Transfer) Command() *cli.Command {
 				},
 			},
 			&cli.StringFlag{
-				Name:     "keystore",
-				Aliases:  []string{"k"},
-				Usage:    "keystore file",
-				Required: true,
+				Name:    "keystore",
+				Aliases: []string{"k"},
+				Usage:   "keystore file, not needed with a remote signer",
 				Action: func(ctx *cli.Context, r string) error {
 					c.keystore = r
 					return nil
@@ -75,27 +72,36 @@ func (c *Transfer) Command() *cli.Command {
 			}
 			c.amount = amount
 
-			return c.transfer()
+			return c.transfer(ctx)
 		},
 	}
 }
 
-func (c *Transfer) transfer() error {
-	fmt.Println("Enter password:")
-	password, err := term.ReadPassword(int(syscall.Stdin))
+func (c *Transfer) transfer(ctx *cli.Context) error {
+	cfg, err := config.Load(ctx.String("config"))
 	if err != nil {
-		return fmt.Errorf("read password error: %v", err)
+		return err
 	}
-	acc, err := readKeystore(c.keystore, string(password))
+	acc, err := signer.Load(ctx.Context, cfg.Signer, func() (account.Account, error) {
+		if c.keystore == "" {
+			return nil, fmt.Errorf("keystore is required without a remote signer")
+		}
+		fmt.Println("Enter password:")
+		password, err := term.ReadPassword(int(syscall.Stdin))
+		if err != nil {
+			return nil, fmt.Errorf("read password error: %v", err)
+		}
+		return readKeystore(c.keystore, string(password))
+	})
 	if err != nil {
-		return fmt.Errorf("read keystore error: %v", err)
+		return fmt.Errorf("read account error: %v", err)
 	}
 
-	conn, err := iotex.NewDefaultGRPCConn(c.grpc)
+	conn, err := chain.Dial(config.Chain{Endpoint: c.grpc, TLS: true})
 	if err != nil {
 		return fmt.Errorf("new grpc connection error: %v", err)
 	}
-	client := iotex.NewAuthedClient(iotexapi.NewAPIServiceClient(conn), 1, acc)
+	client := chain.NewClient(conn, acc)
 
 	return transfer(client, c.recipient, c.amount)
 }
@@ -116,41 +122,17 @@ func readKeystore(path, password string) (account.Account, error) {
 	return account.PrivateKeyToAccount(pk)
 }
 
-func transfer(c iotex.AuthedClient, recipient address.Address, amount *big.Int) error {
+func transfer(c chain.Client, recipient address.Address, amount *big.Int) error {
 	ctx := context.Background()
-	hash, err := c.Transfer(recipient, amount).Call(ctx)
+	hash, err := c.Transfer(ctx, recipient, amount, chain.Opts{})
 	if err != nil {
 		return err
 	}
 
-	err = checkActionReceipt(c, hash)
+	_, err = chain.CheckReceipt(ctx, c, hash, chain.DefaultPolling)
 	if err != nil {
 		return err
 	}
 	fmt.Printf("successfully transfer %s rewards to %s\n", amount.String(), recipient.String())
 	return nil
 }
-
-func checkActionReceipt(c iotex.AuthedClient, hash hash.Hash256) error {
-	time.Sleep(5 * time.Second)
-	var resp *iotexapi.GetReceiptByActionResponse
-	var err error
-	for i := 0; i < 120; i++ {
-		resp, err = c.API().GetReceiptByAction(context.Background(), &iotexapi.GetReceiptByActionRequest{
-			ActionHash: hex.EncodeToString(hash[:]),
-		})
-		if err != nil {
-			if strings.Contains(err.Error(), "code = NotFound") {
-				time.Sleep(2 * time.Second)
-				continue
-			}
-			return err
-		}
-		if resp.ReceiptInfo.Receipt.Status != 1 {
-			return fmt.Errorf("action %x check receipt failed", hash)
-		}
-		return nil
-	}
-	fmt.Printf("action %x check receipt not found\n", hash)
-	return err
-}
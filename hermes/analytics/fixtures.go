@@ -0,0 +1,112 @@
+package analytics
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// Reward is the reward of one voter
+type Reward struct {
+	VoterIotexAddress string `json:"voterIotexAddress"`
+	Amount            string `json:"amount"`
+}
+
+// Distribution is the bookkeeping of one delegate in one epoch
+type Distribution struct {
+	DelegateName string `json:"delegateName"`
+	// RewardAddress is matched against the rewardAddress argument, an empty
+	// value matches any address
+	RewardAddress       string   `json:"rewardAddress,omitempty"`
+	RewardDistribution  []Reward `json:"rewardDistribution"`
+	StakingIotexAddress string   `json:"stakingIotexAddress"`
+	// VoterCount is served as the number of distinct voters in the range
+	VoterCount      int    `json:"voterCount"`
+	WaiveServiceFee bool   `json:"waiveServiceFee"`
+	Refund          string `json:"refund"`
+}
+
+// Fixtures are the per epoch bookkeeping served by the fake service. An epoch
+// without an entry has no bookkeeping, and amounts are served as written so
+// malformed values reach the client.
+type Fixtures struct {
+	Epochs map[uint64][]Distribution `json:"epochs"`
+}
+
+// LoadFixtures reads fixtures from a JSON file
+func LoadFixtures(path string) (*Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read fixtures error: %v", err)
+	}
+	var fixtures Fixtures
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("decode fixtures error: %v", err)
+	}
+	if fixtures.Epochs == nil {
+		fixtures.Epochs = make(map[uint64][]Distribution)
+	}
+	return &fixtures, nil
+}
+
+// Query aggregates the bookkeeping of the epoch range per delegate, the same
+// way the analytics service answers the Hermes query
+func (f *Fixtures) Query(startEpoch, epochCount uint64, rewardAddresses []string) []Distribution {
+	allowed := make(map[string]bool, len(rewardAddresses))
+	for _, v := range rewardAddresses {
+		allowed[v] = true
+	}
+
+	var names []string
+	merged := make(map[string]*Distribution)
+	voters := make(map[string]map[string]int)
+	for epoch := startEpoch; epoch < startEpoch+epochCount; epoch++ {
+		for _, d := range f.Epochs[epoch] {
+			if d.RewardAddress != "" && !allowed[d.RewardAddress] {
+				continue
+			}
+			m, ok := merged[d.DelegateName]
+			if !ok {
+				m = &Distribution{
+					DelegateName:        d.DelegateName,
+					StakingIotexAddress: d.StakingIotexAddress,
+					Refund:              "0",
+				}
+				merged[d.DelegateName] = m
+				voters[d.DelegateName] = make(map[string]int)
+				names = append(names, d.DelegateName)
+			}
+			m.WaiveServiceFee = d.WaiveServiceFee
+			m.Refund = addAmounts(m.Refund, d.Refund)
+			for _, r := range d.RewardDistribution {
+				if i, ok := voters[d.DelegateName][r.VoterIotexAddress]; ok {
+					m.RewardDistribution[i].Amount = addAmounts(m.RewardDistribution[i].Amount, r.Amount)
+					continue
+				}
+				voters[d.DelegateName][r.VoterIotexAddress] = len(m.RewardDistribution)
+				m.RewardDistribution = append(m.RewardDistribution, r)
+			}
+			m.VoterCount = len(m.RewardDistribution)
+		}
+	}
+
+	distributions := make([]Distribution, 0, len(names))
+	for _, name := range names {
+		distributions = append(distributions, *merged[name])
+	}
+	return distributions
+}
+
+// addAmounts sums two decimal amounts, a malformed amount is returned as is
+func addAmounts(a, b string) string {
+	x, ok := new(big.Int).SetString(a, 10)
+	if !ok {
+		return a
+	}
+	y, ok := new(big.Int).SetString(b, 10)
+	if !ok {
+		return b
+	}
+	return x.Add(x, y).String()
+}
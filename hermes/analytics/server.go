@@ -0,0 +1,162 @@
+package analytics
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type request struct {
+	Query     string `json:"query"`
+	Variables struct {
+		StartEpoch    *uint64  `json:"startEpoch"`
+		EpochCount    *uint64  `json:"epochCount"`
+		RewardAddress []string `json:"rewardAddress"`
+	} `json:"variables"`
+}
+
+type gqlError struct {
+	Message string `json:"message"`
+}
+
+// Server is a stand-in for the analytics GraphQL service. It only answers the
+// Hermes query, from fixtures.
+type Server struct {
+	fixtures *Fixtures
+	token    string
+}
+
+// NewServer returns a server answering from fixtures, requests must carry
+// token as bearer token unless it is empty
+func NewServer(fixtures *Fixtures, token string) *Server {
+	return &Server{
+		fixtures: fixtures,
+		token:    token,
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.token != "" && r.Header.Get("Authorization") != "Bearer "+s.token {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrors(w, fmt.Sprintf("decode request error: %v", err))
+		return
+	}
+	if !strings.Contains(req.Query, "Hermes(") {
+		writeErrors(w, "only the Hermes query is supported")
+		return
+	}
+	if req.Variables.StartEpoch == nil || req.Variables.EpochCount == nil {
+		writeErrors(w, "startEpoch and epochCount are required")
+		return
+	}
+	selected, ok := selection(req.Query, "hermesDistribution")
+	if !ok {
+		writeErrors(w, "hermesDistribution selection is missing")
+		return
+	}
+	distributions := s.fixtures.Query(*req.Variables.StartEpoch, *req.Variables.EpochCount, req.Variables.RewardAddress)
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": map[string]interface{}{
+			"Hermes": map[string]interface{}{
+				"hermesDistribution": toResponse(distributions, selected),
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("write response error: %v\n", err)
+	}
+}
+
+// fields is a parsed GraphQL selection set, leaves map to nil
+type fields map[string]fields
+
+// selection parses the selection set following name in query
+func selection(query, name string) (fields, bool) {
+	i := strings.Index(query, name)
+	if i < 0 {
+		return nil, false
+	}
+	rest := strings.TrimLeft(query[i+len(name):], " \t\n")
+	if !strings.HasPrefix(rest, "{") {
+		return nil, false
+	}
+	result, _ := parseFields(rest[1:])
+	return result, true
+}
+
+// parseFields parses s up to the closing brace and returns the remainder
+func parseFields(s string) (fields, string) {
+	result := make(fields)
+	var last string
+	for len(s) > 0 {
+		switch c := s[0]; {
+		case c == '}':
+			return result, s[1:]
+		case c == '{':
+			result[last], s = parseFields(s[1:])
+		case c == ',' || c == ' ' || c == '\t' || c == '\n':
+			s = s[1:]
+		default:
+			end := strings.IndexAny(s, ",{} \t\n")
+			if end < 0 {
+				end = len(s)
+			}
+			last = s[:end]
+			result[last] = nil
+			s = s[end:]
+		}
+	}
+	return result, s
+}
+
+// toResponse renders distributions with the selected fields only
+func toResponse(distributions []Distribution, selected fields) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(distributions))
+	for _, d := range distributions {
+		rewards := make([]map[string]interface{}, 0, len(d.RewardDistribution))
+		for _, r := range d.RewardDistribution {
+			rewards = append(rewards, pick(selected["rewardDistribution"], map[string]interface{}{
+				"voterIotexAddress": r.VoterIotexAddress,
+				"amount":            r.Amount,
+			}))
+		}
+		result = append(result, pick(selected, map[string]interface{}{
+			"delegateName":        d.DelegateName,
+			"rewardDistribution":  rewards,
+			"stakingIotexAddress": d.StakingIotexAddress,
+			"voterCount":          d.VoterCount,
+			"waiveServiceFee":     d.WaiveServiceFee,
+			"refund":              d.Refund,
+		}))
+	}
+	return result
+}
+
+func pick(selected fields, values map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(selected))
+	for name := range selected {
+		if v, ok := values[name]; ok {
+			result[name] = v
+		}
+	}
+	return result
+}
+
+func writeErrors(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []gqlError{{Message: message}},
+	})
+}
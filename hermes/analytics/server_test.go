@@ -0,0 +1,80 @@
+package analytics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shurcooL/graphql"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+type hermesQuery struct {
+	Hermes struct {
+		HermesDistribution []struct {
+			DelegateName       graphql.String
+			RewardDistribution []struct {
+				VoterIotexAddress graphql.String
+				Amount            graphql.String
+			}
+			VoterCount graphql.Int
+			Refund     graphql.String
+		}
+	} `graphql:"Hermes(startEpoch: $startEpoch, epochCount: $epochCount, rewardAddress: $rewardAddress)"`
+}
+
+func TestServer(t *testing.T) {
+	require := require.New(t)
+
+	fixtures, err := LoadFixtures("testdata/complete.json")
+	require.NoError(err)
+	server := httptest.NewServer(NewServer(fixtures, "secret"))
+	defer server.Close()
+
+	query := func(token string, startEpoch, epochCount int) (*hermesQuery, error) {
+		httpClient := oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}))
+		var output hermesQuery
+		err := graphql.NewClient(server.URL, httpClient).Query(context.Background(), &output, map[string]interface{}{
+			"startEpoch":    graphql.Int(startEpoch),
+			"epochCount":    graphql.Int(epochCount),
+			"rewardAddress": []graphql.String{},
+		})
+		return &output, err
+	}
+
+	_, err = query("wrong", 100, 1)
+	require.Error(err)
+
+	output, err := query("secret", 100, 2)
+	require.NoError(err)
+	require.Len(output.Hermes.HermesDistribution, 2)
+	alpha := output.Hermes.HermesDistribution[0]
+	require.Equal(graphql.String("alpha"), alpha.DelegateName)
+	require.Equal(graphql.Int(2), alpha.VoterCount)
+	require.Equal(graphql.String("10000000000000000000"), alpha.Refund)
+	require.Equal(graphql.String("2000000000000000000"), alpha.RewardDistribution[0].Amount)
+
+	output, err = query("secret", 102, 1)
+	require.NoError(err)
+	require.Empty(output.Hermes.HermesDistribution)
+
+	resp, err := http.Get(server.URL)
+	require.NoError(err)
+	resp.Body.Close()
+	require.Equal(http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestQueryKeepsMalformedAmounts(t *testing.T) {
+	require := require.New(t)
+
+	fixtures := &Fixtures{Epochs: map[uint64][]Distribution{
+		1: {{DelegateName: "a", Refund: "1", RewardDistribution: []Reward{{VoterIotexAddress: "v", Amount: "1"}}}},
+		2: {{DelegateName: "a", Refund: "x", RewardDistribution: []Reward{{VoterIotexAddress: "v", Amount: "1.5"}}}},
+	}}
+	distributions := fixtures.Query(1, 2, nil)
+	require.Len(distributions, 1)
+	require.Equal("x", distributions[0].Refund)
+	require.Equal("1.5", distributions[0].RewardDistribution[0].Amount)
+}
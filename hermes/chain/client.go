@@ -0,0 +1,505 @@
+package chain
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	grpc_retry "github.com/grpc-ecosystem/go-grpc-middleware/retry"
+	"github.com/iotexproject/go-pkgs/hash"
+	"github.com/iotexproject/iotex-address/address"
+	"github.com/iotexproject/iotex-antenna-go/v2/account"
+	"github.com/iotexproject/iotex-antenna-go/v2/iotex"
+	"github.com/iotexproject/iotex-proto/golang/iotexapi"
+	"github.com/iotexproject/iotex-proto/golang/iotextypes"
+	"github.com/iotexproject/iotex-proto/golang/protocol"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/ququzone/hermes-patch/hermes/config"
+	"github.com/ququzone/hermes-patch/hermes/metrics"
+)
+
+// ErrNotFound is returned when the requested receipt, bucket or candidate does not exist
+var ErrNotFound = errors.New("not found")
+
+// chainID is the ID of the IoTeX mainnet actions are signed for
+const chainID = 1
+
+// SignedAction is an action signed but not sent, its hash is known before it
+// is broadcast
+type SignedAction struct {
+	Action *iotextypes.Action
+	Hash   hash.Hash256
+}
+
+// Nonce returns the nonce of the action
+func (a *SignedAction) Nonce() uint64 {
+	return a.Action.Core.Nonce
+}
+
+// Bytes returns the encoded action, ParseSignedAction decodes it
+func (a *SignedAction) Bytes() ([]byte, error) {
+	return proto.Marshal(a.Action)
+}
+
+// ParseSignedAction decodes the action of hash h encoded by Bytes
+func ParseSignedAction(h hash.Hash256, data []byte) (*SignedAction, error) {
+	act := &iotextypes.Action{}
+	if err := proto.Unmarshal(data, act); err != nil {
+		return nil, fmt.Errorf("decode action %x error: %v", h, err)
+	}
+	if act.Core == nil {
+		return nil, fmt.Errorf("action %x has no core", h)
+	}
+	return &SignedAction{Action: act, Hash: h}, nil
+}
+
+// Opts are the optional settings of a sent action, zero values are filled by the node
+type Opts struct {
+	// Amount is the value sent along with a contract execution
+	Amount   *big.Int
+	GasPrice *big.Int
+	GasLimit uint64
+	// Nonce is the explicit nonce of the action, zero uses the pending nonce
+	Nonce uint64
+}
+
+// Client is the chain access of hermes, bound to the account sending actions
+type Client interface {
+	// Address returns the address of the sending account
+	Address() address.Address
+	// PendingNonce returns the nonce of the next action of the sending account
+	PendingNonce(ctx context.Context) (uint64, error)
+	// ConfirmedNonce returns the nonce of the last action of addr minted on
+	// chain, actions waiting in a pool are not counted
+	ConfirmedNonce(ctx context.Context, addr address.Address) (uint64, error)
+	ChainMeta(ctx context.Context) (*iotextypes.ChainMeta, error)
+	Balance(ctx context.Context, addr address.Address) (*big.Int, error)
+	UnclaimedBalance(ctx context.Context, addr address.Address) (*big.Int, error)
+	Candidate(ctx context.Context, name string) (*iotextypes.CandidateV2, error)
+	Bucket(ctx context.Context, index uint64) (*iotextypes.VoteBucket, error)
+	// ReadContract calls a view method and returns its decoded outputs
+	ReadContract(ctx context.Context, contract address.Address, contractABI abi.ABI, method string, args ...interface{}) ([]interface{}, error)
+	ExecuteContract(ctx context.Context, contract address.Address, contractABI abi.ABI, opts Opts, method string, args ...interface{}) (hash.Hash256, error)
+	Transfer(ctx context.Context, to address.Address, amount *big.Int, opts Opts) (hash.Hash256, error)
+	// SuggestGasPrice returns the gas price the node suggests
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	// EstimateExecution returns the gas the node estimates for a contract execution
+	EstimateExecution(ctx context.Context, contract address.Address, contractABI abi.ABI, amount *big.Int, method string, args ...interface{}) (uint64, error)
+	EstimateTransfer(ctx context.Context, to address.Address, amount *big.Int) (uint64, error)
+	EstimateAddDeposit(ctx context.Context, bucket uint64, amount *big.Int) (uint64, error)
+	AddDeposit(ctx context.Context, bucket uint64, amount *big.Int, opts Opts) (hash.Hash256, error)
+	// SignTransfer signs a transfer without sending it, a zero nonce is the pending nonce
+	SignTransfer(ctx context.Context, to address.Address, amount *big.Int, opts Opts) (*SignedAction, error)
+	// SignAddDeposit signs a deposit without sending it, a zero nonce is the pending nonce
+	SignAddDeposit(ctx context.Context, bucket uint64, amount *big.Int, opts Opts) (*SignedAction, error)
+	// SignExecution signs a contract execution without sending it, a zero
+	// nonce is the pending nonce
+	SignExecution(ctx context.Context, contract address.Address, contractABI abi.ABI, opts Opts, method string, args ...interface{}) (*SignedAction, error)
+	// SendAction broadcasts an action signed by SignTransfer, SignAddDeposit or
+	// SignExecution, or decoded by ParseSignedAction. An action not matching its hash is not sent.
+	SendAction(ctx context.Context, act *SignedAction) error
+	ClaimReward(ctx context.Context, amount *big.Int, opts Opts) (hash.Hash256, error)
+	// Receipt returns the receipt of an action, or ErrNotFound if it is not minted yet
+	Receipt(ctx context.Context, h hash.Hash256) (*iotextypes.Receipt, error)
+	// Action returns the core of a sent action, or ErrNotFound if the node doesn't know it
+	Action(ctx context.Context, h hash.Hash256) (*iotextypes.ActionCore, error)
+}
+
+// Dial connects to the configured IoTeX node, failed calls are counted in metrics
+func Dial(cfg config.Chain) (*grpc.ClientConn, error) {
+	retry := []grpc_retry.CallOption{
+		grpc_retry.WithBackoff(grpc_retry.BackoffLinear(100 * time.Second)),
+		grpc_retry.WithMax(3),
+	}
+	creds := insecure.NewCredentials()
+	if cfg.TLS {
+		creds = credentials.NewTLS(&tls.Config{})
+	}
+	return grpc.Dial(cfg.Endpoint,
+		grpc.WithStreamInterceptor(grpc_retry.StreamClientInterceptor(retry...)),
+		grpc.WithChainUnaryInterceptor(metrics.UnaryClientInterceptor, grpc_retry.UnaryClientInterceptor(retry...)),
+		grpc.WithTransportCredentials(creds))
+}
+
+type client struct {
+	authed iotex.AuthedClient
+}
+
+// NewClient returns a Client sending actions from acc over conn
+func NewClient(conn *grpc.ClientConn, acc account.Account) Client {
+	return &client{
+		authed: iotex.NewAuthedClient(iotexapi.NewAPIServiceClient(conn), chainID, acc),
+	}
+}
+
+func (c *client) Address() address.Address {
+	return c.authed.Account().Address()
+}
+
+func (c *client) PendingNonce(ctx context.Context) (uint64, error) {
+	resp, err := c.authed.API().GetAccount(ctx, &iotexapi.GetAccountRequest{
+		Address: c.Address().String(),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return resp.AccountMeta.PendingNonce, nil
+}
+
+func (c *client) ConfirmedNonce(ctx context.Context, addr address.Address) (uint64, error) {
+	resp, err := c.authed.API().GetAccount(ctx, &iotexapi.GetAccountRequest{
+		Address: addr.String(),
+	})
+	if err != nil {
+		return 0, Classify(err)
+	}
+	return resp.AccountMeta.Nonce, nil
+}
+
+func (c *client) ChainMeta(ctx context.Context) (*iotextypes.ChainMeta, error) {
+	resp, err := c.authed.API().GetChainMeta(ctx, &iotexapi.GetChainMetaRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.ChainMeta, nil
+}
+
+func (c *client) Balance(ctx context.Context, addr address.Address) (*big.Int, error) {
+	resp, err := c.authed.API().GetAccount(ctx, &iotexapi.GetAccountRequest{
+		Address: addr.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	balance, ok := new(big.Int).SetString(resp.AccountMeta.Balance, 10)
+	if !ok {
+		return nil, errors.New("failed to convert string to big int")
+	}
+	return balance, nil
+}
+
+func (c *client) UnclaimedBalance(ctx context.Context, addr address.Address) (*big.Int, error) {
+	response, err := c.authed.API().ReadState(ctx, &iotexapi.ReadStateRequest{
+		ProtocolID: []byte(protocol.RewardingProtocolID),
+		MethodName: []byte(protocol.ReadUnclaimedBalanceMethodName),
+		Arguments:  [][]byte{[]byte(addr.String())},
+	})
+	if err != nil {
+		return nil, err
+	}
+	balance, ok := new(big.Int).SetString(string(response.Data), 10)
+	if !ok {
+		return nil, errors.New("failed to convert string to big int")
+	}
+	return balance, nil
+}
+
+func (c *client) readStaking(ctx context.Context, method iotexapi.ReadStakingDataMethod_Name, arguments *iotexapi.ReadStakingDataRequest, result proto.Message) error {
+	methodBytes, err := proto.Marshal(&iotexapi.ReadStakingDataMethod{Method: method})
+	if err != nil {
+		return err
+	}
+	argumentsBytes, err := proto.Marshal(arguments)
+	if err != nil {
+		return err
+	}
+	response, err := c.authed.API().ReadState(ctx, &iotexapi.ReadStateRequest{
+		ProtocolID: []byte("staking"),
+		MethodName: methodBytes,
+		Arguments:  [][]byte{argumentsBytes},
+	})
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(response.Data, result)
+}
+
+func (c *client) Candidate(ctx context.Context, name string) (*iotextypes.CandidateV2, error) {
+	var result iotextypes.CandidateV2
+	err := c.readStaking(ctx, iotexapi.ReadStakingDataMethod_CANDIDATE_BY_NAME, &iotexapi.ReadStakingDataRequest{
+		Request: &iotexapi.ReadStakingDataRequest_CandidateByName_{
+			CandidateByName: &iotexapi.ReadStakingDataRequest_CandidateByName{
+				CandName: name,
+			},
+		},
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *client) Bucket(ctx context.Context, index uint64) (*iotextypes.VoteBucket, error) {
+	var result iotextypes.VoteBucketList
+	err := c.readStaking(ctx, iotexapi.ReadStakingDataMethod_BUCKETS_BY_INDEXES, &iotexapi.ReadStakingDataRequest{
+		Request: &iotexapi.ReadStakingDataRequest_BucketsByIndexes{
+			BucketsByIndexes: &iotexapi.ReadStakingDataRequest_VoteBucketsByIndexes{
+				Index: []uint64{index},
+			},
+		},
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Buckets) == 0 {
+		return nil, ErrNotFound
+	}
+	return result.Buckets[0], nil
+}
+
+func (c *client) ReadContract(ctx context.Context, contract address.Address, contractABI abi.ABI, method string, args ...interface{}) ([]interface{}, error) {
+	data, err := c.authed.Contract(contract, contractABI).Read(method, args...).Call(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return data.Unmarshal()
+}
+
+func (c *client) ExecuteContract(ctx context.Context, contract address.Address, contractABI abi.ABI, opts Opts, method string, args ...interface{}) (hash.Hash256, error) {
+	caller := c.authed.Contract(contract, contractABI).Execute(method, args...)
+	if opts.Amount != nil {
+		caller.SetAmount(opts.Amount)
+	}
+	if opts.GasPrice != nil {
+		caller.SetGasPrice(opts.GasPrice)
+	}
+	if opts.GasLimit != 0 {
+		caller.SetGasLimit(opts.GasLimit)
+	}
+	if opts.Nonce != 0 {
+		caller.SetNonce(opts.Nonce)
+	}
+	h, err := caller.Call(ctx)
+	return h, Classify(err)
+}
+
+func (c *client) send(ctx context.Context, caller iotex.SendActionCaller, opts Opts) (hash.Hash256, error) {
+	if opts.GasPrice != nil {
+		caller.SetGasPrice(opts.GasPrice)
+	}
+	if opts.GasLimit != 0 {
+		caller.SetGasLimit(opts.GasLimit)
+	}
+	if opts.Nonce != 0 {
+		caller.SetNonce(opts.Nonce)
+	}
+	h, err := caller.Call(ctx)
+	return h, Classify(err)
+}
+
+func (c *client) Transfer(ctx context.Context, to address.Address, amount *big.Int, opts Opts) (hash.Hash256, error) {
+	return c.send(ctx, c.authed.Transfer(to, amount), opts)
+}
+
+func (c *client) AddDeposit(ctx context.Context, bucket uint64, amount *big.Int, opts Opts) (hash.Hash256, error) {
+	return c.send(ctx, c.authed.Staking().AddDeposit(bucket, amount), opts)
+}
+
+func (c *client) SignTransfer(ctx context.Context, to address.Address, amount *big.Int, opts Opts) (*SignedAction, error) {
+	return c.sign(ctx, &iotextypes.ActionCore{
+		Action: &iotextypes.ActionCore_Transfer{Transfer: &iotextypes.Transfer{
+			Amount:    amount.String(),
+			Recipient: to.String(),
+		}},
+	}, opts)
+}
+
+func (c *client) SignAddDeposit(ctx context.Context, bucket uint64, amount *big.Int, opts Opts) (*SignedAction, error) {
+	return c.sign(ctx, &iotextypes.ActionCore{
+		Action: &iotextypes.ActionCore_StakeAddDeposit{StakeAddDeposit: &iotextypes.StakeAddDeposit{
+			BucketIndex: bucket,
+			Amount:      amount.String(),
+		}},
+	}, opts)
+}
+
+func (c *client) SignExecution(ctx context.Context, contract address.Address, contractABI abi.ABI, opts Opts, method string, args ...interface{}) (*SignedAction, error) {
+	data, err := contractABI.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	amount := "0"
+	if opts.Amount != nil {
+		amount = opts.Amount.String()
+	}
+	return c.sign(ctx, &iotextypes.ActionCore{
+		Action: &iotextypes.ActionCore_Execution{Execution: &iotextypes.Execution{
+			Amount:   amount,
+			Contract: contract.String(),
+			Data:     data,
+		}},
+	}, opts)
+}
+
+// sign seals core like the antenna callers do, gas price and limit are required
+func (c *client) sign(ctx context.Context, core *iotextypes.ActionCore, opts Opts) (*SignedAction, error) {
+	if opts.GasPrice == nil || opts.GasLimit == 0 {
+		return nil, errors.New("gas price and limit are required to sign an action")
+	}
+	nonce := opts.Nonce
+	if nonce == 0 {
+		var err error
+		if nonce, err = c.PendingNonce(ctx); err != nil {
+			return nil, Classify(err)
+		}
+	}
+	core.Version = iotex.ProtocolVersion
+	core.ChainID = chainID
+	core.Nonce = nonce
+	core.GasLimit = opts.GasLimit
+	core.GasPrice = opts.GasPrice.String()
+	msg, err := proto.Marshal(core)
+	if err != nil {
+		return nil, err
+	}
+	acc := c.authed.Account()
+	sig, err := acc.Sign(msg)
+	if err != nil {
+		return nil, fmt.Errorf("sign action error: %v", err)
+	}
+	act := &iotextypes.Action{
+		Core:         core,
+		SenderPubKey: acc.PublicKey().Bytes(),
+		Signature:    sig,
+	}
+	h, err := iotex.ActionHash(act, chainID)
+	if err != nil {
+		return nil, err
+	}
+	return &SignedAction{Action: act, Hash: h}, nil
+}
+
+func (c *client) SendAction(ctx context.Context, act *SignedAction) error {
+	h, err := iotex.ActionHash(act.Action, chainID)
+	if err != nil {
+		return err
+	}
+	if h != act.Hash {
+		return fmt.Errorf("action hash is %x instead of %x", h, act.Hash)
+	}
+	resp, err := c.authed.API().SendAction(ctx, &iotexapi.SendActionRequest{Action: act.Action})
+	if err != nil {
+		return Classify(err)
+	}
+	if resp.ActionHash != hex.EncodeToString(act.Hash[:]) {
+		return fmt.Errorf("node accepted action %s instead of %x", resp.ActionHash, act.Hash)
+	}
+	return nil
+}
+
+func (c *client) ClaimReward(ctx context.Context, amount *big.Int, opts Opts) (hash.Hash256, error) {
+	caller := c.authed.ClaimReward(amount)
+	if opts.GasPrice != nil {
+		caller.SetGasPrice(opts.GasPrice)
+	}
+	if opts.GasLimit != 0 {
+		caller.SetGasLimit(opts.GasLimit)
+	}
+	if opts.Nonce != 0 {
+		caller.SetNonce(opts.Nonce)
+	}
+	h, err := caller.Call(ctx)
+	return h, Classify(err)
+}
+
+func (c *client) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	resp, err := c.authed.API().SuggestGasPrice(ctx, &iotexapi.SuggestGasPriceRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetUint64(resp.GasPrice), nil
+}
+
+func (c *client) estimate(ctx context.Context, request *iotexapi.EstimateActionGasConsumptionRequest) (uint64, error) {
+	request.CallerAddress = c.Address().String()
+	resp, err := c.authed.API().EstimateActionGasConsumption(ctx, request)
+	if err != nil {
+		return 0, Classify(err)
+	}
+	return resp.Gas, nil
+}
+
+func (c *client) EstimateExecution(ctx context.Context, contract address.Address, contractABI abi.ABI, amount *big.Int, method string, args ...interface{}) (uint64, error) {
+	data, err := contractABI.Pack(method, args...)
+	if err != nil {
+		return 0, err
+	}
+	if amount == nil {
+		amount = big.NewInt(0)
+	}
+	return c.estimate(ctx, &iotexapi.EstimateActionGasConsumptionRequest{
+		Action: &iotexapi.EstimateActionGasConsumptionRequest_Execution{
+			Execution: &iotextypes.Execution{
+				Amount:   amount.String(),
+				Contract: contract.String(),
+				Data:     data,
+			},
+		},
+	})
+}
+
+func (c *client) EstimateTransfer(ctx context.Context, to address.Address, amount *big.Int) (uint64, error) {
+	return c.estimate(ctx, &iotexapi.EstimateActionGasConsumptionRequest{
+		Action: &iotexapi.EstimateActionGasConsumptionRequest_Transfer{
+			Transfer: &iotextypes.Transfer{
+				Amount:    amount.String(),
+				Recipient: to.String(),
+			},
+		},
+	})
+}
+
+func (c *client) EstimateAddDeposit(ctx context.Context, bucket uint64, amount *big.Int) (uint64, error) {
+	return c.estimate(ctx, &iotexapi.EstimateActionGasConsumptionRequest{
+		Action: &iotexapi.EstimateActionGasConsumptionRequest_StakeAddDeposit{
+			StakeAddDeposit: &iotextypes.StakeAddDeposit{
+				BucketIndex: bucket,
+				Amount:      amount.String(),
+			},
+		},
+	})
+}
+
+func (c *client) Receipt(ctx context.Context, h hash.Hash256) (*iotextypes.Receipt, error) {
+	resp, err := c.authed.API().GetReceiptByAction(ctx, &iotexapi.GetReceiptByActionRequest{
+		ActionHash: hex.EncodeToString(h[:]),
+	})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrNotFound
+		}
+		return nil, Classify(err)
+	}
+	return resp.ReceiptInfo.Receipt, nil
+}
+
+func (c *client) Action(ctx context.Context, h hash.Hash256) (*iotextypes.ActionCore, error) {
+	resp, err := c.authed.API().GetActions(ctx, &iotexapi.GetActionsRequest{
+		Lookup: &iotexapi.GetActionsRequest_ByHash{
+			ByHash: &iotexapi.GetActionByHashRequest{
+				ActionHash: hex.EncodeToString(h[:]),
+			},
+		},
+	})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if len(resp.ActionInfo) == 0 || resp.ActionInfo[0].Action == nil {
+		return nil, ErrNotFound
+	}
+	return resp.ActionInfo[0].Action.Core, nil
+}
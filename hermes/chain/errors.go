@@ -0,0 +1,124 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/iotexproject/go-pkgs/hash"
+	"github.com/iotexproject/iotex-proto/golang/iotextypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Classes of chain failures. Actions sent by a Client and receipts checked by
+// ReceiptError fail with an error of one of these classes when it is known,
+// retry policies test them with errors.Is.
+var (
+	// ErrRetryable is a transient failure of the node, the same call may succeed later
+	ErrRetryable = errors.New("retryable")
+	// ErrNonceConflict is an action whose nonce is already used or leaves a gap
+	ErrNonceConflict = errors.New("nonce conflict")
+	// ErrInsufficientFunds is an action its sender can't pay for, it is also
+	// the node error message
+	ErrInsufficientFunds = errors.New("insufficient funds for gas * price + value")
+	// ErrGasLimit is an action needing more gas than a block holds
+	ErrGasLimit = errors.New("exceeds block gas limit")
+	// ErrReverted is a minted action that failed, sending it again fails the same way
+	ErrReverted = errors.New("reverted")
+	// ErrBucketInvalid is a deposit to a bucket that doesn't exist or doesn't auto stake
+	ErrBucketInvalid = errors.New("bucket invalid")
+)
+
+// Node errors of an action nonce, they are classified as ErrNonceConflict
+var (
+	// ErrNonceTooLow is the node error of an action reusing a nonce
+	ErrNonceTooLow = errors.New("nonce too low")
+	// ErrNonceTooHigh is the node error of an action leaving a nonce gap
+	ErrNonceTooHigh = errors.New("nonce too high")
+)
+
+// Error is a chain failure of class Kind. errors.Is matches it against both
+// its class and the original error.
+type Error struct {
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+var classes = []error{ErrRetryable, ErrNonceConflict, ErrInsufficientFunds, ErrGasLimit, ErrReverted, ErrBucketInvalid}
+
+// Classify returns err in its class. An error already classified, or whose
+// class is unknown, is returned as is.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, class := range classes {
+		if errors.Is(err, class) {
+			return err
+		}
+	}
+	if kind := classOf(err); kind != nil {
+		return &Error{Kind: kind, Err: err}
+	}
+	return err
+}
+
+// classOf maps gRPC status codes and node error messages to a class
+func classOf(err error) error {
+	if errors.Is(err, ErrNonceTooLow) || errors.Is(err, ErrNonceTooHigh) {
+		return ErrNonceConflict
+	}
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	message := err.Error()
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+			return ErrRetryable
+		}
+		message = s.Message()
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		return ErrRetryable
+	}
+	message = strings.ToLower(message)
+	switch {
+	case strings.Contains(message, "insufficient funds"):
+		return ErrInsufficientFunds
+	case strings.Contains(message, ErrNonceTooLow.Error()), strings.Contains(message, ErrNonceTooHigh.Error()):
+		return ErrNonceConflict
+	case strings.Contains(message, ErrGasLimit.Error()):
+		return ErrGasLimit
+	case strings.Contains(message, "execution reverted"):
+		return ErrReverted
+	}
+	return nil
+}
+
+// ReceiptError returns nil for a successful receipt of h, and the error of the
+// class of its status otherwise
+func ReceiptError(h hash.Hash256, receipt *iotextypes.Receipt) error {
+	status := iotextypes.ReceiptStatus(receipt.Status)
+	var kind error
+	switch status {
+	case iotextypes.ReceiptStatus_Success:
+		return nil
+	case iotextypes.ReceiptStatus_ErrInvalidBucketIndex, iotextypes.ReceiptStatus_ErrInvalidBucketType:
+		kind = ErrBucketInvalid
+	case iotextypes.ReceiptStatus_ErrInsufficientBalance, iotextypes.ReceiptStatus_ErrNotEnoughBalance:
+		kind = ErrInsufficientFunds
+	default:
+		kind = ErrReverted
+	}
+	return &Error{Kind: kind, Err: fmt.Errorf("action %x failed with receipt status %d %s", h, receipt.Status, status)}
+}
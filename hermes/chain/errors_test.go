@@ -0,0 +1,69 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/iotexproject/go-pkgs/hash"
+	"github.com/iotexproject/iotex-proto/golang/iotextypes"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassify(t *testing.T) {
+	require := require.New(t)
+
+	for _, c := range []struct {
+		err  error
+		kind error
+	}{
+		{status.Error(codes.Unavailable, "connection refused"), ErrRetryable},
+		{status.Error(codes.DeadlineExceeded, "timeout"), ErrRetryable},
+		{fmt.Errorf("call error: %w", context.DeadlineExceeded), ErrRetryable},
+		{status.Error(codes.Internal, "insufficient funds for gas * price + value"), ErrInsufficientFunds},
+		{status.Error(codes.Internal, "invalid nonce: nonce too low"), ErrNonceConflict},
+		{status.Error(codes.Internal, "nonce 7 is invalid: nonce too high"), ErrNonceConflict},
+		{ErrNonceTooLow, ErrNonceConflict},
+		{ErrNonceTooHigh, ErrNonceConflict},
+		{status.Error(codes.Internal, "execution reverted: not owner"), ErrReverted},
+		{status.Error(codes.InvalidArgument, "gas limit exceeds block gas limit"), ErrGasLimit},
+		{ErrInsufficientFunds, ErrInsufficientFunds},
+	} {
+		err := Classify(c.err)
+		require.True(errors.Is(err, c.kind), "%v is not %v", c.err, c.kind)
+		require.True(errors.Is(err, c.err))
+		require.Equal(c.err.Error(), err.Error())
+	}
+
+	unknown := status.Error(codes.InvalidArgument, "invalid address")
+	require.Equal(unknown, Classify(unknown))
+	// other errors mentioning a nonce are not nonce conflicts
+	unknown = status.Error(codes.Internal, "failed to get pending nonce")
+	require.Equal(unknown, Classify(unknown))
+	require.Equal(context.Canceled, Classify(context.Canceled))
+	require.Nil(Classify(nil))
+
+	// classifying twice keeps the first class
+	once := Classify(ErrNonceTooLow)
+	require.Equal(once, Classify(once))
+}
+
+func TestReceiptError(t *testing.T) {
+	require := require.New(t)
+
+	h := hash.Hash256b([]byte("action"))
+	receipt := func(s iotextypes.ReceiptStatus) *iotextypes.Receipt {
+		return &iotextypes.Receipt{Status: uint64(s)}
+	}
+	require.NoError(ReceiptError(h, receipt(iotextypes.ReceiptStatus_Success)))
+	require.ErrorIs(ReceiptError(h, receipt(iotextypes.ReceiptStatus_ErrInvalidBucketType)), ErrBucketInvalid)
+	require.ErrorIs(ReceiptError(h, receipt(iotextypes.ReceiptStatus_ErrInvalidBucketIndex)), ErrBucketInvalid)
+	require.ErrorIs(ReceiptError(h, receipt(iotextypes.ReceiptStatus_ErrNotEnoughBalance)), ErrInsufficientFunds)
+	require.ErrorIs(ReceiptError(h, receipt(iotextypes.ReceiptStatus_ErrExecutionReverted)), ErrReverted)
+	err := ReceiptError(h, receipt(iotextypes.ReceiptStatus_Failure))
+	require.ErrorIs(err, ErrReverted)
+	require.Contains(err.Error(), "status 0 Failure")
+}
@@ -0,0 +1,683 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/iotexproject/go-pkgs/hash"
+	"github.com/iotexproject/iotex-address/address"
+	"github.com/iotexproject/iotex-proto/golang/iotextypes"
+	"google.golang.org/protobuf/proto"
+)
+
+// FakeCall is the context of a contract execution on a Fake chain
+type FakeCall struct {
+	Fake     *Fake
+	Contract address.Address
+	Sender   address.Address
+	Amount   *big.Int
+}
+
+// Gas consumed by actions on a Fake chain
+const (
+	// FakeActionGas is the gas of transfers, deposits and reward claims
+	FakeActionGas uint64 = 10000
+	// FakeExecutionGas is the gas of a contract execution, unless the
+	// contract implements FakeGasMeter
+	FakeExecutionGas uint64 = 50000
+)
+
+// FakeGasPrice is the gas price suggested by a new Fake chain
+const FakeGasPrice = 1000000000000
+
+// FakeGasMeter is implemented by fake contracts whose gas depends on the call
+type FakeGasMeter interface {
+	Gas(method string, args []interface{}) uint64
+}
+
+// FakeContract is a contract living in a Fake chain
+type FakeContract interface {
+	// Read returns the outputs of a view method
+	Read(method string, args []interface{}) ([]interface{}, error)
+	// Execute runs a method and returns the receipt status
+	Execute(call *FakeCall, method string, args []interface{}) iotextypes.ReceiptStatus
+}
+
+type fakeContract struct {
+	abi      abi.ABI
+	contract FakeContract
+}
+
+// Fake is an in-memory chain for tests. It models balances, nonces, receipts,
+// staking buckets, candidates and contracts. Every action is minted at once.
+type Fake struct {
+	mu         sync.Mutex
+	meta       *iotextypes.ChainMeta
+	balances   map[string]*big.Int
+	unclaimed  map[string]*big.Int
+	nonces     map[string]uint64
+	receipts   map[hash.Hash256]*iotextypes.Receipt
+	actions    map[hash.Hash256]*iotextypes.ActionCore
+	hidden     map[hash.Hash256]int
+	buckets    map[uint64]*iotextypes.VoteBucket
+	candidates map[string]*iotextypes.CandidateV2
+	contracts  map[string]*fakeContract
+	gasPrice   *big.Int
+
+	nextStatus []iotextypes.ReceiptStatus
+	nextErr    []error
+	nextHidden []int
+}
+
+// NewFake returns an empty fake chain at epoch 1
+func NewFake() *Fake {
+	return &Fake{
+		meta:       &iotextypes.ChainMeta{Height: 1, Epoch: &iotextypes.EpochData{Num: 1}},
+		balances:   make(map[string]*big.Int),
+		unclaimed:  make(map[string]*big.Int),
+		nonces:     make(map[string]uint64),
+		receipts:   make(map[hash.Hash256]*iotextypes.Receipt),
+		actions:    make(map[hash.Hash256]*iotextypes.ActionCore),
+		hidden:     make(map[hash.Hash256]int),
+		buckets:    make(map[uint64]*iotextypes.VoteBucket),
+		candidates: make(map[string]*iotextypes.CandidateV2),
+		contracts:  make(map[string]*fakeContract),
+		gasPrice:   big.NewInt(FakeGasPrice),
+	}
+}
+
+// Client returns a client sending actions from addr
+func (f *Fake) Client(addr address.Address) Client {
+	return &fakeClient{fake: f, addr: addr}
+}
+
+// SetEpoch sets the current epoch number
+func (f *Fake) SetEpoch(epoch uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.meta.Epoch.Num = epoch
+}
+
+// SetGasPrice sets the gas price suggested by the chain
+func (f *Fake) SetGasPrice(price *big.Int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.gasPrice = new(big.Int).Set(price)
+}
+
+// SetBalance sets the balance of addr
+func (f *Fake) SetBalance(addr address.Address, balance *big.Int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.balances[addr.String()] = new(big.Int).Set(balance)
+}
+
+// BalanceOf returns the balance of addr
+func (f *Fake) BalanceOf(addr address.Address) *big.Int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return new(big.Int).Set(f.balance(addr))
+}
+
+// SetUnclaimed sets the unclaimed rewards of addr
+func (f *Fake) SetUnclaimed(addr address.Address, amount *big.Int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.unclaimed[addr.String()] = new(big.Int).Set(amount)
+}
+
+// Nonce returns the nonce of the last action of addr, nonces start at 1 so it
+// is also the number of actions sent
+func (f *Fake) Nonce(addr address.Address) uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.nonces[addr.String()]
+}
+
+// SetBucket adds or replaces a staking bucket
+func (f *Fake) SetBucket(bucket *iotextypes.VoteBucket) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.buckets[bucket.Index] = proto.Clone(bucket).(*iotextypes.VoteBucket)
+}
+
+// BucketOf returns a copy of the staking bucket at index, nil if it doesn't exist
+func (f *Fake) BucketOf(index uint64) *iotextypes.VoteBucket {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	bucket, ok := f.buckets[index]
+	if !ok {
+		return nil
+	}
+	return proto.Clone(bucket).(*iotextypes.VoteBucket)
+}
+
+// SetCandidate adds or replaces a candidate
+func (f *Fake) SetCandidate(candidate *iotextypes.CandidateV2) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.candidates[candidate.Name] = proto.Clone(candidate).(*iotextypes.CandidateV2)
+}
+
+// Deploy places contract at addr
+func (f *Fake) Deploy(addr address.Address, contractABI abi.ABI, contract FakeContract) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.contracts[addr.String()] = &fakeContract{abi: contractABI, contract: contract}
+}
+
+// FailNext makes the next sent action mint with status
+func (f *Fake) FailNext(status iotextypes.ReceiptStatus) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextStatus = append(f.nextStatus, status)
+}
+
+// RejectNext makes the node reject the next sent action with err
+func (f *Fake) RejectNext(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextErr = append(f.nextErr, err)
+}
+
+// DelayNext hides the receipt of the next sent action for the given number of
+// queries, a negative number hides it forever
+func (f *Fake) DelayNext(queries int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextHidden = append(f.nextHidden, queries)
+}
+
+// Action returns the core of a sent action, nil if it was never sent
+func (f *Fake) Action(h hash.Hash256) *iotextypes.ActionCore {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.actions[h]
+}
+
+// Transfer moves amount from one address to another, it returns false if from
+// can't afford it. It must only be called by FakeContract.Execute.
+func (f *Fake) Transfer(from, to address.Address, amount *big.Int) bool {
+	balance := f.balance(from)
+	if balance.Cmp(amount) < 0 {
+		return false
+	}
+	balance.Sub(balance, amount)
+	f.balance(to).Add(f.balance(to), amount)
+	return true
+}
+
+func (f *Fake) balance(addr address.Address) *big.Int {
+	balance, ok := f.balances[addr.String()]
+	if !ok {
+		balance = big.NewInt(0)
+		f.balances[addr.String()] = balance
+	}
+	return balance
+}
+
+// actionHash is the hash of the action core of sender, the gas limit a send
+// fills in is left out so a signed action keeps its hash
+func actionHash(sender address.Address, core *iotextypes.ActionCore) hash.Hash256 {
+	core = proto.Clone(core).(*iotextypes.ActionCore)
+	core.GasLimit = 0
+	data, _ := proto.Marshal(core)
+	return hash.Hash256b(append(sender.Bytes(), data...))
+}
+
+// send charges the sender, mints the action and runs apply to get its status.
+// The action consumes gas, or runs out of gas if its limit is lower.
+func (f *Fake) send(sender address.Address, core *iotextypes.ActionCore, value *big.Int, gas uint64, apply func() iotextypes.ReceiptStatus) (hash.Hash256, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.nextErr) > 0 {
+		err := f.nextErr[0]
+		f.nextErr = f.nextErr[1:]
+		return hash.ZeroHash256, Classify(err)
+	}
+	next := f.nonces[sender.String()] + 1
+	switch {
+	case core.Nonce == 0:
+		core.Nonce = next
+	case core.Nonce < next:
+		return hash.ZeroHash256, Classify(ErrNonceTooLow)
+	case core.Nonce > next:
+		return hash.ZeroHash256, Classify(ErrNonceTooHigh)
+	}
+	if core.GasLimit == 0 {
+		core.GasLimit = gas
+	}
+	gasPrice, ok := new(big.Int).SetString(core.GasPrice, 10)
+	if !ok {
+		gasPrice = big.NewInt(0)
+	}
+	maxFee := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(core.GasLimit))
+	balance := f.balance(sender)
+	if balance.Cmp(new(big.Int).Add(maxFee, value)) < 0 {
+		return hash.ZeroHash256, ErrInsufficientFunds
+	}
+	consumed := gas
+	if core.GasLimit < gas {
+		consumed = core.GasLimit
+	}
+	balance.Sub(balance, new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(consumed)))
+
+	f.nonces[sender.String()] = core.Nonce
+	h := actionHash(sender, core)
+	f.actions[h] = core
+
+	var status iotextypes.ReceiptStatus
+	switch {
+	case len(f.nextStatus) > 0:
+		status = f.nextStatus[0]
+		f.nextStatus = f.nextStatus[1:]
+	case core.GasLimit < gas:
+		status = iotextypes.ReceiptStatus_ErrOutOfGas
+	default:
+		status = apply()
+	}
+	if len(f.nextHidden) > 0 {
+		f.hidden[h] = f.nextHidden[0]
+		f.nextHidden = f.nextHidden[1:]
+	}
+	f.meta.Height++
+	f.receipts[h] = &iotextypes.Receipt{
+		Status:      uint64(status),
+		BlkHeight:   f.meta.Height,
+		ActHash:     h[:],
+		GasConsumed: consumed,
+	}
+	return h, nil
+}
+
+type fakeClient struct {
+	fake *Fake
+	addr address.Address
+}
+
+func (c *fakeClient) Address() address.Address {
+	return c.addr
+}
+
+func (c *fakeClient) PendingNonce(ctx context.Context) (uint64, error) {
+	return c.fake.Nonce(c.addr) + 1, nil
+}
+
+func (c *fakeClient) ConfirmedNonce(ctx context.Context, addr address.Address) (uint64, error) {
+	return c.fake.Nonce(addr), nil
+}
+
+func (c *fakeClient) ChainMeta(ctx context.Context) (*iotextypes.ChainMeta, error) {
+	c.fake.mu.Lock()
+	defer c.fake.mu.Unlock()
+	return proto.Clone(c.fake.meta).(*iotextypes.ChainMeta), nil
+}
+
+func (c *fakeClient) Balance(ctx context.Context, addr address.Address) (*big.Int, error) {
+	return c.fake.BalanceOf(addr), nil
+}
+
+func (c *fakeClient) UnclaimedBalance(ctx context.Context, addr address.Address) (*big.Int, error) {
+	c.fake.mu.Lock()
+	defer c.fake.mu.Unlock()
+	if v, ok := c.fake.unclaimed[addr.String()]; ok {
+		return new(big.Int).Set(v), nil
+	}
+	return big.NewInt(0), nil
+}
+
+func (c *fakeClient) Candidate(ctx context.Context, name string) (*iotextypes.CandidateV2, error) {
+	c.fake.mu.Lock()
+	defer c.fake.mu.Unlock()
+	candidate, ok := c.fake.candidates[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return proto.Clone(candidate).(*iotextypes.CandidateV2), nil
+}
+
+func (c *fakeClient) Bucket(ctx context.Context, index uint64) (*iotextypes.VoteBucket, error) {
+	bucket := c.fake.BucketOf(index)
+	if bucket == nil {
+		return nil, ErrNotFound
+	}
+	return bucket, nil
+}
+
+func (c *fakeClient) contract(contract address.Address) (*fakeContract, error) {
+	fc, ok := c.fake.contracts[contract.String()]
+	if !ok {
+		return nil, fmt.Errorf("contract %s is not deployed", contract.String())
+	}
+	return fc, nil
+}
+
+func (c *fakeClient) ReadContract(ctx context.Context, contract address.Address, contractABI abi.ABI, method string, args ...interface{}) ([]interface{}, error) {
+	c.fake.mu.Lock()
+	defer c.fake.mu.Unlock()
+	fc, err := c.contract(contract)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := contractABI.Methods[method]
+	if !ok {
+		return nil, fmt.Errorf("method %s not found", method)
+	}
+	// round trip through the ABI so callers get the same types as from a node
+	data, err := contractABI.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	inputs, err := m.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := fc.contract.Read(method, inputs)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := m.Outputs.Pack(outputs...)
+	if err != nil {
+		return nil, err
+	}
+	return m.Outputs.Unpack(raw)
+}
+
+func core(opts Opts) *iotextypes.ActionCore {
+	core := &iotextypes.ActionCore{
+		Version:  1,
+		Nonce:    opts.Nonce,
+		GasLimit: opts.GasLimit,
+		GasPrice: "0",
+		ChainID:  1,
+	}
+	if opts.GasPrice != nil {
+		core.GasPrice = opts.GasPrice.String()
+	}
+	return core
+}
+
+func amountOrZero(amount *big.Int) *big.Int {
+	if amount == nil {
+		return big.NewInt(0)
+	}
+	return amount
+}
+
+// executionGas returns the gas of a contract execution
+func (f *Fake) executionGas(contract address.Address, method string, args []interface{}) uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if fc, ok := f.contracts[contract.String()]; ok {
+		if meter, ok := fc.contract.(FakeGasMeter); ok {
+			return meter.Gas(method, args)
+		}
+	}
+	return FakeExecutionGas
+}
+
+func (c *fakeClient) EstimateExecution(ctx context.Context, contract address.Address, contractABI abi.ABI, amount *big.Int, method string, args ...interface{}) (uint64, error) {
+	data, err := contractABI.Pack(method, args...)
+	if err != nil {
+		return 0, err
+	}
+	inputs, err := contractABI.Methods[method].Inputs.Unpack(data[4:])
+	if err != nil {
+		return 0, err
+	}
+	c.fake.mu.Lock()
+	_, err = c.contract(contract)
+	c.fake.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	return c.fake.executionGas(contract, method, inputs), nil
+}
+
+func (c *fakeClient) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	c.fake.mu.Lock()
+	defer c.fake.mu.Unlock()
+	return new(big.Int).Set(c.fake.gasPrice), nil
+}
+
+func (c *fakeClient) EstimateTransfer(ctx context.Context, to address.Address, amount *big.Int) (uint64, error) {
+	return FakeActionGas, nil
+}
+
+func (c *fakeClient) EstimateAddDeposit(ctx context.Context, bucket uint64, amount *big.Int) (uint64, error) {
+	return FakeActionGas, nil
+}
+
+func (c *fakeClient) ExecuteContract(ctx context.Context, contract address.Address, contractABI abi.ABI, opts Opts, method string, args ...interface{}) (hash.Hash256, error) {
+	act, err := execution(contractABI, opts, contract, method, args)
+	if err != nil {
+		return hash.ZeroHash256, err
+	}
+	inputs, err := contractABI.Methods[method].Inputs.Unpack(act.GetExecution().Data[4:])
+	if err != nil {
+		return hash.ZeroHash256, err
+	}
+	return c.execute(act, contract, method, inputs)
+}
+
+// execution builds the core of a contract execution
+func execution(contractABI abi.ABI, opts Opts, contract address.Address, method string, args []interface{}) (*iotextypes.ActionCore, error) {
+	data, err := contractABI.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	act := core(opts)
+	act.Action = &iotextypes.ActionCore_Execution{Execution: &iotextypes.Execution{
+		Amount:   amountOrZero(opts.Amount).String(),
+		Contract: contract.String(),
+		Data:     data,
+	}}
+	return act, nil
+}
+
+// sendExecution sends a signed contract execution, its method is found by the
+// ABI the contract is deployed with
+func (c *fakeClient) sendExecution(act *iotextypes.ActionCore) (hash.Hash256, error) {
+	contract, err := address.FromString(act.GetExecution().Contract)
+	if err != nil {
+		return hash.ZeroHash256, err
+	}
+	c.fake.mu.Lock()
+	fc, err := c.contract(contract)
+	c.fake.mu.Unlock()
+	if err != nil {
+		return hash.ZeroHash256, err
+	}
+	data := act.GetExecution().Data
+	if len(data) < 4 {
+		return hash.ZeroHash256, fmt.Errorf("execution of %s has no method", contract.String())
+	}
+	m, err := fc.abi.MethodById(data[:4])
+	if err != nil {
+		return hash.ZeroHash256, err
+	}
+	inputs, err := m.Inputs.Unpack(data[4:])
+	if err != nil {
+		return hash.ZeroHash256, err
+	}
+	return c.execute(act, contract, m.Name, inputs)
+}
+
+// execute sends a contract execution of method with inputs
+func (c *fakeClient) execute(act *iotextypes.ActionCore, contract address.Address, method string, inputs []interface{}) (hash.Hash256, error) {
+	amount, ok := new(big.Int).SetString(act.GetExecution().Amount, 10)
+	if !ok {
+		amount = big.NewInt(0)
+	}
+	return c.fake.send(c.addr, act, amount, c.fake.executionGas(contract, method, inputs), func() iotextypes.ReceiptStatus {
+		fc, err := c.contract(contract)
+		if err != nil {
+			return iotextypes.ReceiptStatus_Failure
+		}
+		if !c.fake.Transfer(c.addr, contract, amount) {
+			return iotextypes.ReceiptStatus_ErrInsufficientBalance
+		}
+		status := fc.contract.Execute(&FakeCall{
+			Fake:     c.fake,
+			Contract: contract,
+			Sender:   c.addr,
+			Amount:   amount,
+		}, method, inputs)
+		if status != iotextypes.ReceiptStatus_Success {
+			c.fake.Transfer(contract, c.addr, amount)
+		}
+		return status
+	})
+}
+
+func (c *fakeClient) Transfer(ctx context.Context, to address.Address, amount *big.Int, opts Opts) (hash.Hash256, error) {
+	act := core(opts)
+	act.Action = &iotextypes.ActionCore_Transfer{Transfer: &iotextypes.Transfer{
+		Amount:    amount.String(),
+		Recipient: to.String(),
+	}}
+	return c.transfer(act, to, amount)
+}
+
+func (c *fakeClient) transfer(act *iotextypes.ActionCore, to address.Address, amount *big.Int) (hash.Hash256, error) {
+	return c.fake.send(c.addr, act, amount, FakeActionGas, func() iotextypes.ReceiptStatus {
+		c.fake.Transfer(c.addr, to, amount)
+		return iotextypes.ReceiptStatus_Success
+	})
+}
+
+func (c *fakeClient) AddDeposit(ctx context.Context, index uint64, amount *big.Int, opts Opts) (hash.Hash256, error) {
+	act := core(opts)
+	act.Action = &iotextypes.ActionCore_StakeAddDeposit{StakeAddDeposit: &iotextypes.StakeAddDeposit{
+		BucketIndex: index,
+		Amount:      amount.String(),
+	}}
+	return c.addDeposit(act, index, amount)
+}
+
+func (c *fakeClient) addDeposit(act *iotextypes.ActionCore, index uint64, amount *big.Int) (hash.Hash256, error) {
+	return c.fake.send(c.addr, act, amount, FakeActionGas, func() iotextypes.ReceiptStatus {
+		bucket, ok := c.fake.buckets[index]
+		if !ok {
+			return iotextypes.ReceiptStatus_ErrInvalidBucketIndex
+		}
+		if !bucket.AutoStake {
+			return iotextypes.ReceiptStatus_ErrInvalidBucketType
+		}
+		staked, _ := new(big.Int).SetString(bucket.StakedAmount, 10)
+		if staked == nil {
+			staked = big.NewInt(0)
+		}
+		c.fake.balance(c.addr).Sub(c.fake.balance(c.addr), amount)
+		bucket.StakedAmount = staked.Add(staked, amount).String()
+		return iotextypes.ReceiptStatus_Success
+	})
+}
+
+func (c *fakeClient) SignTransfer(ctx context.Context, to address.Address, amount *big.Int, opts Opts) (*SignedAction, error) {
+	act := core(opts)
+	act.Action = &iotextypes.ActionCore_Transfer{Transfer: &iotextypes.Transfer{
+		Amount:    amount.String(),
+		Recipient: to.String(),
+	}}
+	return c.sign(act), nil
+}
+
+func (c *fakeClient) SignExecution(ctx context.Context, contract address.Address, contractABI abi.ABI, opts Opts, method string, args ...interface{}) (*SignedAction, error) {
+	act, err := execution(contractABI, opts, contract, method, args)
+	if err != nil {
+		return nil, err
+	}
+	return c.sign(act), nil
+}
+
+func (c *fakeClient) SignAddDeposit(ctx context.Context, bucket uint64, amount *big.Int, opts Opts) (*SignedAction, error) {
+	act := core(opts)
+	act.Action = &iotextypes.ActionCore_StakeAddDeposit{StakeAddDeposit: &iotextypes.StakeAddDeposit{
+		BucketIndex: bucket,
+		Amount:      amount.String(),
+	}}
+	return c.sign(act), nil
+}
+
+// sign pins the nonce of act, its hash is the one send gives it
+func (c *fakeClient) sign(act *iotextypes.ActionCore) *SignedAction {
+	if act.Nonce == 0 {
+		act.Nonce = c.fake.Nonce(c.addr) + 1
+	}
+	return &SignedAction{
+		Action: &iotextypes.Action{Core: act},
+		Hash:   actionHash(c.addr, act),
+	}
+}
+
+func (c *fakeClient) SendAction(ctx context.Context, act *SignedAction) error {
+	if h := actionHash(c.addr, act.Action.Core); h != act.Hash {
+		return fmt.Errorf("action hash is %x instead of %x", h, act.Hash)
+	}
+	core := proto.Clone(act.Action.Core).(*iotextypes.ActionCore)
+	var err error
+	switch {
+	case core.GetTransfer() != nil:
+		to, e := address.FromString(core.GetTransfer().Recipient)
+		if e != nil {
+			return e
+		}
+		amount, _ := new(big.Int).SetString(core.GetTransfer().Amount, 10)
+		_, err = c.transfer(core, to, amount)
+	case core.GetStakeAddDeposit() != nil:
+		amount, _ := new(big.Int).SetString(core.GetStakeAddDeposit().Amount, 10)
+		_, err = c.addDeposit(core, core.GetStakeAddDeposit().BucketIndex, amount)
+	case core.GetExecution() != nil:
+		_, err = c.sendExecution(core)
+	default:
+		err = fmt.Errorf("fake chain doesn't send %T", core.Action)
+	}
+	return err
+}
+
+func (c *fakeClient) ClaimReward(ctx context.Context, amount *big.Int, opts Opts) (hash.Hash256, error) {
+	act := core(opts)
+	act.Action = &iotextypes.ActionCore_ClaimFromRewardingFund{ClaimFromRewardingFund: &iotextypes.ClaimFromRewardingFund{
+		Amount: amount.String(),
+	}}
+	return c.fake.send(c.addr, act, big.NewInt(0), FakeActionGas, func() iotextypes.ReceiptStatus {
+		unclaimed, ok := c.fake.unclaimed[c.addr.String()]
+		if !ok || unclaimed.Cmp(amount) < 0 {
+			return iotextypes.ReceiptStatus_Failure
+		}
+		unclaimed.Sub(unclaimed, amount)
+		c.fake.balance(c.addr).Add(c.fake.balance(c.addr), amount)
+		return iotextypes.ReceiptStatus_Success
+	})
+}
+
+func (c *fakeClient) Receipt(ctx context.Context, h hash.Hash256) (*iotextypes.Receipt, error) {
+	c.fake.mu.Lock()
+	defer c.fake.mu.Unlock()
+	receipt, ok := c.fake.receipts[h]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if hidden, ok := c.fake.hidden[h]; ok && hidden != 0 {
+		if hidden > 0 {
+			c.fake.hidden[h] = hidden - 1
+		}
+		return nil, ErrNotFound
+	}
+	return proto.Clone(receipt).(*iotextypes.Receipt), nil
+}
+
+func (c *fakeClient) Action(ctx context.Context, h hash.Hash256) (*iotextypes.ActionCore, error) {
+	c.fake.mu.Lock()
+	defer c.fake.mu.Unlock()
+	core, ok := c.fake.actions[h]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return proto.Clone(core).(*iotextypes.ActionCore), nil
+}
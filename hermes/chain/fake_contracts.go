@@ -0,0 +1,127 @@
+package chain
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/iotexproject/iotex-address/address"
+	"github.com/iotexproject/iotex-proto/golang/iotextypes"
+)
+
+// FakeHermes models the Hermes distribution contract
+type FakeHermes struct {
+	StartEpoch       uint64
+	EndEpochs        []uint64
+	DistributedCount map[[32]byte]uint64
+	// Distributed records every recipient paid by distributeRewards
+	Distributed map[[32]byte][]common.Address
+}
+
+// NewFakeHermes returns a Hermes contract without any distribution
+func NewFakeHermes(startEpoch uint64) *FakeHermes {
+	return &FakeHermes{
+		StartEpoch:       startEpoch,
+		DistributedCount: make(map[[32]byte]uint64),
+		Distributed:      make(map[[32]byte][]common.Address),
+	}
+}
+
+func (h *FakeHermes) Read(method string, args []interface{}) ([]interface{}, error) {
+	switch method {
+	case "contractStartEpoch":
+		return []interface{}{new(big.Int).SetUint64(h.StartEpoch)}, nil
+	case "getEndEpochCount":
+		return []interface{}{big.NewInt(int64(len(h.EndEpochs)))}, nil
+	case "endEpochs":
+		i := args[0].(*big.Int)
+		if !i.IsUint64() || i.Uint64() >= uint64(len(h.EndEpochs)) {
+			return nil, fmt.Errorf("execution reverted")
+		}
+		return []interface{}{new(big.Int).SetUint64(h.EndEpochs[i.Uint64()])}, nil
+	case "distributedCount":
+		return []interface{}{new(big.Int).SetUint64(h.DistributedCount[args[0].([32]byte)])}, nil
+	}
+	return nil, fmt.Errorf("fake hermes doesn't support %s", method)
+}
+
+// Gas charges distributeRewards per recipient
+func (h *FakeHermes) Gas(method string, args []interface{}) uint64 {
+	if method == "distributeRewards" {
+		return FakeExecutionGas + 25000*uint64(len(args[2].([]common.Address)))
+	}
+	return FakeExecutionGas
+}
+
+func (h *FakeHermes) Execute(call *FakeCall, method string, args []interface{}) iotextypes.ReceiptStatus {
+	switch method {
+	case "distributeRewards":
+		name := args[0].([32]byte)
+		recipients := args[2].([]common.Address)
+		amounts := args[3].([]*big.Int)
+		if len(recipients) != len(amounts) {
+			return iotextypes.ReceiptStatus_ErrExecutionReverted
+		}
+		total := big.NewInt(0)
+		for _, v := range amounts {
+			total.Add(total, v)
+		}
+		if call.Amount.Cmp(total) < 0 {
+			return iotextypes.ReceiptStatus_ErrExecutionReverted
+		}
+		for i, r := range recipients {
+			to, _ := address.FromBytes(r.Bytes())
+			call.Fake.Transfer(call.Contract, to, amounts[i])
+		}
+		h.DistributedCount[name] += uint64(len(recipients))
+		h.Distributed[name] = append(h.Distributed[name], recipients...)
+		return iotextypes.ReceiptStatus_Success
+	case "commitDistributions":
+		endEpoch := args[0].(*big.Int).Uint64()
+		if len(h.EndEpochs) > 0 && h.EndEpochs[len(h.EndEpochs)-1] >= endEpoch {
+			return iotextypes.ReceiptStatus_ErrExecutionReverted
+		}
+		for _, name := range args[1].([][32]byte) {
+			delete(h.DistributedCount, name)
+		}
+		h.EndEpochs = append(h.EndEpochs, endEpoch)
+		return iotextypes.ReceiptStatus_Success
+	}
+	return iotextypes.ReceiptStatus_ErrExecutionReverted
+}
+
+// FakeMultisend models the multisend contract read by the distribution
+type FakeMultisend struct {
+	MinTips *big.Int
+}
+
+func (m *FakeMultisend) Read(method string, args []interface{}) ([]interface{}, error) {
+	if method == "minTips" {
+		return []interface{}{m.MinTips}, nil
+	}
+	return nil, fmt.Errorf("fake multisend doesn't support %s", method)
+}
+
+func (m *FakeMultisend) Execute(call *FakeCall, method string, args []interface{}) iotextypes.ReceiptStatus {
+	return iotextypes.ReceiptStatus_ErrExecutionReverted
+}
+
+// FakeAutoDeposit models the auto deposit registry of voter buckets
+type FakeAutoDeposit struct {
+	Buckets map[common.Address]int64
+}
+
+func (a *FakeAutoDeposit) Read(method string, args []interface{}) ([]interface{}, error) {
+	if method != "bucket" && method != "buckets" {
+		return nil, fmt.Errorf("fake auto deposit doesn't support %s", method)
+	}
+	bucket, ok := a.Buckets[args[0].(common.Address)]
+	if !ok {
+		bucket = -1
+	}
+	return []interface{}{big.NewInt(bucket)}, nil
+}
+
+func (a *FakeAutoDeposit) Execute(call *FakeCall, method string, args []interface{}) iotextypes.ReceiptStatus {
+	return iotextypes.ReceiptStatus_ErrExecutionReverted
+}
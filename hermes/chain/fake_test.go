@@ -0,0 +1,106 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/iotexproject/iotex-antenna-go/v2/account"
+	"github.com/iotexproject/iotex-proto/golang/iotextypes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeTransfer(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	sender, err := account.NewAccount()
+	require.NoError(err)
+	recipient, err := account.NewAccount()
+	require.NoError(err)
+
+	fake := NewFake()
+	fake.SetBalance(sender.Address(), big.NewInt(100000))
+	c := fake.Client(sender.Address())
+	opts := Opts{GasPrice: big.NewInt(1), GasLimit: 2 * FakeActionGas}
+
+	// only the consumed gas is charged
+	h, err := c.Transfer(ctx, recipient.Address(), big.NewInt(500), opts)
+	require.NoError(err)
+	receipt, err := CheckReceipt(ctx, c, h, Polling{Attempts: 1})
+	require.NoError(err)
+	require.Equal(FakeActionGas, receipt.GasConsumed)
+	require.Equal(big.NewInt(89500), fake.BalanceOf(sender.Address()))
+	require.Equal(big.NewInt(500), fake.BalanceOf(recipient.Address()))
+	require.Equal(uint64(1), fake.Nonce(sender.Address()))
+
+	// gas limit * price + value exceeds the balance
+	_, err = c.Transfer(ctx, recipient.Address(), big.NewInt(80000), opts)
+	require.Equal(ErrInsufficientFunds, err)
+	require.Equal(uint64(1), fake.Nonce(sender.Address()))
+
+	// the whole gas limit is consumed when it is too low
+	h, err = c.Transfer(ctx, recipient.Address(), big.NewInt(1), Opts{GasPrice: big.NewInt(1), GasLimit: 100})
+	require.NoError(err)
+	receipt, err = CheckReceipt(ctx, c, h, Polling{Attempts: 1})
+	require.Error(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_ErrOutOfGas), receipt.Status)
+	require.Equal(uint64(100), receipt.GasConsumed)
+	require.Equal(big.NewInt(89400), fake.BalanceOf(sender.Address()))
+
+	rejected := errors.New("rejected")
+	fake.RejectNext(rejected)
+	_, err = c.Transfer(ctx, recipient.Address(), big.NewInt(1), opts)
+	require.Equal(rejected, err)
+
+	fake.FailNext(iotextypes.ReceiptStatus_ErrExecutionReverted)
+	h, err = c.Transfer(ctx, recipient.Address(), big.NewInt(1), opts)
+	require.NoError(err)
+	_, err = CheckReceipt(ctx, c, h, Polling{Attempts: 1})
+	require.Error(err)
+
+	fake.DelayNext(2)
+	h, err = c.Transfer(ctx, recipient.Address(), big.NewInt(1), opts)
+	require.NoError(err)
+	_, err = WaitReceipt(ctx, c, h, Polling{Attempts: 2})
+	require.Equal(ErrNotFound, err)
+	receipt, err = WaitReceipt(ctx, c, h, Polling{Attempts: 1})
+	require.NoError(err)
+	require.Equal(uint64(iotextypes.ReceiptStatus_Success), receipt.Status)
+
+	// a canceled wait returns before its delay
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = WaitReceipt(canceled, c, h, Polling{Delay: time.Hour, Attempts: 1})
+	require.Equal(context.Canceled, err)
+}
+
+func TestFakeAddDeposit(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	sender, err := account.NewAccount()
+	require.NoError(err)
+	fake := NewFake()
+	fake.SetBalance(sender.Address(), big.NewInt(1000))
+	fake.SetBucket(&iotextypes.VoteBucket{Index: 1, AutoStake: true, StakedAmount: "10"})
+	fake.SetBucket(&iotextypes.VoteBucket{Index: 2})
+	c := fake.Client(sender.Address())
+
+	statuses := map[uint64]iotextypes.ReceiptStatus{
+		1: iotextypes.ReceiptStatus_Success,
+		2: iotextypes.ReceiptStatus_ErrInvalidBucketType,
+		3: iotextypes.ReceiptStatus_ErrInvalidBucketIndex,
+	}
+	for index, status := range statuses {
+		h, err := c.AddDeposit(ctx, index, big.NewInt(100), Opts{})
+		require.NoError(err)
+		receipt, err := WaitReceipt(ctx, c, h, Polling{Attempts: 1})
+		require.NoError(err)
+		require.Equal(uint64(status), receipt.Status)
+	}
+	require.Equal("110", fake.BucketOf(1).StakedAmount)
+	require.Equal(big.NewInt(900), fake.BalanceOf(sender.Address()))
+}
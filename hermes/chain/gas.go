@@ -0,0 +1,36 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+// ErrGasPriceTooHigh is returned when the gas price exceeds the configured ceiling
+var ErrGasPriceTooHigh = errors.New("gas price exceeds ceiling")
+
+// GasPrice returns the gas price suggested by the node, scaled by the
+// multiplier and raised to the floor of cfg. It fails with ErrGasPriceTooHigh
+// when the price is above the ceiling, so nothing is sent at that price.
+func GasPrice(ctx context.Context, c Client, cfg config.Gas) (*big.Int, error) {
+	suggested, err := c.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("suggest gas price error: %v", err)
+	}
+	multiplier := cfg.Multiplier
+	if multiplier == 0 {
+		multiplier = 100
+	}
+	price := new(big.Int).Mul(suggested, big.NewInt(int64(multiplier)))
+	price.Quo(price, big.NewInt(100))
+	if floor := cfg.Price.Int(); floor != nil && price.Cmp(floor) < 0 {
+		price = floor
+	}
+	if ceiling := cfg.Ceiling.Int(); ceiling != nil && price.Cmp(ceiling) > 0 {
+		return nil, fmt.Errorf("%w: %s > %s", ErrGasPriceTooHigh, price.String(), ceiling.String())
+	}
+	return price, nil
+}
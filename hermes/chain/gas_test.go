@@ -0,0 +1,49 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/iotexproject/iotex-antenna-go/v2/account"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+func TestGasPrice(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	acc, err := account.NewAccount()
+	require.NoError(err)
+	fake := NewFake()
+	c := fake.Client(acc.Address())
+	cfg := config.Gas{
+		Price:      config.NewAmount(big.NewInt(1000)),
+		Ceiling:    config.NewAmount(big.NewInt(5000)),
+		Multiplier: 150,
+	}
+
+	fake.SetGasPrice(big.NewInt(2000))
+	price, err := GasPrice(ctx, c, cfg)
+	require.NoError(err)
+	require.Equal(big.NewInt(3000), price)
+
+	// raised to the floor
+	fake.SetGasPrice(big.NewInt(100))
+	price, err = GasPrice(ctx, c, cfg)
+	require.NoError(err)
+	require.Equal(big.NewInt(1000), price)
+
+	// refused above the ceiling
+	fake.SetGasPrice(big.NewInt(4000))
+	_, err = GasPrice(ctx, c, cfg)
+	require.True(errors.Is(err, ErrGasPriceTooHigh))
+
+	// no ceiling and the default multiplier
+	price, err = GasPrice(ctx, c, config.Gas{Price: config.NewAmount(big.NewInt(1000))})
+	require.NoError(err)
+	require.Equal(big.NewInt(4000), price)
+}
@@ -0,0 +1,66 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/iotexproject/go-pkgs/hash"
+	"github.com/iotexproject/iotex-proto/golang/iotextypes"
+
+	"github.com/ququzone/hermes-patch/hermes/metrics"
+	"github.com/ququzone/hermes-patch/hermes/util"
+)
+
+// Polling controls how a receipt is waited for
+type Polling struct {
+	Delay    time.Duration
+	Interval time.Duration
+	Attempts int
+}
+
+// DefaultPolling waits up to about four minutes for a receipt
+var DefaultPolling = Polling{
+	Delay:    5 * time.Second,
+	Interval: 2 * time.Second,
+	Attempts: 120,
+}
+
+// WaitReceipt polls the receipt of h until it is minted, it returns ErrNotFound
+// once the attempts are exhausted. Retryable errors of the node use an attempt.
+// It returns the error of ctx when ctx is done first.
+func WaitReceipt(ctx context.Context, c Client, h hash.Hash256, p Polling) (*iotextypes.Receipt, error) {
+	defer metrics.Since(metrics.ReceiptWait, time.Now())
+	if err := util.Sleep(ctx, p.Delay); err != nil {
+		return nil, err
+	}
+	for i := 0; i < p.Attempts; i++ {
+		receipt, err := c.Receipt(ctx, h)
+		if err == ErrNotFound || errors.Is(err, ErrRetryable) {
+			if err := util.Sleep(ctx, p.Interval); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return receipt, nil
+	}
+	return nil, ErrNotFound
+}
+
+// CheckReceipt waits for the receipt of h and checks that the action succeeded
+// with ReceiptError, the receipt is returned whenever it is found
+func CheckReceipt(ctx context.Context, c Client, h hash.Hash256, p Polling) (*iotextypes.Receipt, error) {
+	receipt, err := WaitReceipt(ctx, c, h, p)
+	if err == ErrNotFound {
+		fmt.Printf("action %x check receipt not found\n", h)
+		return nil, fmt.Errorf("action %x receipt not found", h)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return receipt, ReceiptError(h, receipt)
+}
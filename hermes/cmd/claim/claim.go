@@ -8,20 +8,14 @@ package claim
 
 import (
 	"context"
-	"encoding/hex"
 	"fmt"
 	"math/big"
-	"strings"
-	"time"
 
-	"github.com/iotexproject/go-pkgs/hash"
-	"github.com/iotexproject/iotex-antenna-go/v2/iotex"
-	"github.com/iotexproject/iotex-proto/golang/iotexapi"
-	"github.com/iotexproject/iotex-proto/golang/protocol"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
-	"google.golang.org/grpc"
 
+	"github.com/ququzone/hermes-patch/hermes/chain"
+	"github.com/ququzone/hermes-patch/hermes/config"
 	"github.com/ququzone/hermes-patch/hermes/util"
 )
 
@@ -32,6 +26,9 @@ var ClaimCmd = &cobra.Command{
 	Args:  cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cmd.SilenceUsage = true
+		if err := config.Init(""); err != nil {
+			return err
+		}
 		_, err := Reward()
 		return err
 	},
@@ -39,38 +36,31 @@ var ClaimCmd = &cobra.Command{
 
 // Reward is claim reward from contract
 func Reward() (*big.Int, error) {
-	pwd := util.MustFetchNonEmptyParam("VAULT_PASSWORD")
+	cfg := config.Get()
+	pwd := cfg.Vault.Password
+	if pwd == "" {
+		return nil, errors.New("vault password is not configured")
+	}
 	account, err := util.GetVaultAccount(pwd)
 	if err != nil {
 		return nil, err
 	}
 
-	tls := util.MustFetchNonEmptyParam("RPC_TLS")
-	endpoint := util.MustFetchNonEmptyParam("IO_ENDPOINT")
-	var conn *grpc.ClientConn
-
-	if tls == "true" {
-		conn, err = iotex.NewDefaultGRPCConn(endpoint)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		conn, err = iotex.NewGRPCConnWithoutTLS(endpoint)
-		if err != nil {
-			return nil, err
-		}
+	conn, err := chain.Dial(cfg.Chain)
+	if err != nil {
+		return nil, err
 	}
 	defer conn.Close()
 
-	c := iotex.NewAuthedClient(iotexapi.NewAPIServiceClient(conn), 1, account)
+	c := chain.NewClient(conn, account)
 
 	// get current epoch and block height
-	resp, err := c.API().GetChainMeta(context.Background(), &iotexapi.GetChainMetaRequest{})
+	meta, err := c.ChainMeta(context.Background())
 	if err != nil {
 		return nil, err
 	}
-	curEpoch := resp.ChainMeta.Epoch.Num
-	curHeight := resp.ChainMeta.Height
+	curEpoch := meta.Epoch.Num
+	curHeight := meta.Height
 
 	fmt.Printf("Current Epoch Number: %d\n", curEpoch)
 	fmt.Printf("Current Block Height: %d\n", curHeight)
@@ -84,58 +74,24 @@ func Reward() (*big.Int, error) {
 	return unclaimedBalance, err
 }
 
-func getUnclaimedBalance(c iotex.AuthedClient) (*big.Int, error) {
-	request := &iotexapi.ReadStateRequest{
-		ProtocolID: []byte(protocol.RewardingProtocolID),
-		MethodName: []byte(protocol.ReadUnclaimedBalanceMethodName),
-		Arguments:  [][]byte{[]byte(c.Account().Address().String())},
-	}
-	response, err := c.API().ReadState(context.Background(), request)
-	if err != nil {
-		return nil, err
-	}
-	unclaimedBlance, ok := big.NewInt(0).SetString(string(response.Data), 10)
-	if !ok {
-		return nil, errors.New("failed to convert string to big int")
-	}
-	return unclaimedBlance, nil
+func getUnclaimedBalance(c chain.Client) (*big.Int, error) {
+	return c.UnclaimedBalance(context.Background(), c.Address())
 }
 
-func claim(c iotex.AuthedClient, unclaimedBalance *big.Int) error {
+// receiptPolling is how long the claim action is waited for
+var receiptPolling = chain.DefaultPolling
+
+func claim(c chain.Client, unclaimedBalance *big.Int) error {
 	ctx := context.Background()
-	hash, err := c.ClaimReward(unclaimedBalance).Call(ctx)
+	hash, err := c.ClaimReward(ctx, unclaimedBalance, chain.Opts{})
 	if err != nil {
 		return err
 	}
 
-	err = checkActionReceipt(c, hash)
+	_, err = chain.CheckReceipt(ctx, c, hash, receiptPolling)
 	if err != nil {
 		return err
 	}
 	fmt.Println("successfully claim rewards")
 	return nil
 }
-
-func checkActionReceipt(c iotex.AuthedClient, hash hash.Hash256) error {
-	time.Sleep(5 * time.Second)
-	var resp *iotexapi.GetReceiptByActionResponse
-	var err error
-	for i := 0; i < 120; i++ {
-		resp, err = c.API().GetReceiptByAction(context.Background(), &iotexapi.GetReceiptByActionRequest{
-			ActionHash: hex.EncodeToString(hash[:]),
-		})
-		if err != nil {
-			if strings.Contains(err.Error(), "code = NotFound") {
-				time.Sleep(2 * time.Second)
-				continue
-			}
-			return err
-		}
-		if resp.ReceiptInfo.Receipt.Status != 1 {
-			return errors.Errorf("action %x check receipt failed", hash)
-		}
-		return nil
-	}
-	fmt.Printf("action %x check receipt not found\n", hash)
-	return err
-}
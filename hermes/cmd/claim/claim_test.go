@@ -13,8 +13,9 @@ import (
 
 	"github.com/iotexproject/iotex-antenna-go/v2/account"
 	"github.com/iotexproject/iotex-antenna-go/v2/iotex"
-	"github.com/iotexproject/iotex-proto/golang/iotexapi"
 	"github.com/stretchr/testify/require"
+
+	"github.com/ququzone/hermes-patch/hermes/chain"
 )
 
 const (
@@ -33,7 +34,7 @@ func TestClaimReward(t *testing.T) {
 	require.NoError(err)
 	defer conn.Close()
 
-	c := iotex.NewAuthedClient(iotexapi.NewAPIServiceClient(conn), 1, account)
+	c := chain.NewClient(conn, account)
 
 	unClaimedBalance, err := getUnclaimedBalance(c)
 	require.NoError(err)
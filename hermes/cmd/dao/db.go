@@ -1,40 +1,88 @@
 package dao
 
 import (
-	"crypto/rsa"
 	"fmt"
+	"strings"
 
 	"github.com/jinzhu/gorm"
-	// mysql dialects
+	// database dialects
 	_ "github.com/jinzhu/gorm/dialects/mysql"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
 
 	"github.com/ququzone/hermes-patch/hermes/cmd/key"
-	"github.com/ququzone/hermes-patch/hermes/util"
+	"github.com/ququzone/hermes-patch/hermes/config"
 )
 
 var db *gorm.DB
-var privateKey *rsa.PrivateKey
-var publicKey *rsa.PublicKey
+var keyring *key.Keyring
 
-// ConnectDatabase connect database
+// ConnectDatabase connect database, its schema must be migrated and the rows
+// still to be sent must be signed with the current signature versions
 func ConnectDatabase() error {
+	if err := ConnectDatabaseToResign(); err != nil {
+		return err
+	}
+	return checkSignatures()
+}
+
+// ConnectDatabaseToResign connects the database without checking the
+// signature versions, so migrate-signatures can re-sign the older rows
+func ConnectDatabaseToResign() error {
+	cfg := config.Get().Database
+	if err := Open(cfg.Dialect, cfg.Conn); err != nil {
+		return err
+	}
+	if err := checkSchema(); err != nil {
+		return err
+	}
 	var err error
-	db, err = gorm.Open("mysql", util.MustFetchNonEmptyParam("DB_CONN"))
+	keyring, err = LoadKeyring(cfg)
+	return err
+}
+
+// Open opens the database of dialect, mysql when empty
+func Open(dialect, conn string) error {
+	if dialect == "" {
+		dialect = config.DialectMySQL
+	}
+	var err error
+	db, err = gorm.Open(dialect, conn)
 	if err != nil {
 		return fmt.Errorf("open database error: %v", err)
 	}
-	db.AutoMigrate(&DropRecord{}, &SmallRecord{}, &SmallRecordBak{}, &Account{})
+	return nil
+}
 
-	privateKey, err = key.LoadPrivateKey(util.MustFetchNonEmptyParam("RSA_PRIVATE"))
+// LoadKeyring returns the keyring of the record signing keys
+func LoadKeyring(cfg config.Database) (*key.Keyring, error) {
+	privateKey, err := key.LoadPrivateKey(cfg.RSAPrivate)
 	if err != nil {
-		return fmt.Errorf("load private key error: %v", err)
+		return nil, fmt.Errorf("load private key error: %v", err)
 	}
-	publicKey, err = key.LoadPublicKey(util.MustFetchNonEmptyParam("RSA_PUBLIC"))
+	ring, err := key.NewKeyring(privateKey)
 	if err != nil {
-		return fmt.Errorf("load public key error: %v", err)
+		return nil, fmt.Errorf("new keyring error: %v", err)
+	}
+	publicKeys := []string{cfg.RSAPublic}
+	if cfg.RSAPublicKeys != "" {
+		publicKeys = append(publicKeys, strings.Split(cfg.RSAPublicKeys, ",")...)
 	}
+	for _, pem := range publicKeys {
+		publicKey, err := key.LoadPublicKey(strings.TrimSpace(pem))
+		if err != nil {
+			return nil, fmt.Errorf("load public key error: %v", err)
+		}
+		if _, err := ring.Add(publicKey); err != nil {
+			return nil, fmt.Errorf("add public key error: %v", err)
+		}
+	}
+	return ring, nil
+}
 
-	return nil
+// Keyring returns the keyring of the record signing keys
+func Keyring() *key.Keyring {
+	return keyring
 }
 
 // Transaction begin transaction
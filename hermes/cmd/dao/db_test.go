@@ -0,0 +1,114 @@
+package dao
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+// setTestDB opens a new SQLite database with a test signing key
+func setTestDB(t *testing.T) {
+	setTestKey(t)
+	require.NoError(t, Open(config.DialectSQLite, filepath.Join(t.TempDir(), "hermes.db")))
+	t.Cleanup(func() { db.Close() })
+	_, err := MigrateUp(0)
+	require.NoError(t, err)
+}
+
+func TestDropRecordQueries(t *testing.T) {
+	require := require.New(t)
+	setTestDB(t)
+
+	large, _ := new(big.Int).SetString("123456789012345678901234567", 10)
+	for _, r := range []DropRecord{
+		{EndEpoch: 10, DelegateName: "a", Voter: "io1b", Amount: large.String(), Status: "pending"},
+		{EndEpoch: 10, DelegateName: "b", Voter: "io1b", Amount: "1", Status: "pending"},
+		{EndEpoch: 10, DelegateName: "c", Voter: "io1a", Amount: "2", Status: "pending"},
+		{EndEpoch: 11, DelegateName: "a", Voter: "io1c", Amount: "3", Status: "new"},
+		// the same voter, delegate and epoch is saved once
+		{EndEpoch: 11, DelegateName: "a", Voter: "io1c", Amount: "4", Status: "new"},
+	} {
+		require.NoError(r.Save(nil))
+	}
+
+	voters, err := FindVotersByStatus("pending")
+	require.NoError(err)
+	require.Equal([]string{"io1a", "io1b"}, voters)
+	voters, err = FindVotersByStatus("it's")
+	require.NoError(err)
+	require.Empty(voters)
+
+	sum, last, err := SumByEndEpoch(10)
+	require.NoError(err)
+	require.Equal(new(big.Int).Add(large, big.NewInt(3)), sum)
+	require.Equal(uint64(11), last)
+
+	records, err := FindNewDropRecordByLimit(10)
+	require.NoError(err)
+	require.Len(records, 1)
+	require.Equal("3", records[0].Amount)
+	require.NoError(records[0].Verify())
+	count, err := CountDropRecordByStatus("pending")
+	require.NoError(err)
+	require.Equal(uint64(3), count)
+}
+
+func TestBakCompletedRecord(t *testing.T) {
+	require := require.New(t)
+	setTestDB(t)
+
+	for _, r := range []SmallRecord{
+		{EndEpoch: 10, DelegateName: "a", Voter: "io1a", Amount: "1", Status: "completed"},
+		{EndEpoch: 10, DelegateName: "a", Voter: "io1b", Amount: "2", Status: "new"},
+	} {
+		require.NoError(r.Save(nil))
+	}
+	pending, err := FindPendingSmalls("io1a", "a", 11)
+	require.NoError(err)
+	require.Empty(pending)
+	pending, err = FindPendingSmalls("io1b", "a", 11)
+	require.NoError(err)
+	require.Len(pending, 1)
+
+	require.NoError(BakCompletedRecord())
+	require.NoError(BakCompletedRecord())
+
+	var smalls []SmallRecord
+	require.NoError(db.Unscoped().Find(&smalls).Error)
+	require.Len(smalls, 1)
+	require.Equal("io1b", smalls[0].Voter)
+	var baks []SmallRecordBak
+	require.NoError(db.Find(&baks).Error)
+	require.Len(baks, 1)
+	require.Equal("io1a", baks[0].Voter)
+	require.NoError((*SmallRecord)(&baks[0]).Verify())
+
+	tables := RetainedTables
+	RetainedTables = tables[:1]
+	require.Error(BakCompletedRecord())
+	RetainedTables = tables
+}
+
+func TestFundingTransfers(t *testing.T) {
+	require := require.New(t)
+	setTestDB(t)
+
+	for _, status := range []string{FundingSent, FundingCompleted, FundingPending} {
+		require.NoError((&FundingTransfer{Status: status}).Save(nil))
+	}
+	record := DropRecord{EndEpoch: 10, Voter: "io1a", Amount: "1", Status: "new", FundingID: 3}
+	require.NoError(record.Save(nil))
+
+	unfinished, err := FindUnfinishedFundingTransfers()
+	require.NoError(err)
+	require.Len(unfinished, 2)
+	require.Equal(FundingSent, unfinished[0].Status)
+	require.Equal(FundingPending, unfinished[1].Status)
+	records, err := FindDropRecordsByFunding(3)
+	require.NoError(err)
+	require.Len(records, 1)
+}
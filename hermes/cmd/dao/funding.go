@@ -0,0 +1,84 @@
+package dao
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// Status values of funding transfers
+const (
+	FundingPending   = "pending"
+	FundingSent      = "sent"
+	FundingCompleted = "completed"
+)
+
+// FundingTransfer is a transfer of compound rewards to the sender account.
+// The drop records it funds point to it with FundingID.
+type FundingTransfer struct {
+	gorm.Model
+
+	EndEpoch  uint64 `gorm:"index:idx_funding_transfers_end_epoch"`
+	Recipient string `gorm:"type:varchar(41)"`
+	// Total is the amount of the funded records and of the previous balance
+	Total string `gorm:"type:varchar(50)"`
+	// Previous is the previous balance included in Total
+	Previous string `gorm:"type:varchar(50)"`
+	// Reference identifies the previous balance, it is only transferred once
+	Reference string `gorm:"type:varchar(100)"`
+	// Amount is the amount sent, lower than Total when the balance is short
+	Amount string `gorm:"type:varchar(50)"`
+	// Nonce is pinned before the transfer is sent, so sending it again can't
+	// pay twice
+	Nonce        uint64
+	Hash         string `gorm:"type:varchar(64)"`
+	GasConsumed  uint64
+	Status       string `gorm:"type:varchar(15);index:idx_funding_transfers_status"`
+	ErrorMessage string `gorm:"type:text"`
+}
+
+// TableName table name of FundingTransfer
+func (FundingTransfer) TableName() string {
+	return "funding_transfers"
+}
+
+// Save insert or update the funding transfer
+func (t *FundingTransfer) Save(tx *gorm.DB) error {
+	if tx == nil {
+		tx = db
+	}
+	return tx.Save(t).Error
+}
+
+// FindUnfinishedFundingTransfers returns the funding transfers not completed, oldest first
+func FindUnfinishedFundingTransfers() (result []*FundingTransfer, err error) {
+	err = db.Where("status <> ?", FundingCompleted).Order("id").Find(&result).Error
+	return
+}
+
+// FindFundingTransfer returns the funding transfer of id
+func FindFundingTransfer(id uint) (*FundingTransfer, error) {
+	var result FundingTransfer
+	if err := db.First(&result, id).Error; err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// FindFundingTransferByReference returns the funding transfer to recipient
+// that includes the previous balance of reference, or nil
+func FindFundingTransferByReference(recipient, reference string) (*FundingTransfer, error) {
+	var result FundingTransfer
+	err := db.Where("recipient = ? and reference = ?", recipient, reference).Order("id").First(&result).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// FindDropRecordsByFunding returns the drop records funded by the transfer id
+func FindDropRecordsByFunding(id uint) (result []DropRecord, err error) {
+	err = db.Where("funding_id = ?", id).Order("id").Find(&result).Error
+	return
+}
@@ -0,0 +1,205 @@
+package dao
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Status values of the distribution ledger
+const (
+	RunRunning     = "running"
+	RunCompleted   = "completed"
+	ChunkPending   = "pending"
+	ChunkSent      = "sent"
+	ChunkCompleted = "completed"
+	ChunkFailed    = "failed"
+)
+
+// DistributionRun is the distribution of one end epoch
+type DistributionRun struct {
+	gorm.Model
+
+	EndEpoch uint64 `gorm:"unique_index:idx_distribution_runs_end_epoch"`
+	Tip      string `gorm:"type:varchar(50)"`
+	Host     string `gorm:"type:varchar(100)"`
+	Status   string `gorm:"type:varchar(15)"`
+}
+
+// TableName table name of DistributionRun
+func (DistributionRun) TableName() string {
+	return "distribution_runs"
+}
+
+// DistributionDelegate is the split of one delegate in a run
+type DistributionDelegate struct {
+	gorm.Model
+
+	RunID        uint   `gorm:"unique_index:idx_distribution_delegates_run_delegate"`
+	EndEpoch     uint64 `gorm:"index:idx_distribution_delegates_end_epoch"`
+	DelegateName string `gorm:"type:varchar(100);unique_index:idx_distribution_delegates_run_delegate"`
+	Total        string `gorm:"type:varchar(50)"`
+	ServiceFee   string `gorm:"type:varchar(50)"`
+	// ChargeFee is deducted from every recipient paid by transfer
+	ChargeFee       string `gorm:"type:varchar(50)"`
+	TotalRecipients int
+	Chunks          int
+}
+
+// TableName table name of DistributionDelegate
+func (DistributionDelegate) TableName() string {
+	return "distribution_delegates"
+}
+
+// DistributionChunk is one distributeRewards call. Recipients and Amounts are
+// JSON arrays of io addresses and decimal amounts.
+type DistributionChunk struct {
+	gorm.Model
+
+	DelegateID   uint   `gorm:"unique_index:idx_distribution_chunks_delegate_chunk"`
+	EndEpoch     uint64 `gorm:"index:idx_distribution_chunks_end_epoch"`
+	DelegateName string `gorm:"type:varchar(100)"`
+	ChunkIndex   int    `gorm:"unique_index:idx_distribution_chunks_delegate_chunk"`
+	Recipients   string `gorm:"type:text"`
+	Amounts      string `gorm:"type:text"`
+	Total        string `gorm:"type:varchar(50)"`
+	Hash         string `gorm:"type:varchar(64)"`
+	GasConsumed  uint64
+	Status       string `gorm:"type:varchar(15);index:idx_distribution_chunks_status"`
+	Signature    string `gorm:"type:text"`
+	ErrorMessage string `gorm:"type:text"`
+	KeyID        string `gorm:"type:varchar(16)"`
+	// SignatureVersion is 0 for chunks signed before ChunkSignatureVersion
+	SignatureVersion uint8
+	// Nonce and SignedAction are the action of a sent chunk, recorded before
+	// it is broadcast so it can be broadcast again
+	Nonce        uint64
+	SignedAction string `gorm:"type:text"`
+}
+
+// TableName table name of DistributionChunk
+func (DistributionChunk) TableName() string {
+	return "distribution_chunks"
+}
+
+// ChunkSignatureVersion is the version of the chunk signatures, version 0
+// chunks are re-signed by MigrateChunkSignatures
+const ChunkSignatureVersion = 1
+
+func (t *DistributionChunk) message() string {
+	return fmt.Sprintf("v1|chunk|%d|%q|%d|%q|%q|%q", t.EndEpoch, t.DelegateName, t.ChunkIndex, t.Recipients, t.Amounts, t.Total)
+}
+
+// legacyMessage is the message of a version 0 signature
+func (t *DistributionChunk) legacyMessage() string {
+	return fmt.Sprintf("%s,%d,%d,%s,%s", t.DelegateName, t.EndEpoch, t.ChunkIndex, t.Recipients, t.Amounts)
+}
+
+// sign signs the chunk with the current key
+func (t *DistributionChunk) sign() error {
+	signature, keyID, err := keyring.Sign(t.message())
+	if err != nil {
+		return err
+	}
+	t.Signature, t.KeyID, t.SignatureVersion = signature, keyID, ChunkSignatureVersion
+	return nil
+}
+
+// Verify verify signature of the chunk with the key it was made by
+func (t *DistributionChunk) Verify() error {
+	if t.SignatureVersion != ChunkSignatureVersion {
+		return fmt.Errorf("chunk version %d: %w", t.SignatureVersion, ErrSignatureVersion)
+	}
+	return keyring.Verify(t.message(), t.Signature, t.KeyID)
+}
+
+// Save update the chunk status
+func (t *DistributionChunk) Save(tx *gorm.DB) error {
+	if tx == nil {
+		tx = db
+	}
+	return tx.Save(t).Error
+}
+
+// StartDistributionRun returns the run of endEpoch, it is created if it doesn't exist
+func StartDistributionRun(endEpoch uint64, tip string) (*DistributionRun, error) {
+	var run DistributionRun
+	err := db.Where("end_epoch = ?", endEpoch).First(&run).Error
+	if err == nil {
+		return &run, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	run = DistributionRun{
+		EndEpoch: endEpoch,
+		Tip:      tip,
+		Host:     host,
+		Status:   RunRunning,
+	}
+	if err := db.Create(&run).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// FindRunningDistributionRun returns the run of endEpoch in progress, nil if there is none
+func FindRunningDistributionRun(endEpoch uint64) (*DistributionRun, error) {
+	var run DistributionRun
+	err := db.Where("end_epoch = ? and status = ?", endEpoch, RunRunning).First(&run).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// CompleteDistributionRun marks the run completed
+func CompleteDistributionRun(run *DistributionRun) error {
+	run.Status = RunCompleted
+	return db.Save(run).Error
+}
+
+// FindDistributionDelegate returns the split of delegate in run, nil if it is not split yet
+func FindDistributionDelegate(runID uint, delegateName string) (*DistributionDelegate, error) {
+	var delegate DistributionDelegate
+	err := db.Where("run_id = ? and delegate_name = ?", runID, delegateName).First(&delegate).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &delegate, nil
+}
+
+// CreateDistributionDelegate saves the split of a delegate with its signed chunks
+func CreateDistributionDelegate(tx *gorm.DB, delegate *DistributionDelegate, chunks []*DistributionChunk) error {
+	delegate.Chunks = len(chunks)
+	if err := tx.Create(delegate).Error; err != nil {
+		return err
+	}
+	for _, chunk := range chunks {
+		chunk.DelegateID = delegate.ID
+		chunk.EndEpoch = delegate.EndEpoch
+		chunk.DelegateName = delegate.DelegateName
+		chunk.Status = ChunkPending
+		if err := chunk.sign(); err != nil {
+			return err
+		}
+		if err := tx.Create(chunk).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindDistributionChunks returns the chunks of a delegate ordered by index
+func FindDistributionChunks(delegateID uint) (result []*DistributionChunk, err error) {
+	err = db.Where("delegate_id = ?", delegateID).Order("chunk_index").Find(&result).Error
+	return
+}
@@ -0,0 +1,387 @@
+package dao
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+// Migration is a versioned schema change. Down is nil when the change can't
+// be rolled back.
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration is an applied migration
+type SchemaMigration struct {
+	Version   uint `gorm:"primary_key;auto_increment:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// TableName table name of SchemaMigration
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationState is a migration and when it was applied, if it was
+type MigrationState struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// migrations are applied in order, a released migration is never changed
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial schema",
+		Up: func(tx *gorm.DB) error {
+			// the tables created by AutoMigrate before versioned migrations, it
+			// completes those of older versions
+			return tx.AutoMigrate(&schemaV1DropRecord{}, &schemaV1SmallRecord{}, &schemaV1SmallRecordBak{},
+				&schemaV1Account{}, &schemaV1DistributionRun{}, &schemaV1DistributionDelegate{},
+				&schemaV1DistributionChunk{}, &schemaV1ReconcileReport{}, &schemaV1ReconcileFinding{},
+				&schemaV1FundingTransfer{}).Error
+		},
+	},
+	{
+		Version: 2,
+		Name:    "unique drop record voter",
+		Up: func(tx *gorm.DB) error {
+			var duplicates []struct {
+				EndEpoch     uint64
+				DelegateName string
+				Voter        string
+			}
+			err := tx.Unscoped().Model(&DropRecord{}).Select("end_epoch, delegate_name, voter").
+				Group("end_epoch, delegate_name, voter").Having("count(*) > 1").Scan(&duplicates).Error
+			if err != nil {
+				return err
+			}
+			if len(duplicates) > 0 {
+				d := duplicates[0]
+				return fmt.Errorf("%d voters have duplicated drop records, first is %s of %s at end epoch %d",
+					len(duplicates), d.Voter, d.DelegateName, d.EndEpoch)
+			}
+			return tx.Model(&DropRecord{}).AddUniqueIndex("idx_drop_records_epoch_delegate_voter",
+				"end_epoch", "delegate_name", "voter").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Model(&DropRecord{}).RemoveIndex("idx_drop_records_epoch_delegate_voter").Error
+		},
+	},
+	{
+		Version: 3,
+		Name:    "drop record archives",
+		Up: func(tx *gorm.DB) error {
+			return tx.CreateTable(&schemaV3DropRecordArchive{}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			var count uint64
+			if err := tx.Unscoped().Model(&schemaV3DropRecordArchive{}).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("%d drop records are archived, restore them first", count)
+			}
+			return tx.DropTable(&schemaV3DropRecordArchive{}).Error
+		},
+	},
+	{
+		Version: 4,
+		Name:    "payout amounts",
+		Up: func(tx *gorm.DB) error {
+			for _, c := range payoutColumns {
+				if err := addColumn(tx, c[0], c[1], "varchar(50)"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, c := range payoutColumns {
+				if err := dropColumn(tx, c[0], c[1]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 5,
+		Name:    "drop record actions",
+		Up: func(tx *gorm.DB) error {
+			for _, table := range []string{"drop_records", "drop_record_archives"} {
+				if err := addColumn(tx, table, "sender", "varchar(41)"); err != nil {
+					return err
+				}
+				if err := addColumn(tx, table, "signed_action", "text"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, table := range []string{"drop_records", "drop_record_archives"} {
+				if err := dropColumn(tx, table, "sender"); err != nil {
+					return err
+				}
+				if err := dropColumn(tx, table, "signed_action"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 6,
+		Name:    "funding previous",
+		Up: func(tx *gorm.DB) error {
+			return addColumn(tx, "funding_transfers", "previous", "varchar(50)")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumn(tx, "funding_transfers", "previous")
+		},
+	},
+	{
+		Version: 7,
+		Name:    "chunk actions",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumn(tx, "distribution_chunks", "nonce", "bigint"); err != nil {
+				return err
+			}
+			return addColumn(tx, "distribution_chunks", "signed_action", "text")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropColumn(tx, "distribution_chunks", "nonce"); err != nil {
+				return err
+			}
+			return dropColumn(tx, "distribution_chunks", "signed_action")
+		},
+	},
+	{
+		Version: 8,
+		Name:    "chunk signature version",
+		Up: func(tx *gorm.DB) error {
+			return addColumn(tx, "distribution_chunks", "signature_version", "smallint")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumn(tx, "distribution_chunks", "signature_version")
+		},
+	},
+	{
+		Version: 9,
+		Name:    "funding reference",
+		Up: func(tx *gorm.DB) error {
+			return addColumn(tx, "funding_transfers", "reference", "varchar(100)")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumn(tx, "funding_transfers", "reference")
+		},
+	},
+}
+
+// payoutColumns are the table and column pairs of migration 4
+var payoutColumns = [][2]string{
+	{"distribution_delegates", "charge_fee"},
+	{"drop_records", "deposited"},
+	{"drop_record_archives", "deposited"},
+}
+
+// addColumn adds column of sqlType to table
+func addColumn(tx *gorm.DB, table, column, sqlType string) error {
+	return tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s",
+		tx.Dialect().Quote(table), tx.Dialect().Quote(column), sqlType)).Error
+}
+
+// dropColumn drops column of table. The SQLite of the driver can't drop
+// columns, the table is rebuilt without it instead.
+func dropColumn(tx *gorm.DB, table, column string) error {
+	if tx.Dialect().GetName() != config.DialectSQLite {
+		return tx.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s",
+			tx.Dialect().Quote(table), tx.Dialect().Quote(column))).Error
+	}
+
+	rows, err := tx.Raw(fmt.Sprintf("PRAGMA table_info(%s)", tx.Dialect().Quote(table))).Rows()
+	if err != nil {
+		return err
+	}
+	var definitions, columns []string
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, sqlType    string
+			defaultValue     *string
+		)
+		if err := rows.Scan(&cid, &name, &sqlType, &notNull, &defaultValue, &pk); err != nil {
+			rows.Close()
+			return err
+		}
+		if name == column {
+			continue
+		}
+		definition := tx.Dialect().Quote(name) + " " + sqlType
+		if pk > 0 {
+			definition += " PRIMARY KEY AUTOINCREMENT"
+		}
+		if notNull > 0 {
+			definition += " NOT NULL"
+		}
+		if defaultValue != nil {
+			definition += " DEFAULT " + *defaultValue
+		}
+		definitions = append(definitions, definition)
+		columns = append(columns, tx.Dialect().Quote(name))
+	}
+	rows.Close()
+
+	// the indexes go with the old table, those not using column are created again
+	var indexes []string
+	err = tx.Raw("SELECT sql FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL", table).
+		Pluck("sql", &indexes).Error
+	if err != nil {
+		return err
+	}
+	rebuilt := table + "_rebuilt"
+	list := strings.Join(columns, ", ")
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE %s (%s)", tx.Dialect().Quote(rebuilt), strings.Join(definitions, ", ")),
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", tx.Dialect().Quote(rebuilt), list, list, tx.Dialect().Quote(table)),
+		fmt.Sprintf("DROP TABLE %s", tx.Dialect().Quote(table)),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", tx.Dialect().Quote(rebuilt), tx.Dialect().Quote(table)),
+	}
+	for _, index := range indexes {
+		if !strings.Contains(index, tx.Dialect().Quote(column)) {
+			statements = append(statements, index)
+		}
+	}
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appliedMigrations returns the applied versions
+func appliedMigrations() (map[uint]SchemaMigration, error) {
+	if !db.HasTable(&SchemaMigration{}) {
+		return nil, nil
+	}
+	var rows []SchemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("query schema_migrations error: %v", err)
+	}
+	result := make(map[uint]SchemaMigration, len(rows))
+	for _, row := range rows {
+		result[row.Version] = row
+	}
+	return result, nil
+}
+
+// MigrationStatus returns every migration, oldest first
+func MigrationStatus() ([]MigrationState, error) {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		state := MigrationState{Migration: m}
+		if row, ok := applied[m.Version]; ok {
+			appliedAt := row.AppliedAt
+			state.AppliedAt = &appliedAt
+		}
+		result = append(result, state)
+	}
+	return result, nil
+}
+
+// MigrateUp applies the pending migrations up to version, every one when
+// version is 0, and returns those it applied
+func MigrateUp(version uint) ([]Migration, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}).Error; err != nil {
+		return nil, fmt.Errorf("create schema_migrations error: %v", err)
+	}
+	applied, err := appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, m := range migrations {
+		if version > 0 && m.Version > version {
+			break
+		}
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		tx := Transaction()
+		if err := m.Up(tx); err != nil {
+			tx.Rollback()
+			return done, fmt.Errorf("migration %d %s error: %v", m.Version, m.Name, err)
+		}
+		if err := tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()}).Error; err != nil {
+			tx.Rollback()
+			return done, fmt.Errorf("save migration %d error: %v", m.Version, err)
+		}
+		if err := tx.Commit().Error; err != nil {
+			return done, fmt.Errorf("commit migration %d error: %v", m.Version, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// MigrateDown rolls back the last steps applied migrations, newest first,
+// and returns those it rolled back
+func MigrateDown(steps int) ([]Migration, error) {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Down == nil {
+			return done, fmt.Errorf("migration %d %s can't be rolled back", m.Version, m.Name)
+		}
+		tx := Transaction()
+		if err := m.Down(tx); err != nil {
+			tx.Rollback()
+			return done, fmt.Errorf("roll back migration %d %s error: %v", m.Version, m.Name, err)
+		}
+		if err := tx.Delete(&SchemaMigration{Version: m.Version}).Error; err != nil {
+			tx.Rollback()
+			return done, fmt.Errorf("delete migration %d error: %v", m.Version, err)
+		}
+		if err := tx.Commit().Error; err != nil {
+			return done, fmt.Errorf("commit migration %d error: %v", m.Version, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// checkSchema returns an error when a migration is not applied
+func checkSchema() error {
+	states, err := MigrationStatus()
+	if err != nil {
+		return err
+	}
+	for _, state := range states {
+		if state.AppliedAt == nil {
+			return fmt.Errorf("migration %d %s is not applied, run `db migrate up`", state.Version, state.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package dao
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+func TestMigrations(t *testing.T) {
+	require := require.New(t)
+	setTestKey(t)
+	require.NoError(Open(config.DialectSQLite, filepath.Join(t.TempDir(), "hermes.db")))
+	defer db.Close()
+
+	require.ErrorContains(checkSchema(), "run `db migrate up`")
+	done, err := MigrateUp(1)
+	require.NoError(err)
+	require.Len(done, 1)
+	require.ErrorContains(checkSchema(), "migration 2")
+
+	done, err = MigrateUp(0)
+	require.NoError(err)
+	require.Len(done, len(migrations)-1)
+	require.Equal(uint(2), done[0].Version)
+	require.NoError(checkSchema())
+	done, err = MigrateUp(0)
+	require.NoError(err)
+	require.Empty(done)
+
+	states, err := MigrationStatus()
+	require.NoError(err)
+	require.Len(states, len(migrations))
+	for _, state := range states {
+		require.NotNil(state.AppliedAt)
+	}
+
+	// the unique index refuses duplicated records that Save would skip
+	record := DropRecord{EndEpoch: 10, DelegateName: "a", Voter: "io1a", Amount: "1", Status: "new"}
+	require.NoError(record.Save(nil))
+	duplicate := record
+	require.Error(db.Create(&duplicate).Error)
+
+	done, err = MigrateDown(len(migrations) - 1)
+	require.NoError(err)
+	require.Len(done, len(migrations)-1)
+	require.Equal(uint(2), done[len(done)-1].Version)
+	require.Error(checkSchema())
+	// the table is back to the first schema
+	require.False(db.Dialect().HasColumn("drop_records", "deposited"))
+	require.True(db.Dialect().HasIndex("drop_records", "idx_drop_records_status"))
+	v1 := schemaV1DropRecord{EndEpoch: 10, DelegateName: "a", Voter: "io1a", Amount: "1", Status: "new"}
+	require.NoError(db.Create(&v1).Error)
+	_, err = MigrateUp(0)
+	require.ErrorContains(err, "1 voters have duplicated drop records")
+	require.NoError(db.Unscoped().Delete(&v1).Error)
+	_, err = MigrateUp(0)
+	require.NoError(err)
+
+	// archived records are restored before their table is dropped
+	require.NoError(db.Create(&DropRecordArchive{EndEpoch: 1}).Error)
+	_, err = MigrateDown(len(migrations) - 1)
+	require.ErrorContains(err, "1 drop records are archived")
+	require.NoError(db.Unscoped().Delete(&DropRecordArchive{}).Error)
+
+	done, err = MigrateDown(len(migrations))
+	require.ErrorContains(err, "migration 1 initial schema can't be rolled back")
+	require.Equal(uint(2), done[len(done)-1].Version)
+	_, err = MigrateUp(0)
+	require.NoError(err)
+}
@@ -2,11 +2,10 @@ package dao
 
 import (
 	"fmt"
+	"math"
 	"math/big"
 
 	"github.com/jinzhu/gorm"
-
-	"github.com/ququzone/hermes-patch/hermes/cmd/key"
 )
 
 // DropRecord drop record model
@@ -20,8 +19,22 @@ type DropRecord struct {
 	Amount       string `gorm:"type:varchar(50)"`
 	Status       string `gorm:"type:varchar(15);index:idx_drop_records_status"`
 	Hash         string `gorm:"type:varchar(64)"`
+	GasConsumed  uint64
 	Signature    string `gorm:"type:text"`
 	ErrorMessage string `gorm:"type:text"`
+
+	SignatureVersion uint8
+	KeyID            string `gorm:"type:varchar(16)"`
+	// FundingID is the funding transfer paying the sender for the record
+	FundingID uint `gorm:"index:idx_drop_records_funding_id"`
+	// Nonce is the nonce of the action of Hash, recorded before it is sent
+	Nonce uint64
+	// Deposited is the amount sent to the voter, after the action fee
+	Deposited string `gorm:"type:varchar(50)"`
+	// Sender is the account sending the action of Hash
+	Sender string `gorm:"type:varchar(41)"`
+	// SignedAction is the encoded action of Hash, kept to broadcast it again
+	SignedAction string `gorm:"type:text"`
 }
 
 // TableName table name of DropRecord
@@ -29,23 +42,27 @@ func (DropRecord) TableName() string {
 	return "drop_records"
 }
 
-// Save insert or update drop record
+// Save insert or update drop record, a record of the same end epoch, delegate
+// and voter is not inserted again. The unique index of migration 2 refuses
+// the duplicates of concurrent saves.
 func (t DropRecord) Save(tx *gorm.DB) error {
 	if tx == nil {
 		tx = db
 	}
 
 	if t.Signature == "" {
-		signature, err := key.Sign(fmt.Sprintf("%s,%s,%s", t.DelegateName, t.Amount, t.Status), privateKey)
+		signature, keyID, err := sign(t.message())
 		if err != nil {
 			return err
 		}
 		t.Signature = signature
+		t.SignatureVersion = SignatureVersion
+		t.KeyID = keyID
 	}
 
 	if t.ID == 0 {
 		var count uint64
-		err := tx.Model(&DropRecord{}).Where("`end_epoch` = ? and `delegate_name` = ? and `voter` = ?", t.EndEpoch, t.DelegateName, t.Voter).Count(&count).Error
+		err := tx.Model(&DropRecord{}).Where("end_epoch = ? and delegate_name = ? and voter = ?", t.EndEpoch, t.DelegateName, t.Voter).Count(&count).Error
 		if err != nil {
 			return err
 		}
@@ -58,9 +75,20 @@ func (t DropRecord) Save(tx *gorm.DB) error {
 	return tx.Save(&t).Error
 }
 
+// message is the signed content of the record
+func (t *DropRecord) message() string {
+	return fmt.Sprintf("v3|drop|%d|%q|%q|%d|%q|%q|%q|%d|%q|%q|%q|%d", t.EndEpoch, t.DelegateName, t.Voter, t.Index, t.Amount,
+		t.Status, t.Hash, t.Nonce, t.Sender, t.SignedAction, t.Deposited, t.FundingID)
+}
+
+// v2Message is the message of a version 2 signature
+func (t *DropRecord) v2Message() string {
+	return fmt.Sprintf("v2|drop|%d|%q|%q|%d|%q|%q", t.EndEpoch, t.DelegateName, t.Voter, t.Index, t.Amount, t.Status)
+}
+
 // Verify verify signature
 func (t *DropRecord) Verify() error {
-	return key.Verify(fmt.Sprintf("%s,%s,%s", t.DelegateName, t.Amount, t.Status), t.Signature, publicKey)
+	return verify(t.SignatureVersion, SignatureVersion, t.KeyID, t.message(), t.Signature)
 }
 
 // FindNewDropRecordByLimit find by limit
@@ -69,19 +97,15 @@ func FindNewDropRecordByLimit(limit int32) (result []DropRecord, err error) {
 	return
 }
 
+// CountDropRecordByStatus count records of status
+func CountDropRecordByStatus(status string) (count uint64, err error) {
+	err = db.Model(&DropRecord{}).Where("status = ?", status).Count(&count).Error
+	return
+}
+
+// FindVotersByStatus returns the distinct voters of the drop records of status
 func FindVotersByStatus(status string) (result []string, err error) {
-	stmt, _ := db.DB().Prepare("select distinct voter from drop_records where status = '" + status + "'")
-	rows, err := stmt.Query()
-	if err != nil {
-		return
-	}
-	for rows.Next() {
-		var row string
-		if err = rows.Scan(&row); err != nil {
-			return
-		}
-		result = append(result, row)
-	}
+	err = db.Model(&DropRecord{}).Where("status = ?", status).Order("voter").Pluck("distinct voter", &result).Error
 	return
 }
 
@@ -95,24 +119,29 @@ func FindByStatus(status string) (result []DropRecord, err error) {
 	return
 }
 
+// SumByEndEpoch returns the total amount of the drop records of endEpoch and
+// the last end epoch of all drop records. Amounts are summed exactly, as they
+// are stored as decimal strings.
 func SumByEndEpoch(endEpoch uint64) (*big.Int, uint64, error) {
-	var result float64
-
-	stmt, _ := db.DB().Prepare("select sum(amount) as total from drop_records where end_epoch = ?")
-	err := stmt.QueryRow(endEpoch).Scan(&result)
-	if err != nil {
+	var amounts []string
+	if err := db.Model(&DropRecord{}).Where("end_epoch = ?", endEpoch).Pluck("amount", &amounts).Error; err != nil {
 		return nil, 0, err
 	}
-	sum, _ := big.NewFloat(result).Int(nil)
+	sum := big.NewInt(0)
+	for _, v := range amounts {
+		amount, ok := new(big.Int).SetString(v, 10)
+		if !ok {
+			return nil, 0, fmt.Errorf("invalid drop record amount %s of end epoch %d", v, endEpoch)
+		}
+		sum.Add(sum, amount)
+	}
 
-	var maxEndEpoch uint64
-	stmt, _ = db.DB().Prepare("select max(end_epoch) as end_epoch from drop_records")
-	err = stmt.QueryRow().Scan(&maxEndEpoch)
+	last, err := LastEndEpoch()
 	if err != nil {
 		return nil, 0, err
 	}
 
-	return sum, maxEndEpoch, err
+	return sum, last, nil
 }
 
 type SmallRecord struct {
@@ -127,6 +156,9 @@ type SmallRecord struct {
 	Hash         string `gorm:"type:varchar(64)"`
 	Signature    string `gorm:"type:text"`
 	ErrorMessage string `gorm:"type:text"`
+
+	SignatureVersion uint8
+	KeyID            string `gorm:"type:varchar(16)"`
 }
 
 func FindSmallByVoterAndStatus(voter, delegate, status string, endEpoch uint64) (result []SmallRecord, err error) {
@@ -134,8 +166,11 @@ func FindSmallByVoterAndStatus(voter, delegate, status string, endEpoch uint64)
 	return
 }
 
+// FindPendingSmalls returns the new small records of voter and delegate from
+// other end epochs. Completed ones restored from the archive are skipped, they
+// were already paid.
 func FindPendingSmalls(voter, delegate string, endEpoch uint64) (result []SmallRecord, err error) {
-	err = db.Where("voter = ? and delegate_name = ? and end_epoch <> ?", voter, delegate, endEpoch).Find(&result).Error
+	err = db.Where("voter = ? and delegate_name = ? and status = ? and end_epoch <> ?", voter, delegate, "new", endEpoch).Find(&result).Error
 	return
 }
 
@@ -146,16 +181,18 @@ func (t SmallRecord) Save(tx *gorm.DB) error {
 	}
 
 	if t.Signature == "" {
-		signature, err := key.Sign(fmt.Sprintf("%s,%s,%s", t.DelegateName, t.Amount, t.Status), privateKey)
+		signature, keyID, err := sign(t.message())
 		if err != nil {
 			return err
 		}
 		t.Signature = signature
+		t.SignatureVersion = SmallSignatureVersion
+		t.KeyID = keyID
 	}
 
 	if t.ID == 0 {
 		var count uint64
-		err := tx.Model(&SmallRecord{}).Where("`end_epoch` = ? and `delegate_name` = ? and `voter` = ?", t.EndEpoch, t.DelegateName, t.Voter).Count(&count).Error
+		err := tx.Model(&SmallRecord{}).Where("end_epoch = ? and delegate_name = ? and voter = ?", t.EndEpoch, t.DelegateName, t.Voter).Count(&count).Error
 		if err != nil {
 			return err
 		}
@@ -168,9 +205,14 @@ func (t SmallRecord) Save(tx *gorm.DB) error {
 	return tx.Save(&t).Error
 }
 
+// message is the signed content of the record
+func (t *SmallRecord) message() string {
+	return fmt.Sprintf("v2|small|%d|%d|%q|%q|%q|%q", t.EndEpoch, t.SentEpoch, t.DelegateName, t.Voter, t.Amount, t.Status)
+}
+
 // Verify verify signature
 func (t *SmallRecord) Verify() error {
-	return key.Verify(fmt.Sprintf("%s,%s,%s", t.DelegateName, t.Amount, t.Status), t.Signature, publicKey)
+	return verify(t.SignatureVersion, SmallSignatureVersion, t.KeyID, t.message(), t.Signature)
 }
 
 type SmallRecordBak struct {
@@ -178,28 +220,30 @@ type SmallRecordBak struct {
 
 	EndEpoch     uint64
 	SentEpoch    uint64
-	DelegateName string `gorm:"type:varchar(100);index:idx_small_records_delegate_name"`
-	Voter        string `gorm:"type:varchar(41);index:idx_small_records_voter"`
+	DelegateName string `gorm:"type:varchar(100);index:idx_small_record_baks_delegate_name"`
+	Voter        string `gorm:"type:varchar(41);index:idx_small_record_baks_voter"`
 	Amount       string `gorm:"type:varchar(50)"`
-	Status       string `gorm:"type:varchar(15);index:idx_small_records_status"`
+	Status       string `gorm:"type:varchar(15);index:idx_small_record_baks_status"`
 	Hash         string `gorm:"type:varchar(64)"`
 	Signature    string `gorm:"type:text"`
 	ErrorMessage string `gorm:"type:text"`
+
+	SignatureVersion uint8
+	KeyID            string `gorm:"type:varchar(16)"`
 }
 
+// BakCompletedRecord moves the completed small records to small_record_baks
 func BakCompletedRecord() error {
-	tx := Transaction()
-	err := tx.Exec("INSERT INTO small_record_baks SELECT * FROM small_records WHERE status =?", "completed").Error
-	if err != nil {
-		tx.Rollback()
-		return err
+	smalls, ok := FindRetainedTable("small_records")
+	if !ok {
+		return fmt.Errorf("small_records is not a retained table")
 	}
-	err = tx.Exec("DELETE FROM small_records WHERE status =?", "completed").Error
-	if err != nil {
-		tx.Rollback()
-		return err
+	for {
+		n, err := smalls.Archive(math.MaxInt64, 1000, nil, true)
+		if err != nil || n == 0 {
+			return err
+		}
 	}
-	return tx.Commit().Error
 }
 
 type Account struct {
@@ -217,7 +261,7 @@ func (t Account) Save(tx *gorm.DB) error {
 	}
 
 	var count uint64
-	err := tx.Model(&Account{}).Where("`address` = ?", t.Address).Count(&count).Error
+	err := tx.Model(&Account{}).Where("address = ?", t.Address).Count(&count).Error
 	if err != nil {
 		return err
 	}
@@ -229,7 +273,7 @@ func (t Account) Save(tx *gorm.DB) error {
 
 func FindAccount(address string) (*Account, error) {
 	var account Account
-	err := db.Model(&Account{}).Where("`address` = ?", address).First(&account).Error
+	err := db.Model(&Account{}).Where("address = ?", address).First(&account).Error
 	if err == gorm.ErrRecordNotFound {
 		return nil, nil
 	}
@@ -0,0 +1,63 @@
+package dao
+
+// FindDistributionDelegatesByEpoch returns the delegate splits of endEpoch
+func FindDistributionDelegatesByEpoch(endEpoch uint64) (result []DistributionDelegate, err error) {
+	err = db.Where("end_epoch = ?", endEpoch).Order("delegate_name").Find(&result).Error
+	return
+}
+
+// FindDistributionChunksByEpoch returns the chunks of endEpoch, whatever their status
+func FindDistributionChunksByEpoch(endEpoch uint64) (result []*DistributionChunk, err error) {
+	err = db.Where("end_epoch = ?", endEpoch).Order("delegate_name, chunk_index").Find(&result).Error
+	return
+}
+
+// FindDropRecordsByEpoch returns the drop records of endEpoch, including the archived ones
+func FindDropRecordsByEpoch(endEpoch uint64) ([]DropRecord, error) {
+	return findDropRecords("end_epoch = ?", endEpoch)
+}
+
+// FindDropRecordsByIDs returns the drop records of ids, including the archived ones
+func FindDropRecordsByIDs(ids []uint) ([]DropRecord, error) {
+	return findDropRecords("id in (?)", ids)
+}
+
+// FindDropRecordsByStatuses returns the drop records of statuses, including the archived ones
+func FindDropRecordsByStatuses(statuses []string) ([]DropRecord, error) {
+	return findDropRecords("status in (?)", statuses)
+}
+
+func findDropRecords(where string, args ...interface{}) (result []DropRecord, err error) {
+	if err = db.Where(where, args...).Order("id").Find(&result).Error; err != nil {
+		return
+	}
+	var archives []DropRecordArchive
+	err = db.Where(where, args...).Order("id").Find(&archives).Error
+	for _, v := range archives {
+		result = append(result, DropRecord(v))
+	}
+	return
+}
+
+// FindSmallRecordsByEpoch returns the small records of endEpoch, including the backed up ones
+func FindSmallRecordsByEpoch(endEpoch uint64) ([]SmallRecord, error) {
+	return findSmallRecords("end_epoch = ?", endEpoch)
+}
+
+// FindSmallRecordsBySentEpoch returns the small records paid in sentEpoch,
+// including the backed up ones
+func FindSmallRecordsBySentEpoch(sentEpoch uint64) ([]SmallRecord, error) {
+	return findSmallRecords("status = ? and sent_epoch = ?", "completed", sentEpoch)
+}
+
+func findSmallRecords(where string, args ...interface{}) (result []SmallRecord, err error) {
+	if err = db.Where(where, args...).Order("id").Find(&result).Error; err != nil {
+		return
+	}
+	var baks []SmallRecordBak
+	err = db.Where(where, args...).Order("id").Find(&baks).Error
+	for _, v := range baks {
+		result = append(result, SmallRecord(v))
+	}
+	return
+}
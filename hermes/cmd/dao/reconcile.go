@@ -0,0 +1,99 @@
+package dao
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// Record types of reconciliation findings
+const (
+	RecordDrop  = "drop_record"
+	RecordSmall = "small_record"
+	RecordChunk = "distribution_chunk"
+)
+
+// Kinds of reconciliation findings
+const (
+	FindingMissing           = "missing"
+	FindingFailed            = "failed"
+	FindingDuplicated        = "duplicated"
+	FindingAmountMismatch    = "amount_mismatch"
+	FindingRecipientMismatch = "recipient_mismatch"
+)
+
+// ReconcileReport is one reconciliation of an epoch range against the chain
+type ReconcileReport struct {
+	gorm.Model
+
+	FromEpoch uint64
+	ToEpoch   uint64
+	Records   int
+	Findings  int
+}
+
+// TableName table name of ReconcileReport
+func (ReconcileReport) TableName() string {
+	return "reconcile_reports"
+}
+
+// ReconcileFinding is a ledger record whose payment doesn't match the chain
+type ReconcileFinding struct {
+	gorm.Model
+
+	ReportID     uint   `gorm:"index:idx_reconcile_findings_report_id"`
+	RecordType   string `gorm:"type:varchar(20)"`
+	RecordID     uint
+	EndEpoch     uint64
+	DelegateName string `gorm:"type:varchar(100)"`
+	Hash         string `gorm:"type:varchar(64)"`
+	Kind         string `gorm:"type:varchar(20);index:idx_reconcile_findings_kind"`
+	Expected     string `gorm:"type:varchar(100)"`
+	Actual       string `gorm:"type:varchar(100)"`
+	Message      string `gorm:"type:text"`
+}
+
+// TableName table name of ReconcileFinding
+func (ReconcileFinding) TableName() string {
+	return "reconcile_findings"
+}
+
+// FindCompletedDropRecords returns the completed drop records of the end
+// epochs in [from, to], including the archived ones
+func FindCompletedDropRecords(from, to uint64) ([]DropRecord, error) {
+	return findDropRecords("status = ? and end_epoch >= ? and end_epoch <= ?", "completed", from, to)
+}
+
+// FindCompletedSmallRecords returns the completed small records sent in the end
+// epochs [from, to], including the backed up ones
+func FindCompletedSmallRecords(from, to uint64) ([]SmallRecord, error) {
+	return findSmallRecords("status = ? and sent_epoch >= ? and sent_epoch <= ?", "completed", from, to)
+}
+
+// FindCompletedDistributionChunks returns the completed chunks of the end epochs in [from, to]
+func FindCompletedDistributionChunks(from, to uint64) (result []*DistributionChunk, err error) {
+	err = db.Where("status = ? and end_epoch >= ? and end_epoch <= ?", ChunkCompleted, from, to).Order("id").Find(&result).Error
+	return
+}
+
+// FindDistributionRuns returns the runs of the end epochs in [from, to]
+func FindDistributionRuns(from, to uint64) (result []DistributionRun, err error) {
+	err = db.Where("end_epoch >= ? and end_epoch <= ?", from, to).Find(&result).Error
+	return
+}
+
+// SaveReconcileReport saves a report with its findings
+func SaveReconcileReport(report *ReconcileReport, findings []*ReconcileFinding) error {
+	tx := Transaction()
+	report.Findings = len(findings)
+	if err := tx.Create(report).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, finding := range findings {
+		finding.ReportID = report.ID
+		if err := tx.Create(finding).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
@@ -0,0 +1,247 @@
+package dao
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// DropRecordArchive is an archived drop record, it has the columns of DropRecord
+type DropRecordArchive struct {
+	gorm.Model
+
+	EndEpoch     uint64 `gorm:"index:idx_drop_record_archives_end_epoch"`
+	DelegateName string `gorm:"type:varchar(100)"`
+	Voter        string `gorm:"type:varchar(41)"`
+	Index        uint64
+	Amount       string `gorm:"type:varchar(50)"`
+	Status       string `gorm:"type:varchar(15)"`
+	Hash         string `gorm:"type:varchar(64)"`
+	GasConsumed  uint64
+	Signature    string `gorm:"type:text"`
+	ErrorMessage string `gorm:"type:text"`
+
+	SignatureVersion uint8
+	KeyID            string `gorm:"type:varchar(16)"`
+	FundingID        uint
+	Nonce            uint64
+	Deposited        string `gorm:"type:varchar(50)"`
+	Sender           string `gorm:"type:varchar(41)"`
+	SignedAction     string `gorm:"type:text"`
+}
+
+// TableName table name of DropRecordArchive
+func (DropRecordArchive) TableName() string {
+	return "drop_record_archives"
+}
+
+// RetainedTable is a record table whose old completed rows are moved to an
+// archive table with the same columns
+type RetainedTable struct {
+	Table        string
+	ArchiveTable string
+	// columns are copied between the table and its archive
+	columns []string
+	// archived selects the rows to archive, the end epoch they are before is
+	// appended to args
+	archived string
+	args     []interface{}
+	// rows returns a pointer to an empty slice of the table model
+	rows func() interface{}
+	// row returns a pointer to a new table model
+	row func() interface{}
+}
+
+// RetainedTables are the record tables with an archive. The distribution
+// ledger (runs, delegates and chunks), funding transfers and reconcile reports
+// are kept: they grow by chunk, transfer or run rather than by voter, export
+// and reconcile read the ledger of every end epoch, and archived drop records
+// still point to their funding transfer.
+var RetainedTables = []RetainedTable{
+	{
+		Table:        "drop_records",
+		ArchiveTable: "drop_record_archives",
+		columns: []string{"id", "created_at", "updated_at", "deleted_at", "end_epoch", "delegate_name", "voter",
+			"index", "amount", "status", "hash", "gas_consumed", "signature", "error_message",
+			"signature_version", "key_id", "funding_id", "nonce", "deposited", "sender", "signed_action"},
+		archived: "(status = ? or status like ?) and end_epoch < ?",
+		args:     []interface{}{"completed", "merged-%"},
+		rows:     func() interface{} { return &[]DropRecord{} },
+		row:      func() interface{} { return &DropRecord{} },
+	},
+	{
+		Table:        "small_records",
+		ArchiveTable: "small_record_baks",
+		columns: []string{"id", "created_at", "updated_at", "deleted_at", "end_epoch", "sent_epoch", "delegate_name",
+			"voter", "amount", "status", "hash", "signature", "error_message", "signature_version", "key_id"},
+		archived: "status = ? and end_epoch < ?",
+		args:     []interface{}{"completed"},
+		rows:     func() interface{} { return &[]SmallRecord{} },
+		row:      func() interface{} { return &SmallRecord{} },
+	},
+}
+
+// FindRetainedTable returns the retained table named table
+func FindRetainedTable(table string) (RetainedTable, bool) {
+	for _, t := range RetainedTables {
+		if t.Table == table {
+			return t, true
+		}
+	}
+	return RetainedTable{}, false
+}
+
+// copyRows copies the rows of ids from one table to another with the
+// explicit column list of t
+func (t RetainedTable) copyRows(tx *gorm.DB, from, to string, ids []uint) error {
+	columns := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		columns[i] = tx.Dialect().Quote(c)
+	}
+	list := strings.Join(columns, ", ")
+	return tx.Exec(fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s WHERE id IN (?)",
+		tx.Dialect().Quote(to), list, list, tx.Dialect().Quote(from)), ids).Error
+}
+
+// Archive moves up to limit rows of t that end before the end epoch before,
+// lowest ID first, and returns how many it moved. The rows, a pointer to a
+// slice of the table model, are given to export with their first and last ID
+// before they leave the table. When keep is false they are deleted without
+// being copied to the archive table.
+func (t RetainedTable) Archive(before uint64, limit int, export func(first, last uint, rows interface{}) error, keep bool) (int, error) {
+	var ids []uint
+	args := append(append([]interface{}(nil), t.args...), before)
+	err := db.Unscoped().Table(t.Table).Where(t.archived, args...).Order("id").Limit(limit).Pluck("id", &ids).Error
+	if err != nil {
+		return 0, fmt.Errorf("query %s error: %v", t.Table, err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if export != nil {
+		rows := t.rows()
+		if err := db.Unscoped().Table(t.Table).Where("id in (?)", ids).Order("id").Find(rows).Error; err != nil {
+			return 0, fmt.Errorf("query %s error: %v", t.Table, err)
+		}
+		if err := export(ids[0], ids[len(ids)-1], rows); err != nil {
+			return 0, err
+		}
+	}
+
+	tx := Transaction()
+	if keep {
+		if err := t.copyRows(tx, t.Table, t.ArchiveTable, ids); err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("archive %s error: %v", t.Table, err)
+		}
+	}
+	if err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id IN (?)", tx.Dialect().Quote(t.Table)), ids).Error; err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("delete archived %s error: %v", t.Table, err)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return 0, fmt.Errorf("commit archived %s error: %v", t.Table, err)
+	}
+	return len(ids), nil
+}
+
+// CountArchivable returns how many rows of t end before the end epoch before
+func (t RetainedTable) CountArchivable(before uint64) (count uint64, err error) {
+	args := append(append([]interface{}(nil), t.args...), before)
+	err = db.Unscoped().Table(t.Table).Where(t.archived, args...).Count(&count).Error
+	return
+}
+
+// Unarchive moves up to limit archived rows of the end epochs in [from, to]
+// back to t, and returns how many it moved. Rows whose ID is used in t are
+// left in the archive.
+func (t RetainedTable) Unarchive(from, to uint64, limit int) (int, error) {
+	var ids []uint
+	err := db.Unscoped().Table(t.ArchiveTable).Where("end_epoch >= ? and end_epoch <= ?", from, to).
+		Where(fmt.Sprintf("id not in (select id from %s)", db.Dialect().Quote(t.Table))).
+		Order("id").Limit(limit).Pluck("id", &ids).Error
+	if err != nil {
+		return 0, fmt.Errorf("query %s error: %v", t.ArchiveTable, err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	tx := Transaction()
+	if err := t.copyRows(tx, t.ArchiveTable, t.Table, ids); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("restore %s error: %v", t.Table, err)
+	}
+	if err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id IN (?)", tx.Dialect().Quote(t.ArchiveTable)), ids).Error; err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("delete restored %s error: %v", t.ArchiveTable, err)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return 0, fmt.Errorf("commit restored %s error: %v", t.Table, err)
+	}
+	return len(ids), nil
+}
+
+// Import inserts in t the rows decoded from r, one JSON row per line as
+// written by an export, and returns how many it inserted. Rows whose ID is in
+// t or in its archive are skipped.
+func (t RetainedTable) Import(r io.Reader) (int, error) {
+	decoder := json.NewDecoder(r)
+	tx := Transaction()
+	imported := 0
+	for {
+		row := t.row()
+		err := decoder.Decode(row)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("decode %s row error: %v", t.Table, err)
+		}
+		id := modelID(row)
+		var count uint64
+		for _, table := range []string{t.Table, t.ArchiveTable} {
+			var n uint64
+			if err := tx.Unscoped().Table(table).Where("id = ?", id).Count(&n).Error; err != nil {
+				tx.Rollback()
+				return 0, fmt.Errorf("query %s error: %v", table, err)
+			}
+			count += n
+		}
+		if count > 0 {
+			continue
+		}
+		if err := tx.Table(t.Table).Create(row).Error; err != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("insert %s row %d error: %v", t.Table, id, err)
+		}
+		imported++
+	}
+	if err := tx.Commit().Error; err != nil {
+		return 0, fmt.Errorf("commit imported %s error: %v", t.Table, err)
+	}
+	return imported, nil
+}
+
+// modelID returns the ID of a table model
+func modelID(row interface{}) uint {
+	switch v := row.(type) {
+	case *DropRecord:
+		return v.ID
+	case *SmallRecord:
+		return v.ID
+	}
+	return 0
+}
+
+// LastEndEpoch returns the last end epoch of the drop records, 0 without any
+func LastEndEpoch() (uint64, error) {
+	var last struct {
+		EndEpoch uint64
+	}
+	err := db.Model(&DropRecord{}).Select("coalesce(max(end_epoch), 0) as end_epoch").Scan(&last).Error
+	return last.EndEpoch, err
+}
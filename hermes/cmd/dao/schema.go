@@ -0,0 +1,212 @@
+package dao
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// The tables as created by the first migrations. They are snapshots, later
+// changes of the models are made by new migrations and never here.
+
+type schemaV1DropRecord struct {
+	gorm.Model
+
+	EndEpoch     uint64
+	DelegateName string `gorm:"type:varchar(100)"`
+	Voter        string `gorm:"type:varchar(41)"`
+	Index        uint64
+	Amount       string `gorm:"type:varchar(50)"`
+	Status       string `gorm:"type:varchar(15);index:idx_drop_records_status"`
+	Hash         string `gorm:"type:varchar(64)"`
+	GasConsumed  uint64
+	Signature    string `gorm:"type:text"`
+	ErrorMessage string `gorm:"type:text"`
+
+	SignatureVersion uint8
+	KeyID            string `gorm:"type:varchar(16)"`
+	FundingID        uint   `gorm:"index:idx_drop_records_funding_id"`
+	Nonce            uint64
+}
+
+func (schemaV1DropRecord) TableName() string {
+	return "drop_records"
+}
+
+type schemaV1SmallRecord struct {
+	gorm.Model
+
+	EndEpoch     uint64
+	SentEpoch    uint64
+	DelegateName string `gorm:"type:varchar(100);index:idx_small_records_delegate_name"`
+	Voter        string `gorm:"type:varchar(41);index:idx_small_records_voter"`
+	Amount       string `gorm:"type:varchar(50)"`
+	Status       string `gorm:"type:varchar(15);index:idx_small_records_status"`
+	Hash         string `gorm:"type:varchar(64)"`
+	Signature    string `gorm:"type:text"`
+	ErrorMessage string `gorm:"type:text"`
+
+	SignatureVersion uint8
+	KeyID            string `gorm:"type:varchar(16)"`
+}
+
+func (schemaV1SmallRecord) TableName() string {
+	return "small_records"
+}
+
+type schemaV1SmallRecordBak struct {
+	gorm.Model
+
+	EndEpoch     uint64
+	SentEpoch    uint64
+	DelegateName string `gorm:"type:varchar(100);index:idx_small_record_baks_delegate_name"`
+	Voter        string `gorm:"type:varchar(41);index:idx_small_record_baks_voter"`
+	Amount       string `gorm:"type:varchar(50)"`
+	Status       string `gorm:"type:varchar(15);index:idx_small_record_baks_status"`
+	Hash         string `gorm:"type:varchar(64)"`
+	Signature    string `gorm:"type:text"`
+	ErrorMessage string `gorm:"type:text"`
+
+	SignatureVersion uint8
+	KeyID            string `gorm:"type:varchar(16)"`
+}
+
+func (schemaV1SmallRecordBak) TableName() string {
+	return "small_record_baks"
+}
+
+type schemaV1Account struct {
+	gorm.Model
+
+	Address  string `gorm:"type:varchar(41);index:idx_all_addresses_address"`
+	Transfer uint8
+	Contract uint8
+}
+
+func (schemaV1Account) TableName() string {
+	return "accounts"
+}
+
+type schemaV1DistributionRun struct {
+	gorm.Model
+
+	EndEpoch uint64 `gorm:"unique_index:idx_distribution_runs_end_epoch"`
+	Tip      string `gorm:"type:varchar(50)"`
+	Host     string `gorm:"type:varchar(100)"`
+	Status   string `gorm:"type:varchar(15)"`
+}
+
+func (schemaV1DistributionRun) TableName() string {
+	return "distribution_runs"
+}
+
+type schemaV1DistributionDelegate struct {
+	gorm.Model
+
+	RunID           uint   `gorm:"unique_index:idx_distribution_delegates_run_delegate"`
+	EndEpoch        uint64 `gorm:"index:idx_distribution_delegates_end_epoch"`
+	DelegateName    string `gorm:"type:varchar(100);unique_index:idx_distribution_delegates_run_delegate"`
+	Total           string `gorm:"type:varchar(50)"`
+	ServiceFee      string `gorm:"type:varchar(50)"`
+	TotalRecipients int
+	Chunks          int
+}
+
+func (schemaV1DistributionDelegate) TableName() string {
+	return "distribution_delegates"
+}
+
+type schemaV1DistributionChunk struct {
+	gorm.Model
+
+	DelegateID   uint   `gorm:"unique_index:idx_distribution_chunks_delegate_chunk"`
+	EndEpoch     uint64 `gorm:"index:idx_distribution_chunks_end_epoch"`
+	DelegateName string `gorm:"type:varchar(100)"`
+	ChunkIndex   int    `gorm:"unique_index:idx_distribution_chunks_delegate_chunk"`
+	Recipients   string `gorm:"type:text"`
+	Amounts      string `gorm:"type:text"`
+	Total        string `gorm:"type:varchar(50)"`
+	Hash         string `gorm:"type:varchar(64)"`
+	GasConsumed  uint64
+	Status       string `gorm:"type:varchar(15);index:idx_distribution_chunks_status"`
+	Signature    string `gorm:"type:text"`
+	ErrorMessage string `gorm:"type:text"`
+	KeyID        string `gorm:"type:varchar(16)"`
+}
+
+func (schemaV1DistributionChunk) TableName() string {
+	return "distribution_chunks"
+}
+
+type schemaV1FundingTransfer struct {
+	gorm.Model
+
+	EndEpoch     uint64 `gorm:"index:idx_funding_transfers_end_epoch"`
+	Recipient    string `gorm:"type:varchar(41)"`
+	Total        string `gorm:"type:varchar(50)"`
+	Amount       string `gorm:"type:varchar(50)"`
+	Nonce        uint64
+	Hash         string `gorm:"type:varchar(64)"`
+	GasConsumed  uint64
+	Status       string `gorm:"type:varchar(15);index:idx_funding_transfers_status"`
+	ErrorMessage string `gorm:"type:text"`
+}
+
+func (schemaV1FundingTransfer) TableName() string {
+	return "funding_transfers"
+}
+
+type schemaV1ReconcileReport struct {
+	gorm.Model
+
+	FromEpoch uint64
+	ToEpoch   uint64
+	Records   int
+	Findings  int
+}
+
+func (schemaV1ReconcileReport) TableName() string {
+	return "reconcile_reports"
+}
+
+type schemaV1ReconcileFinding struct {
+	gorm.Model
+
+	ReportID     uint   `gorm:"index:idx_reconcile_findings_report_id"`
+	RecordType   string `gorm:"type:varchar(20)"`
+	RecordID     uint
+	EndEpoch     uint64
+	DelegateName string `gorm:"type:varchar(100)"`
+	Hash         string `gorm:"type:varchar(64)"`
+	Kind         string `gorm:"type:varchar(20);index:idx_reconcile_findings_kind"`
+	Expected     string `gorm:"type:varchar(100)"`
+	Actual       string `gorm:"type:varchar(100)"`
+	Message      string `gorm:"type:text"`
+}
+
+func (schemaV1ReconcileFinding) TableName() string {
+	return "reconcile_findings"
+}
+
+// schemaV3DropRecordArchive is drop_record_archives as created by migration 3
+type schemaV3DropRecordArchive struct {
+	gorm.Model
+
+	EndEpoch     uint64 `gorm:"index:idx_drop_record_archives_end_epoch"`
+	DelegateName string `gorm:"type:varchar(100)"`
+	Voter        string `gorm:"type:varchar(41)"`
+	Index        uint64
+	Amount       string `gorm:"type:varchar(50)"`
+	Status       string `gorm:"type:varchar(15)"`
+	Hash         string `gorm:"type:varchar(64)"`
+	GasConsumed  uint64
+	Signature    string `gorm:"type:text"`
+	ErrorMessage string `gorm:"type:text"`
+
+	SignatureVersion uint8
+	KeyID            string `gorm:"type:varchar(16)"`
+	FundingID        uint
+	Nonce            uint64
+}
+
+func (schemaV3DropRecordArchive) TableName() string {
+	return "drop_record_archives"
+}
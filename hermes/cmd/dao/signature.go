@@ -0,0 +1,255 @@
+package dao
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+)
+
+// SignatureVersion is the version of the drop record signatures written by
+// Save. Version 1 only covered the delegate name, amount and status, version 2
+// covers every field that decides who is paid what. Version 3 also covers the
+// action of a drop record, which is broadcast again while it is submitted, and
+// its deposited amount and funding transfer.
+const SignatureVersion = 3
+
+// SmallSignatureVersion is the version of the small record signatures, they
+// have no action and stay at version 2
+const SmallSignatureVersion = 2
+
+// ErrSignatureVersion is returned when a row is signed with another version,
+// the row is re-signed by migrate-signatures rather than invalid
+var ErrSignatureVersion = errors.New("signature version is not current, run migrate-signatures")
+
+const resignBatch = 500
+
+// pendingStatuses are the statuses of records not paid yet
+var pendingStatuses = []string{"new", "pending", "submitted"}
+
+// sign signs message with the current key, it returns the signature and the key ID
+func sign(message string) (string, string, error) {
+	return keyring.Sign(message)
+}
+
+// verify checks a signature of the current version with the key it was made by
+func verify(version, current uint8, keyID, message, signature string) error {
+	if version != current {
+		return fmt.Errorf("version %d: %w", version, ErrSignatureVersion)
+	}
+	return keyring.Verify(message, signature, keyID)
+}
+
+// legacyMessage is the message of a version 1 signature
+func legacyMessage(delegateName, amount, status string) string {
+	return fmt.Sprintf("%s,%s,%s", delegateName, amount, status)
+}
+
+// checkSignatures refuses the rows still to be sent signed with an older
+// version, they would fail verification at runtime and never be sent
+func checkSignatures() error {
+	var count int
+	err := db.Model(&DropRecord{}).Where("status in (?) and (signature_version is null or signature_version < ?)",
+		pendingStatuses, SignatureVersion).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%d drop records are signed with a version older than %d, run migrate-signatures", count, SignatureVersion)
+	}
+	err = db.Model(&SmallRecord{}).Where("status in (?) and (signature_version is null or signature_version < ?)",
+		pendingStatuses, SmallSignatureVersion).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%d small records are signed with a version older than %d, run migrate-signatures", count, SmallSignatureVersion)
+	}
+	// the chunks of a run in progress are verified when it is resumed
+	err = db.Model(&DistributionChunk{}).Where("(signature_version is null or signature_version < ?) and delegate_id in "+
+		"(select d.id from distribution_delegates d join distribution_runs r on r.id = d.run_id where r.status = ?)",
+		ChunkSignatureVersion, RunRunning).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%d distribution chunks are signed with a version older than %d, run migrate-signatures", count, ChunkSignatureVersion)
+	}
+	return nil
+}
+
+// SignatureMigration is the outcome of re-signing one table
+type SignatureMigration struct {
+	Table    string
+	Migrated int
+	// Invalid are the IDs of rows failing verification, they are left untouched
+	Invalid []uint
+}
+
+// MigrateDropRecordSignatures re-signs the drop records with a signature of an
+// older version once it is verified, nothing is written on dry run
+func MigrateDropRecordSignatures(dryRun bool) (*SignatureMigration, error) {
+	return resignDropRecords(dryRun, func(row *DropRecord) error {
+		if row.SignatureVersion == 2 {
+			return keyring.Verify(row.v2Message(), row.Signature, row.KeyID)
+		}
+		return keyring.VerifyAny(legacyMessage(row.DelegateName, row.Amount, row.Status), row.Signature)
+	}, "(signature_version is null or signature_version < ?)", SignatureVersion)
+}
+
+// MigrateSmallRecordSignatures re-signs the small records with a signature of
+// an older version once it is verified, nothing is written on dry run
+func MigrateSmallRecordSignatures(dryRun bool) (*SignatureMigration, error) {
+	return resignSmallRecords(dryRun, func(row *SmallRecord) error {
+		return keyring.VerifyAny(legacyMessage(row.DelegateName, row.Amount, row.Status), row.Signature)
+	}, "(signature_version is null or signature_version < ?)", SmallSignatureVersion)
+}
+
+// MigrateChunkSignatures re-signs the distribution chunks with a version 0
+// signature once it is verified, nothing is written on dry run
+func MigrateChunkSignatures(dryRun bool) (*SignatureMigration, error) {
+	return resignChunks(dryRun, func(row *DistributionChunk) error {
+		if row.KeyID == "" {
+			return keyring.VerifyAny(row.legacyMessage(), row.Signature)
+		}
+		return keyring.Verify(row.legacyMessage(), row.Signature, row.KeyID)
+	}, "(signature_version is null or signature_version < ?)", ChunkSignatureVersion)
+}
+
+// RotateDropRecordKeys re-signs the pending drop records signed by another
+// key with the current key once they are verified, nothing is written on dry run
+func RotateDropRecordKeys(dryRun bool) (*SignatureMigration, error) {
+	return resignDropRecords(dryRun, func(row *DropRecord) error {
+		return row.Verify()
+	}, "status in (?) and (key_id is null or key_id <> ?)", pendingStatuses, keyring.CurrentID())
+}
+
+// RotateSmallRecordKeys re-signs the pending small records signed by another
+// key with the current key once they are verified, nothing is written on dry run
+func RotateSmallRecordKeys(dryRun bool) (*SignatureMigration, error) {
+	return resignSmallRecords(dryRun, func(row *SmallRecord) error {
+		return row.Verify()
+	}, "status in (?) and (key_id is null or key_id <> ?)", pendingStatuses, keyring.CurrentID())
+}
+
+// RotateChunkKeys re-signs the distribution chunks not completed yet signed by
+// another key with the current key once they are verified, nothing is written
+// on dry run
+func RotateChunkKeys(dryRun bool) (*SignatureMigration, error) {
+	return resignChunks(dryRun, func(row *DistributionChunk) error {
+		return row.Verify()
+	}, "status <> ? and (key_id is null or key_id <> ?)", ChunkCompleted, keyring.CurrentID())
+}
+
+// resignDropRecords re-signs the drop records matching where in batches, a row
+// is only re-signed if check accepts its current signature
+func resignDropRecords(dryRun bool, check func(*DropRecord) error, where string, args ...interface{}) (*SignatureMigration, error) {
+	result := &SignatureMigration{Table: DropRecord{}.TableName()}
+	var lastID uint
+	for {
+		var rows []DropRecord
+		err := db.Where(where, args...).Where("id > ?", lastID).Order("id").Limit(resignBatch).Find(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			return result, nil
+		}
+		tx := Transaction()
+		for _, row := range rows {
+			lastID = row.ID
+			if err := check(&row); err != nil {
+				result.Invalid = append(result.Invalid, row.ID)
+				continue
+			}
+			result.Migrated++
+			row.Signature = ""
+			if err := row.Save(tx); err != nil {
+				tx.Rollback()
+				return nil, fmt.Errorf("re-sign drop record %d error: %v", row.ID, err)
+			}
+		}
+		if err := commit(tx, dryRun); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// resignSmallRecords re-signs the small records matching where in batches, a
+// row is only re-signed if check accepts its current signature
+func resignSmallRecords(dryRun bool, check func(*SmallRecord) error, where string, args ...interface{}) (*SignatureMigration, error) {
+	result := &SignatureMigration{Table: "small_records"}
+	var lastID uint
+	for {
+		var rows []SmallRecord
+		err := db.Where(where, args...).Where("id > ?", lastID).Order("id").Limit(resignBatch).Find(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			return result, nil
+		}
+		tx := Transaction()
+		for _, row := range rows {
+			lastID = row.ID
+			if err := check(&row); err != nil {
+				result.Invalid = append(result.Invalid, row.ID)
+				continue
+			}
+			result.Migrated++
+			row.Signature = ""
+			if err := row.Save(tx); err != nil {
+				tx.Rollback()
+				return nil, fmt.Errorf("re-sign small record %d error: %v", row.ID, err)
+			}
+		}
+		if err := commit(tx, dryRun); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// resignChunks re-signs the distribution chunks matching where in batches, a
+// row is only re-signed if check accepts its current signature
+func resignChunks(dryRun bool, check func(*DistributionChunk) error, where string, args ...interface{}) (*SignatureMigration, error) {
+	result := &SignatureMigration{Table: DistributionChunk{}.TableName()}
+	var lastID uint
+	for {
+		var rows []DistributionChunk
+		err := db.Where(where, args...).Where("id > ?", lastID).Order("id").Limit(resignBatch).Find(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			return result, nil
+		}
+		tx := Transaction()
+		for _, row := range rows {
+			lastID = row.ID
+			if err := check(&row); err != nil {
+				result.Invalid = append(result.Invalid, row.ID)
+				continue
+			}
+			result.Migrated++
+			if err := row.sign(); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			if err := row.Save(tx); err != nil {
+				tx.Rollback()
+				return nil, fmt.Errorf("re-sign distribution chunk %d error: %v", row.ID, err)
+			}
+		}
+		if err := commit(tx, dryRun); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// commit commits tx, or rolls it back on dry run
+func commit(tx *gorm.DB, dryRun bool) error {
+	if dryRun {
+		return tx.Rollback().Error
+	}
+	return tx.Commit().Error
+}
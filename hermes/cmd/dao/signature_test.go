@@ -0,0 +1,186 @@
+package dao
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ququzone/hermes-patch/hermes/cmd/key"
+)
+
+func setTestKey(t *testing.T) *rsa.PrivateKey {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyring, err = key.NewKeyring(privateKey)
+	require.NoError(t, err)
+	return privateKey
+}
+
+func TestDropRecordSignature(t *testing.T) {
+	require := require.New(t)
+	privateKey := setTestKey(t)
+
+	signed := func() DropRecord {
+		record := DropRecord{EndEpoch: 123, DelegateName: "delegate", Voter: "io1voter", Index: 7, Amount: "100", Status: "new"}
+		signature, keyID, err := sign(record.message())
+		require.NoError(err)
+		record.Signature, record.SignatureVersion, record.KeyID = signature, SignatureVersion, keyID
+		return record
+	}
+	record := signed()
+	require.Len(record.KeyID, 16)
+	require.NoError(record.Verify())
+
+	for name, tamper := range map[string]func(*DropRecord){
+		"voter":     func(r *DropRecord) { r.Voter = "io1attacker" },
+		"bucket":    func(r *DropRecord) { r.Index = 8 },
+		"end epoch": func(r *DropRecord) { r.EndEpoch = 124 },
+		"amount":    func(r *DropRecord) { r.Amount = "1000" },
+		"hash":      func(r *DropRecord) { r.Hash = "00" },
+		"nonce":     func(r *DropRecord) { r.Nonce = 9 },
+		"sender":    func(r *DropRecord) { r.Sender = "io1attacker" },
+		"action":    func(r *DropRecord) { r.SignedAction = "00" },
+		"deposited": func(r *DropRecord) { r.Deposited = "1000" },
+		"funding":   func(r *DropRecord) { r.FundingID = 9 },
+		"version":   func(r *DropRecord) { r.SignatureVersion = 2 },
+		"key":       func(r *DropRecord) { r.KeyID = "0000000000000000" },
+	} {
+		record := signed()
+		tamper(&record)
+		require.Error(record.Verify(), name)
+	}
+
+	// records signed by a rotated key still verify while its public key is kept
+	rotated, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(err)
+	old := keyring
+	keyring, err = key.NewKeyring(rotated)
+	require.NoError(err)
+	require.Error(record.Verify())
+	_, err = keyring.Add(&privateKey.PublicKey)
+	require.NoError(err)
+	require.NoError(record.Verify())
+	require.NotEqual(record.KeyID, keyring.CurrentID())
+	keyring = old
+
+	// a legacy signature only verifies under the legacy message
+	legacy, err := key.Sign(legacyMessage(record.DelegateName, record.Amount, record.Status), privateKey)
+	require.NoError(err)
+	record.Signature, record.SignatureVersion, record.KeyID = legacy, 0, ""
+	require.ErrorIs(record.Verify(), ErrSignatureVersion)
+	require.NoError(keyring.VerifyAny(legacyMessage(record.DelegateName, record.Amount, record.Status), record.Signature))
+}
+
+func TestSmallRecordSignature(t *testing.T) {
+	require := require.New(t)
+	setTestKey(t)
+
+	record := SmallRecord{EndEpoch: 120, DelegateName: "delegate", Voter: "io1voter", Amount: "10", Status: "new"}
+	signature, keyID, err := sign(record.message())
+	require.NoError(err)
+	record.Signature, record.SignatureVersion, record.KeyID = signature, SmallSignatureVersion, keyID
+	require.NoError(record.Verify())
+
+	record.SentEpoch = 123
+	require.Error(record.Verify())
+}
+
+func TestMigrateVersion2Signatures(t *testing.T) {
+	require := require.New(t)
+	setTestDB(t)
+
+	record := DropRecord{EndEpoch: 123, DelegateName: "delegate", Voter: "io1voter", Index: 7, Amount: "100", Status: "submitted", Hash: "00", Nonce: 3}
+	signature, keyID, err := sign(record.v2Message())
+	require.NoError(err)
+	record.Signature, record.SignatureVersion, record.KeyID = signature, 2, keyID
+	require.NoError(db.Create(&record).Error)
+	require.Error(record.Verify())
+
+	result, err := MigrateDropRecordSignatures(false)
+	require.NoError(err)
+	require.Equal(1, result.Migrated)
+	require.Empty(result.Invalid)
+	require.NoError(db.First(&record, record.ID).Error)
+	require.Equal(uint8(SignatureVersion), record.SignatureVersion)
+	require.NoError(record.Verify())
+}
+
+func TestChunkSignature(t *testing.T) {
+	require := require.New(t)
+	setTestDB(t)
+
+	signed := func() DistributionChunk {
+		chunk := DistributionChunk{EndEpoch: 123, DelegateName: "delegate", ChunkIndex: 1, Recipients: `["io1voter"]`, Amounts: `["100"]`, Total: "100"}
+		require.NoError(chunk.sign())
+		return chunk
+	}
+	chunk := signed()
+	require.NoError(chunk.Verify())
+	for name, tamper := range map[string]func(*DistributionChunk){
+		"recipients": func(c *DistributionChunk) { c.Recipients = `["io1attacker"]` },
+		"amounts":    func(c *DistributionChunk) { c.Amounts = `["1000"]` },
+		"total":      func(c *DistributionChunk) { c.Total = "1000" },
+		"index":      func(c *DistributionChunk) { c.ChunkIndex = 2 },
+		"version":    func(c *DistributionChunk) { c.SignatureVersion = 0 },
+		"no key":     func(c *DistributionChunk) { c.KeyID = "" },
+	} {
+		chunk := signed()
+		tamper(&chunk)
+		require.Error(chunk.Verify(), name)
+	}
+
+	// a version 0 chunk is re-signed once its legacy signature verifies
+	legacy := signed()
+	signature, _, err := sign(legacy.legacyMessage())
+	require.NoError(err)
+	legacy.Signature, legacy.KeyID, legacy.SignatureVersion = signature, "", 0
+	require.NoError(db.Create(&legacy).Error)
+	forged := signed()
+	forged.ChunkIndex, forged.SignatureVersion = 2, 0
+	require.NoError(db.Create(&forged).Error)
+	require.Error(legacy.Verify())
+
+	result, err := MigrateChunkSignatures(false)
+	require.NoError(err)
+	require.Equal(1, result.Migrated)
+	require.Equal([]uint{forged.ID}, result.Invalid)
+	require.NoError(db.First(&legacy, legacy.ID).Error)
+	require.NoError(legacy.Verify())
+}
+
+func TestCheckSignatures(t *testing.T) {
+	require := require.New(t)
+	setTestDB(t)
+	require.NoError(checkSignatures())
+
+	record := DropRecord{EndEpoch: 123, DelegateName: "delegate", Voter: "io1voter", Index: 7, Amount: "100", Status: "new"}
+	signature, keyID, err := sign(record.v2Message())
+	require.NoError(err)
+	record.Signature, record.SignatureVersion, record.KeyID = signature, 2, keyID
+	require.NoError(db.Create(&record).Error)
+	require.ErrorContains(checkSignatures(), "1 drop records")
+	_, err = MigrateDropRecordSignatures(false)
+	require.NoError(err)
+	require.NoError(checkSignatures())
+
+	// only the records still to be paid are checked
+	small := SmallRecord{EndEpoch: 123, DelegateName: "delegate", Voter: "io1voter", Amount: "1", Status: "new"}
+	small.Signature = legacyMessage(small.DelegateName, small.Amount, small.Status)
+	require.NoError(db.Create(&small).Error)
+	require.ErrorContains(checkSignatures(), "1 small records")
+	small.Status = "completed"
+	require.NoError(db.Save(&small).Error)
+	require.NoError(checkSignatures())
+
+	run := DistributionRun{EndEpoch: 123, Status: RunRunning}
+	require.NoError(db.Create(&run).Error)
+	delegate := DistributionDelegate{RunID: run.ID, EndEpoch: 123, DelegateName: "delegate"}
+	require.NoError(db.Create(&delegate).Error)
+	require.NoError(db.Create(&DistributionChunk{DelegateID: delegate.ID, EndEpoch: 123, DelegateName: "delegate"}).Error)
+	require.ErrorContains(checkSignatures(), "1 distribution chunks")
+	run.Status = RunCompleted
+	require.NoError(db.Save(&run).Error)
+	require.NoError(checkSignatures())
+}
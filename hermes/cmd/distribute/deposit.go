@@ -20,35 +20,25 @@ import (
 	"github.com/iotexproject/go-pkgs/hash"
 	"github.com/iotexproject/iotex-address/address"
 	"github.com/iotexproject/iotex-antenna-go/v2/account"
-	"github.com/iotexproject/iotex-antenna-go/v2/iotex"
-	"github.com/iotexproject/iotex-proto/golang/iotexapi"
-	"github.com/iotexproject/iotex-proto/golang/iotextypes"
 	"github.com/pkg/errors"
-	"google.golang.org/grpc"
-	"google.golang.org/protobuf/proto"
 
+	"github.com/ququzone/hermes-patch/hermes/chain"
 	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+	"github.com/ququzone/hermes-patch/hermes/config"
+	"github.com/ququzone/hermes-patch/hermes/metrics"
+	"github.com/ququzone/hermes-patch/hermes/notify"
 	"github.com/ququzone/hermes-patch/hermes/util"
 )
 
 // GetBucketID query bucketID from contract
-func GetBucketID(c iotex.AuthedClient, voter common.Address) (int64, error) {
-	cstring := util.MustFetchNonEmptyParam("AUTO_DEPOSIT_CONTRACT_ADDRESS")
-	caddr, err := address.FromString(cstring)
-	if err != nil {
-		return 0, err
-	}
+func GetBucketID(c chain.Client, voter common.Address) (int64, error) {
+	caddr := config.Get().Contracts.AutoDeposit.Address()
 	autoDepositABI, err := abi.JSON(strings.NewReader(AutoDepositABI))
 	if err != nil {
 		return 0, err
 	}
 
-	data, err := c.Contract(caddr, autoDepositABI).Read("bucket", voter).Call(context.Background())
-	if err != nil {
-		return 0, err
-	}
-
-	bucketID, err := data.Unmarshal()
+	bucketID, err := c.ReadContract(context.Background(), caddr, autoDepositABI, "bucket", voter)
 	if err != nil {
 		return 0, err
 	}
@@ -58,16 +48,23 @@ func GetBucketID(c iotex.AuthedClient, voter common.Address) (int64, error) {
 // Sender send drop record
 type Sender struct {
 	Accounts []account.Account
-	Notifier *Notifier
+	Notifier *notify.Alerter
+
+	clients []chain.Client
 }
 
 type accountSender struct {
-	account   account.Account
-	records   []dao.DropRecord
-	waitGroup *sync.WaitGroup
-	notifier  *Notifier
+	client   chain.Client
+	notifier *notify.Alerter
+	// failures counts the records this account failed to send in a row, for
+	// a nonce conflict or a node failure
+	failures int
 }
 
+// maxAccountFailures is how many records in a row an account may fail to send
+// before it is taken out of rotation
+const maxAccountFailures = 3
+
 type analyserData struct {
 	EpochNumber  uint64 `json:"epochNumber"`
 	DelegateName string `json:"delegateName"`
@@ -77,92 +74,171 @@ type analyserData struct {
 	Amount       string `json:"amount"`
 }
 
-var bucketStateMap = make(map[uint64]bool)
-
-func (s *accountSender) send() {
-	tls := util.MustFetchNonEmptyParam("RPC_TLS")
-	endpoint := util.MustFetchNonEmptyParam("IO_ENDPOINT")
+// bucketStateMap caches the auto stake state of buckets, it is shared by the
+// sender shards
+var (
+	bucketStateMap = make(map[uint64]bool)
+	bucketStateMu  sync.Mutex
+)
 
-	var conn *grpc.ClientConn
-	var err error
+// depositPolling is how long a deposit or transfer receipt is waited for
+var depositPolling = chain.Polling{
+	Delay:    5 * time.Second,
+	Interval: time.Second,
+	Attempts: 30,
+}
 
-	if tls == "true" {
-		conn, err = iotex.NewDefaultGRPCConn(endpoint)
-		if err != nil {
-			log.Fatalf("create grpc error: %v", err)
-		}
-	} else {
-		conn, err = iotex.NewGRPCConnWithoutTLS(endpoint)
-		if err != nil {
-			log.Fatalf("create grpc error: %v", err)
+// process sends one record. It returns an error when the account can't send,
+// the record is then left to another account.
+func (s *accountSender) process(ctx context.Context, record dao.DropRecord) error {
+	if err := record.Verify(); err != nil {
+		if errors.Is(err, dao.ErrSignatureVersion) {
+			// left new for migrate-signatures
+			log.Printf("skip drop record %d: %v\n", record.ID, err)
+			return nil
 		}
+		invalidRecord(s.notifier, record)
+		return nil
 	}
-	defer conn.Close()
-	client := iotex.NewAuthedClient(iotexapi.NewAPIServiceClient(conn), 1, s.account)
-
-	for _, record := range s.records {
-		if record.Verify() != nil {
-			record.Status = "error_signature"
-			err = record.Save(dao.DB())
-			if err != nil {
-				log.Fatalf("save drop records error: %v", err)
-			}
-			continue
-		}
-		amount, ok := big.NewInt(0).SetString(record.Amount, 10)
-		if !ok {
-			log.Printf("can't convert staking amount: %v\n", record.Amount)
-		}
-		h, ignore, ra, err := addDepositOrTransfer(client, record.ID, record.Index, record.Voter, record.DelegateName, amount)
+	amount, ok := big.NewInt(0).SetString(record.Amount, 10)
+	if !ok {
+		log.Printf("can't convert staking amount: %v\n", record.Amount)
+	}
+	// the signed action is recorded before it is broadcast, so a crash can't
+	// leave it sent with the record still new
+	submit := func(signed *chain.SignedAction) {
+		data, err := signed.Bytes()
 		if err != nil {
-			if ignore {
-				if strings.HasSuffix(err.Error(), "insufficient funds for gas * price + value") {
-					s.notifier.SendMessage(fmt.Sprintf("Deposit %d error: %v", record.ID, err))
-					time.Sleep(30 * time.Minute)
-					break
-				}
-				if !strings.HasSuffix(err.Error(), "exceeds block gas limit") {
-					log.Printf("add deposit %d with ignore error: %v\n", record.ID, err)
-				}
-
-				continue
-			} else {
-				log.Printf("add deposit %d error: %v\n", record.ID, err)
-				if !strings.HasPrefix(err.Error(), "add deposit error by exhausted retry") {
-					s.notifier.SendMessage(fmt.Sprintf("Deposit %d error: %v", record.ID, err))
-				}
-				record.Status = "error"
-				record.ErrorMessage = err.Error()
-				err = record.Save(dao.DB())
-				if err != nil {
-					log.Fatalf("save error drop records %d:%s error: %v", record.ID, record.Voter, err)
-				}
-			}
+			log.Fatalf("encode action of drop record %d error: %v", record.ID, err)
 		}
-		record.Hash = hex.EncodeToString(h[:])
+		record.Status = "submitted"
+		record.Hash = hex.EncodeToString(signed.Hash[:])
+		record.Nonce = signed.Nonce()
+		record.Sender = s.client.Address().String()
+		record.SignedAction = hex.EncodeToString(data)
 		record.Signature = ""
-		record.Status = "completed"
-		err = record.Save(dao.DB())
-		if err != nil {
-			log.Fatalf("save success drop records %d:%s error: %v", record.ID, record.Voter, err)
+		if err := record.Save(dao.DB()); err != nil {
+			log.Fatalf("save submitted drop records %d:%s error: %v", record.ID, record.Voter, err)
 		}
-
-		ad := analyserData{
-			EpochNumber:  record.EndEpoch,
-			DelegateName: record.DelegateName,
-			VoterAddress: record.Voter,
-			BucketID:     record.Index,
-			ActHash:      record.Hash,
-			Amount:       ra.String(),
+	}
+	h, ignore, ra, gasConsumed, err := addDepositOrTransfer(s.client, record.ID, record.Index, record.Voter, record.DelegateName, amount, submit)
+	if err != nil && ignore && record.Status == "submitted" {
+		// the node refused the action, nothing was sent
+		record = resetRecord(record)
+	}
+	if err != nil {
+		switch {
+		case ignore && (errors.Is(err, chain.ErrInsufficientFunds) || errors.Is(err, chain.ErrGasPriceTooHigh)):
+			// the account can't send at all, another one takes the record
+			s.notifier.Criticalf("Deposit %d error: %v", record.ID, err)
+			return err
+		case ignore && (errors.Is(err, errRecord) || errors.Is(err, chain.ErrGasLimit)):
+			// any account would fail the same way, the record isn't queued again
+			log.Printf("add deposit %d error: %v\n", record.ID, err)
+			failRecord(s.notifier, record, err, 0)
+		case ignore:
+			// a nonce conflict or a node failure, the record stays new
+			log.Printf("add deposit %d with ignore error: %v\n", record.ID, err)
+			s.failures++
+			if s.failures >= maxAccountFailures {
+				s.failures = 0
+				return fmt.Errorf("%d records failed in a row, last: %v", maxAccountFailures, err)
+			}
+		case errors.Is(err, errUnsettled):
+			// the action may still be minted, the record stays submitted until
+			// resolveSubmitted finds its receipt
+			log.Printf("add deposit %d error: %v\n", record.ID, err)
+		default:
+			log.Printf("add deposit %d error: %v\n", record.ID, err)
+			failRecord(s.notifier, record, err, gasConsumed)
 		}
-		postAnalyserData(&ad)
+		return nil
+	}
+	s.failures = 0
+	if ignore {
+		// the amount doesn't cover the gas, nothing was sent
+		skipRecord(record)
+		return nil
 	}
+	record.Hash = hex.EncodeToString(h[:])
+	completeRecord(s.notifier, record, ra, gasConsumed)
+	return nil
+}
+
+// invalidRecord marks a record whose signature doesn't verify
+func invalidRecord(notifier *notify.Alerter, record dao.DropRecord) {
+	record.Status = "error_signature"
+	err := record.Save(dao.DB())
+	if err != nil {
+		log.Fatalf("save drop records error: %v", err)
+	}
+	metrics.Records.WithLabelValues(record.Status).Inc()
+	notifier.Criticalf("Drop record %d of voter %s has invalid signature", record.ID, record.Voter)
+}
+
+// resetRecord makes a record whose action was not sent new again
+func resetRecord(record dao.DropRecord) dao.DropRecord {
+	record.Status = "new"
+	record.Hash = ""
+	record.Nonce = 0
+	record.Sender = ""
+	record.SignedAction = ""
+	record.Signature = ""
+	if err := record.Save(dao.DB()); err != nil {
+		log.Fatalf("save new drop records %d:%s error: %v", record.ID, record.Voter, err)
+	}
+	return record
+}
+
+// failRecord marks a record whose action failed on chain
+func failRecord(notifier *notify.Alerter, record dao.DropRecord, cause error, gasConsumed uint64) {
+	notifier.Errorf("Deposit %d error: %v", record.ID, cause)
+	record.Status = "error"
+	record.ErrorMessage = cause.Error()
+	record.GasConsumed = gasConsumed
+	record.Signature = ""
+	err := record.Save(dao.DB())
+	if err != nil {
+		log.Fatalf("save error drop records %d:%s error: %v", record.ID, record.Voter, err)
+	}
+	metrics.Records.WithLabelValues(record.Status).Inc()
+}
+
+// skipRecord completes a record whose amount doesn't cover the gas with nothing
+// deposited. It only has the hash of a deposit that failed before, if any.
+func skipRecord(record dao.DropRecord) {
+	record.Deposited = "0"
+	record.Signature = ""
+	record.Status = "completed"
+	if err := record.Save(dao.DB()); err != nil {
+		log.Fatalf("save skipped drop records %d:%s error: %v", record.ID, record.Voter, err)
+	}
+	metrics.Records.WithLabelValues(record.Status).Inc()
+}
 
-	s.records = nil
-	if s.waitGroup != nil {
-		s.waitGroup.Done()
-		s.waitGroup = nil
+// completeRecord marks a record paid with ra by the action of record.Hash
+func completeRecord(notifier *notify.Alerter, record dao.DropRecord, ra *big.Int, gasConsumed uint64) {
+	record.GasConsumed = gasConsumed
+	record.Deposited = ra.String()
+	record.Signature = ""
+	record.Status = "completed"
+	err := record.Save(dao.DB())
+	if err != nil {
+		log.Fatalf("save success drop records %d:%s error: %v", record.ID, record.Voter, err)
+	}
+	metrics.Records.WithLabelValues(record.Status).Inc()
+	metrics.Compounded.Add(metrics.IOTX(ra))
+	notifier.Sent(1, ra)
+
+	ad := analyserData{
+		EpochNumber:  record.EndEpoch,
+		DelegateName: record.DelegateName,
+		VoterAddress: record.Voter,
+		BucketID:     record.Index,
+		ActHash:      record.Hash,
+		Amount:       ra.String(),
 	}
+	postAnalyserData(&ad)
 }
 
 func postAnalyserData(ad *analyserData) {
@@ -204,166 +280,241 @@ func postAnalyserData(ad *analyserData) {
 	}
 }
 
-func checkAutoStake(c iotex.AuthedClient, bucketID uint64) (bool, error) {
+func checkAutoStake(c chain.Client, bucketID uint64) (bool, error) {
+	bucketStateMu.Lock()
 	state, ok := bucketStateMap[bucketID]
+	bucketStateMu.Unlock()
 	if ok {
 		return state, nil
 	}
 
-	method := &iotexapi.ReadStakingDataMethod{
-		Method: iotexapi.ReadStakingDataMethod_BUCKETS_BY_INDEXES,
-	}
-	methodBytes, err := proto.Marshal(method)
-	if err != nil {
-		return false, err
-	}
-	arguments := &iotexapi.ReadStakingDataRequest{
-		Request: &iotexapi.ReadStakingDataRequest_BucketsByIndexes{
-			BucketsByIndexes: &iotexapi.ReadStakingDataRequest_VoteBucketsByIndexes{
-				Index: []uint64{bucketID},
-			},
-		},
+	bucket, err := c.Bucket(context.Background(), bucketID)
+	if err == chain.ErrNotFound {
+		return false, fmt.Errorf("can't find bucket %d", bucketID)
 	}
-	argumentsBytes, err := proto.Marshal(arguments)
 	if err != nil {
 		return false, err
 	}
+	bucketStateMu.Lock()
+	bucketStateMap[bucketID] = bucket.AutoStake
+	bucketStateMu.Unlock()
+	return bucket.AutoStake, nil
+}
 
-	res, err := c.API().ReadState(context.Background(), &iotexapi.ReadStateRequest{
-		ProtocolID: []byte("staking"),
-		MethodName: methodBytes,
-		Arguments:  [][]byte{argumentsBytes},
-		Height:     "",
-	})
-	if err != nil {
-		return false, err
-	}
-	var result iotextypes.VoteBucketList
-	err = proto.Unmarshal(res.Data, &result)
+// errRecord is a record no action can be made for, sending it again fails the
+// same way whichever account sends it
+var errRecord = errors.New("record can't be sent")
+
+// errUnsettled is an action sent whose receipt couldn't be fetched, it may
+// still be minted
+var errUnsettled = errors.New("action unsettled")
+
+// addDepositOrTransfer deposits amount, less the gas, to the bucket of voter, or
+// transfers it to voter when the bucket doesn't auto stake. A deposit to a
+// bucket found invalid on chain is sent again once, as a transfer.
+func addDepositOrTransfer(
+	c chain.Client,
+	recordID uint,
+	bucketID uint64,
+	voter string,
+	delegateName string,
+	amount *big.Int,
+	submit func(*chain.SignedAction),
+) (hash.Hash256, bool, *big.Int, uint64, error) {
+	autoStake, err := checkAutoStake(c, bucketID)
 	if err != nil {
-		return false, err
+		log.Printf("check auto stake bucket error: %v", err)
 	}
-	if len(result.Buckets) == 0 {
-		return false, fmt.Errorf("can't find bucket %d", bucketID)
+	h, ignore, ra, gasConsumed, err := sendDepositOrTransfer(c, recordID, bucketID, voter, amount, autoStake, submit)
+	if autoStake && errors.Is(err, chain.ErrBucketInvalid) {
+		// the bucket stopped auto staking
+		bucketStateMu.Lock()
+		delete(bucketStateMap, bucketID)
+		bucketStateMu.Unlock()
+		return sendDepositOrTransfer(c, recordID, bucketID, voter, amount, false, submit)
 	}
-	bucketStateMap[bucketID] = result.Buckets[0].AutoStake
-	return result.Buckets[0].AutoStake, nil
+	return h, ignore, ra, gasConsumed, err
 }
 
-func addDepositOrTransfer(
-	c iotex.AuthedClient,
+// accountError reports whether err is a failure of the sending account or of
+// the node rather than of the record
+func accountError(err error) bool {
+	return errors.Is(err, chain.ErrRetryable) || errors.Is(err, chain.ErrNonceConflict) ||
+		errors.Is(err, chain.ErrInsufficientFunds) || errors.Is(err, context.Canceled)
+}
+
+// sendDepositOrTransfer sends amount, less the gas, to voter as a deposit or a
+// transfer and waits for its receipt
+func sendDepositOrTransfer(
+	c chain.Client,
 	recordID uint,
 	bucketID uint64,
 	voter string,
-	delegateName string,
 	amount *big.Int,
-) (hash.Hash256, bool, *big.Int, error) {
+	autoStake bool,
+	submit func(*chain.SignedAction),
+) (hash.Hash256, bool, *big.Int, uint64, error) {
 	ctx := context.Background()
 
-	gasPriceStr := util.MustFetchNonEmptyParam("GAS_PRICE")
-	gasPrice, ok := big.NewInt(0).SetString(gasPriceStr, 10)
-	if !ok {
-		return hash.ZeroHash256, true, nil, errors.New("failed to convert string to big int")
+	to, _ := address.FromString(voter)
+	var (
+		estimate uint64
+		err      error
+	)
+	if !autoStake {
+		estimate, err = c.EstimateTransfer(ctx, to, amount)
+	} else {
+		estimate, err = c.EstimateAddDeposit(ctx, bucketID, amount)
 	}
-	// TODO change to 10000?
-	gasLimit := 13000
-
-	gas := big.NewInt(0).Mul(gasPrice, big.NewInt(int64(gasLimit)))
-	if amount.Cmp(gas) <= 0 {
-		log.Printf("amount %s less than gas for %d\n", amount.String(), recordID)
-		return hash.ZeroHash256, true, nil, nil
+	if err != nil {
+		if accountError(err) {
+			return hash.ZeroHash256, true, nil, 0, fmt.Errorf("estimate gas error: %w", err)
+		}
+		return hash.ZeroHash256, true, nil, 0, fmt.Errorf("estimate gas error: %w: %w", err, errRecord)
+	}
+	limit, err := gasLimit(estimate)
+	if err != nil {
+		return hash.ZeroHash256, true, nil, 0, fmt.Errorf("%w: %w", err, errRecord)
 	}
 
-	autoStake, err := checkAutoStake(c, bucketID)
+	gasPrice, err := chain.GasPrice(ctx, c, config.Get().Gas)
 	if err != nil {
-		log.Printf("check auto stake bucket error: %v", err)
+		return hash.ZeroHash256, true, nil, 0, err
+	}
+	gas := big.NewInt(0).Mul(gasPrice, new(big.Int).SetUint64(estimate))
+	if amount.Cmp(gas) <= 0 {
+		log.Printf("amount %s less than gas for %d\n", amount.String(), recordID)
+		return hash.ZeroHash256, true, nil, 0, nil
 	}
 
-	var h hash.Hash256
+	var signed *chain.SignedAction
 	ra := big.NewInt(0).Sub(amount, gas)
+	opts := chain.Opts{GasPrice: gasPrice, GasLimit: limit}
 	if !autoStake {
-		to, _ := address.FromString(voter)
-		h, err = c.Transfer(to, ra).SetGasPrice(gasPrice).SetGasLimit(uint64(gasLimit)).Call(ctx)
+		signed, err = c.SignTransfer(ctx, to, ra, opts)
 	} else {
-		h, err = c.Staking().AddDeposit(bucketID, ra).SetGasPrice(gasPrice).SetGasLimit(uint64(gasLimit)).Call(ctx)
+		signed, err = c.SignAddDeposit(ctx, bucketID, ra, opts)
 	}
-
 	if err != nil {
-		return hash.ZeroHash256, true, nil, err
+		return hash.ZeroHash256, true, nil, 0, err
 	}
-	time.Sleep(5 * time.Second)
-
-	for i := 0; i < 30; i++ {
-		resp, err := c.API().GetReceiptByAction(ctx, &iotexapi.GetReceiptByActionRequest{
-			ActionHash: hex.EncodeToString(h[:]),
-		})
-		if err != nil {
-			if strings.Contains(err.Error(), "code = NotFound") {
-				time.Sleep(1 * time.Second)
-				continue
-			}
-			return h, false, nil, err
-		}
-		if resp.ReceiptInfo.Receipt.Status == 204 {
-			delete(bucketStateMap, bucketID)
-			return addDepositOrTransfer(c, recordID, bucketID, voter, delegateName, amount)
-		}
-		if resp.ReceiptInfo.Receipt.Status != 1 {
-			return h, false, nil, errors.Errorf("add deposit staking failed: %x", h)
+	if submit != nil {
+		submit(signed)
+	}
+	h := signed.Hash
+	if err := c.SendAction(ctx, signed); err != nil {
+		if !errors.Is(err, chain.ErrRetryable) {
+			return hash.ZeroHash256, true, nil, 0, err
 		}
-		return h, false, ra, nil
+		// the node may have taken the action, its receipt is looked for
+		log.Printf("send action %x error: %v\n", h, err)
+	}
+
+	receipt, err := chain.WaitReceipt(ctx, c, h, depositPolling)
+	if err != nil {
+		return h, false, nil, 0, fmt.Errorf("wait receipt of %x, index=%d error: %w: %w", h, bucketID, err, errUnsettled)
+	}
+	if err := chain.ReceiptError(h, receipt); err != nil {
+		return h, false, nil, receipt.GasConsumed, fmt.Errorf("add deposit staking failed: %w", err)
 	}
-	return h, false, nil, errors.Errorf("add deposit error by exhausted retry, index=%d, hash: %x", bucketID, h)
+	return h, false, ra, receipt.GasConsumed, nil
 }
 
-// Send send records
-func (s *Sender) Send() {
+// pendingPolling is how long the sender waits when every new record is still
+// in flight
+var pendingPolling = 10 * time.Second
+
+// Send send records until ctx is done. The accounts pull records from a shared
+// queue, and the next batch is fetched once the queue is empty while the last
+// records of the previous one may still be in flight.
+func (s *Sender) Send(ctx context.Context) {
 	fmt.Println("Begin add deposit to bucket")
-	for {
+	q := newWorkQueue()
+	wg := sync.WaitGroup{}
+	for _, c := range s.clients {
+		sender := &accountSender{client: c, notifier: s.Notifier}
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			work(ctx, q, name, s.Notifier, sender.process)
+		}(c.Address().String())
+	}
+	go func() {
+		<-ctx.Done()
+		q.close()
+	}()
+
+	for q.waitEmpty() {
+		s.updateBalances(ctx)
+		resolveSubmitted(ctx, s.clients, s.Notifier, q.isPending)
 		records, err := dao.FindNewDropRecordByLimit(10000)
 		if err != nil {
 			log.Fatalf("query drop records error: %v", err)
 		}
+		if count, err := dao.CountDropRecordByStatus("new"); err == nil {
+			metrics.QueueDepth.Set(float64(count))
+		}
 		if len(records) == 0 {
-			time.Sleep(5 * time.Minute)
+			util.Sleep(ctx, 5*time.Minute)
 			continue
 		}
-		s.Notifier.SendMessage(fmt.Sprintf("Begin send %d compound hermes rewards", len(records)))
-
-		shard := len(s.Accounts)
-		if len(records) < shard || shard == 1 {
-			sender := &accountSender{
-				account:  s.Accounts[0],
-				records:  records,
-				notifier: s.Notifier,
-			}
-			sender.send()
-		} else {
-			wg := sync.WaitGroup{}
-			wg.Add(shard)
-			size := len(records) / shard
-			for i := 0; i < shard; i++ {
-				end := size * (i + 1)
-				if i == shard-1 {
-					end = len(records)
-				}
-				sender := &accountSender{
-					account:   s.Accounts[i],
-					records:   records[i*size : end],
-					waitGroup: &wg,
-					notifier:  s.Notifier,
-				}
-				go sender.send()
-			}
-			wg.Wait()
+		queued := q.push(records)
+		if queued == 0 {
+			util.Sleep(ctx, pendingPolling)
+			continue
+		}
+		s.Notifier.Infof("Begin send %d compound hermes rewards", queued)
+	}
+	wg.Wait()
+}
+
+func (s *Sender) updateBalances(ctx context.Context) {
+	for _, c := range s.clients {
+		if balance, err := c.Balance(ctx, c.Address()); err == nil {
+			metrics.Balance.WithLabelValues(c.Address().String()).Set(metrics.IOTX(balance))
 		}
 	}
 }
 
+// Unfunded returns the addresses of the sender accounts holding less than minimum
+func (s *Sender) Unfunded(ctx context.Context, minimum *big.Int) ([]string, error) {
+	var unfunded []string
+	for _, c := range s.clients {
+		balance, err := c.Balance(ctx, c.Address())
+		if err != nil {
+			return nil, fmt.Errorf("get balance of %s error: %v", c.Address().String(), err)
+		}
+		metrics.Balance.WithLabelValues(c.Address().String()).Set(metrics.IOTX(balance))
+		if balance.Cmp(minimum) < 0 {
+			unfunded = append(unfunded, fmt.Sprintf("%s (%s)", c.Address().String(), balance.String()))
+		}
+	}
+	return unfunded, nil
+}
+
 // NewSender new sender instance
-func NewSender(notifier *Notifier, accounts []account.Account) (*Sender, error) {
+func NewSender(notifier *notify.Alerter, accounts []account.Account) (*Sender, error) {
+	if len(accounts) == 0 {
+		return nil, errors.New("no sender account")
+	}
+	seen := make(map[string]bool)
+	for _, acc := range accounts {
+		if seen[acc.Address().String()] {
+			return nil, fmt.Errorf("account %s is given twice", acc.Address().String())
+		}
+		seen[acc.Address().String()] = true
+	}
+	conn, err := chain.Dial(config.Get().Chain)
+	if err != nil {
+		return nil, fmt.Errorf("create grpc error: %v", err)
+	}
+	clients := make([]chain.Client, len(accounts))
+	for i, acc := range accounts {
+		clients[i] = chain.NewClient(conn, acc)
+	}
 	return &Sender{
 		Accounts: accounts,
 		Notifier: notifier,
+		clients:  clients,
 	}, nil
 }
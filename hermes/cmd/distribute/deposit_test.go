@@ -0,0 +1,212 @@
+package distribute
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/iotexproject/iotex-antenna-go/v2/account"
+	"github.com/iotexproject/iotex-proto/golang/iotextypes"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ququzone/hermes-patch/hermes/chain"
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+const testVoterPrivateKey = "b000000000000000000000000000000000000000000000000000000000000000"
+
+func TestAddDepositOrTransfer(t *testing.T) {
+	require := require.New(t)
+
+	fake, c := newFakeChain(t)
+	voter, err := account.HexStringToAccount(testVoterPrivateKey)
+	require.NoError(err)
+	fake.SetBalance(c.Address(), big.NewInt(1000000000000000000))
+	fake.SetBucket(&iotextypes.VoteBucket{Index: 7, Owner: voter.Address().String(), AutoStake: true, StakedAmount: "100"})
+	fake.SetBucket(&iotextypes.VoteBucket{Index: 8, Owner: voter.Address().String(), AutoStake: false})
+
+	amount := big.NewInt(100000000000000000)
+	gas := new(big.Int).Mul(big.NewInt(1000000000000), new(big.Int).SetUint64(chain.FakeActionGas))
+	expected := new(big.Int).Sub(amount, gas)
+
+	t.Run("auto stake bucket gets a deposit", func(t *testing.T) {
+		h, ignore, ra, gasConsumed, err := addDepositOrTransfer(c, 1, 7, voter.Address().String(), "delegate", amount, nil)
+		require.NoError(err)
+		require.False(ignore)
+		require.Equal(expected, ra)
+		require.Equal(chain.FakeActionGas, gasConsumed)
+		require.Equal(chain.FakeActionGas*12/10, fake.Action(h).GasLimit)
+		require.NotNil(fake.Action(h).GetStakeAddDeposit())
+		require.Equal(new(big.Int).Add(expected, big.NewInt(100)).String(), fake.BucketOf(7).StakedAmount)
+	})
+
+	t.Run("other bucket gets a transfer", func(t *testing.T) {
+		h, ignore, ra, _, err := addDepositOrTransfer(c, 2, 8, voter.Address().String(), "delegate", amount, nil)
+		require.NoError(err)
+		require.False(ignore)
+		require.Equal(expected, ra)
+		require.Equal(voter.Address().String(), fake.Action(h).GetTransfer().Recipient)
+		require.Equal(expected, fake.BalanceOf(voter.Address()))
+	})
+
+	t.Run("bucket no longer auto staking falls back to transfer", func(t *testing.T) {
+		fake.SetBucket(&iotextypes.VoteBucket{Index: 7, Owner: voter.Address().String(), AutoStake: false})
+		nonce := fake.Nonce(c.Address())
+		h, _, ra, _, err := addDepositOrTransfer(c, 3, 7, voter.Address().String(), "delegate", amount, nil)
+		require.NoError(err)
+		require.Equal(expected, ra)
+		require.NotNil(fake.Action(h).GetTransfer())
+		require.Equal(nonce+2, fake.Nonce(c.Address()))
+	})
+
+	t.Run("transfer fallback is sent once", func(t *testing.T) {
+		fake.SetBucket(&iotextypes.VoteBucket{Index: 9, Owner: voter.Address().String(), AutoStake: true})
+		fake.FailNext(iotextypes.ReceiptStatus_ErrInvalidBucketType)
+		fake.FailNext(iotextypes.ReceiptStatus_ErrInvalidBucketType)
+		nonce := fake.Nonce(c.Address())
+		_, ignore, _, _, err := addDepositOrTransfer(c, 3, 9, voter.Address().String(), "delegate", amount, nil)
+		require.ErrorIs(err, chain.ErrBucketInvalid)
+		require.False(ignore)
+		require.Equal(nonce+2, fake.Nonce(c.Address()))
+	})
+
+	t.Run("failed receipt", func(t *testing.T) {
+		fake.FailNext(iotextypes.ReceiptStatus_ErrExecutionReverted)
+		_, ignore, _, _, err := addDepositOrTransfer(c, 4, 8, voter.Address().String(), "delegate", amount, nil)
+		require.ErrorIs(err, chain.ErrReverted)
+		require.False(ignore)
+	})
+
+	t.Run("receipt never minted", func(t *testing.T) {
+		fake.DelayNext(-1)
+		_, ignore, _, _, err := addDepositOrTransfer(c, 5, 8, voter.Address().String(), "delegate", amount, nil)
+		require.ErrorIs(err, errUnsettled)
+		require.ErrorIs(err, chain.ErrNotFound)
+		require.False(ignore)
+	})
+
+	t.Run("amount below gas is skipped", func(t *testing.T) {
+		nonce := fake.Nonce(c.Address())
+		_, ignore, _, _, err := addDepositOrTransfer(c, 6, 8, voter.Address().String(), "delegate", gas, nil)
+		require.NoError(err)
+		require.True(ignore)
+		require.Equal(nonce, fake.Nonce(c.Address()))
+	})
+
+	t.Run("gas price above the ceiling is refused", func(t *testing.T) {
+		require.NoError(config.Get().Gas.Ceiling.Set("2000000000000"))
+		defer config.Get().Gas.Ceiling.Set("")
+		fake.SetGasPrice(big.NewInt(3000000000000))
+		defer fake.SetGasPrice(big.NewInt(chain.FakeGasPrice))
+		nonce := fake.Nonce(c.Address())
+		_, ignore, _, _, err := addDepositOrTransfer(c, 7, 8, voter.Address().String(), "delegate", amount, nil)
+		require.True(errors.Is(err, chain.ErrGasPriceTooHigh))
+		require.True(ignore)
+		require.Equal(nonce, fake.Nonce(c.Address()))
+	})
+
+	t.Run("insufficient funds", func(t *testing.T) {
+		fake.SetBalance(c.Address(), big.NewInt(0))
+		_, ignore, _, _, err := addDepositOrTransfer(c, 7, 8, voter.Address().String(), "delegate", amount, nil)
+		require.Equal(chain.ErrInsufficientFunds, err)
+		require.True(ignore)
+	})
+}
+
+func TestProcessSignature(t *testing.T) {
+	require := require.New(t)
+
+	fake, c := newFakeChain(t)
+	setTestDatabase(t)
+	fake.SetBalance(c.Address(), big.NewInt(1000000000000000000))
+	s := &accountSender{client: c}
+	status := func(id uint) string {
+		var record dao.DropRecord
+		require.NoError(dao.DB().First(&record, id).Error)
+		return record.Status
+	}
+
+	// an older version is left new for migrate-signatures
+	older := dao.DropRecord{EndEpoch: 1, DelegateName: "delegate", Voter: "io1voter", Amount: "100", Status: "new",
+		Signature: "signature", SignatureVersion: 2}
+	require.NoError(dao.DB().Create(&older).Error)
+	require.NoError(s.process(context.Background(), older))
+	require.Equal("new", status(older.ID))
+
+	forged := dao.DropRecord{EndEpoch: 2, DelegateName: "delegate", Voter: "io1voter", Amount: "100", Status: "new",
+		Signature: "signature", SignatureVersion: dao.SignatureVersion}
+	require.NoError(dao.DB().Create(&forged).Error)
+	require.NoError(s.process(context.Background(), forged))
+	require.Equal("error_signature", status(forged.ID))
+	require.Zero(fake.Nonce(c.Address()))
+}
+
+func TestProcessFailures(t *testing.T) {
+	require := require.New(t)
+
+	fake, c := newFakeChain(t)
+	setTestDatabase(t)
+	voter, err := account.HexStringToAccount(testVoterPrivateKey)
+	require.NoError(err)
+	fake.SetBalance(c.Address(), big.NewInt(1000000000000000000))
+	for i := 1; i <= 4; i++ {
+		require.NoError(dao.DropRecord{EndEpoch: uint64(i), DelegateName: "delegate", Voter: voter.Address().String(),
+			Index: 8, Amount: "100000000000000000", Status: "new"}.Save(nil))
+	}
+	records, err := dao.FindNewDropRecordByLimit(10)
+	require.NoError(err)
+	require.Len(records, 4)
+	s := &accountSender{client: c}
+	status := func(id uint) string {
+		var record dao.DropRecord
+		require.NoError(dao.DB().First(&record, id).Error)
+		return record.Status
+	}
+
+	// a record needing more gas than the limit fails without counting against the account
+	cfg := config.Get()
+	cfg.Gas.Limit = chain.FakeActionGas - 1
+	for _, record := range records[:3] {
+		require.NoError(s.process(context.Background(), record))
+		require.Equal("error", status(record.ID))
+	}
+	require.Zero(s.failures)
+	cfg.Gas.Limit = 5000000
+
+	// nonce conflicts count against the account, the records stay new
+	for i := 0; i < maxAccountFailures; i++ {
+		fake.RejectNext(chain.ErrNonceTooLow)
+	}
+	require.NoError(s.process(context.Background(), records[3]))
+	require.Equal("new", status(records[3].ID))
+	require.Equal(1, s.failures)
+	require.NoError(s.process(context.Background(), records[3]))
+	require.ErrorContains(s.process(context.Background(), records[3]), "records failed in a row")
+	require.Equal("new", status(records[3].ID))
+	require.Zero(fake.Nonce(c.Address()))
+}
+
+func TestSenderUnfunded(t *testing.T) {
+	require := require.New(t)
+
+	fake, c := newFakeChain(t)
+	voter, err := account.HexStringToAccount(testVoterPrivateKey)
+	require.NoError(err)
+	other := fake.Client(voter.Address())
+	fake.SetBalance(c.Address(), big.NewInt(1000))
+	fake.SetBalance(other.Address(), big.NewInt(999))
+
+	s := &Sender{clients: []chain.Client{c, other}}
+	unfunded, err := s.Unfunded(context.Background(), big.NewInt(1000))
+	require.NoError(err)
+	require.Equal([]string{other.Address().String() + " (999)"}, unfunded)
+
+	_, err = NewSender(nil, nil)
+	require.Error(err)
+	acc, err := account.HexStringToAccount(testPrivateKey)
+	require.NoError(err)
+	_, err = NewSender(nil, []account.Account{acc, acc})
+	require.ErrorContains(err, "given twice")
+}
@@ -8,32 +8,27 @@ package distribute
 
 import (
 	"context"
-	"encoding/hex"
 	"fmt"
 	"math/big"
 	"sort"
-	"strconv"
 	"strings"
-	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/iotexproject/go-pkgs/hash"
 	"github.com/iotexproject/iotex-address/address"
 	"github.com/iotexproject/iotex-antenna-go/v2/account"
-	"github.com/iotexproject/iotex-antenna-go/v2/iotex"
-	"github.com/iotexproject/iotex-proto/golang/iotexapi"
 	"github.com/iotexproject/iotex-proto/golang/iotextypes"
 	"github.com/jinzhu/gorm"
 	"github.com/pkg/errors"
 	"github.com/shurcooL/graphql"
 	"github.com/spf13/cobra"
 	"golang.org/x/oauth2"
-	"google.golang.org/grpc"
-	"google.golang.org/protobuf/proto"
 
+	"github.com/ququzone/hermes-patch/hermes/chain"
 	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
-	"github.com/ququzone/hermes-patch/hermes/util"
+	"github.com/ququzone/hermes-patch/hermes/config"
+	"github.com/ququzone/hermes-patch/hermes/notify"
 )
 
 // DistributeCmd is the distribute command
@@ -43,7 +38,10 @@ var DistributeCmd = &cobra.Command{
 	Args:  cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cmd.SilenceUsage = true
-		return Reward(nil, nil, nil, 0, nil)
+		if err := config.Init(""); err != nil {
+			return err
+		}
+		return Reward(cmd.Context(), nil, nil, nil, 0, nil)
 	},
 }
 
@@ -53,269 +51,328 @@ type DistributionInfo struct {
 	RecipientList []common.Address
 	Total         *big.Int
 	AmountList    []*big.Int
+	ServiceFee    *big.Int
 }
 
-func Merge(notifier *Notifier, acc account.Account, sender address.Address, previous *big.Int) error {
-	tls := util.MustFetchNonEmptyParam("RPC_TLS")
-	endpoint := util.MustFetchNonEmptyParam("IO_ENDPOINT")
-	var conn *grpc.ClientConn
-	var err error
-
-	if tls == "true" {
-		conn, err = iotex.NewDefaultGRPCConn(endpoint)
-		if err != nil {
-			return err
-		}
-	} else {
-		conn, err = iotex.NewGRPCConnWithoutTLS(endpoint)
-		if err != nil {
-			return err
-		}
-	}
-	defer conn.Close()
-	c := iotex.NewAuthedClient(iotexapi.NewAPIServiceClient(conn), 1, acc)
-
-	total, err := mergeCompound()
+// Merge transfers the compound rewards and previous to the sender, once the
+// transfer is sent its receipt is waited for even if ctx is done
+func Merge(ctx context.Context, notifier *notify.Alerter, acc account.Account, sender address.Address, previous *big.Int, reference string) error {
+	conn, err := chain.Dial(config.Get().Chain)
 	if err != nil {
 		return err
 	}
+	defer conn.Close()
+	c := chain.NewClient(conn, acc)
 
-	total = new(big.Int).Add(total, previous)
-	accountInfo, err := c.API().GetAccount(context.Background(), &iotexapi.GetAccountRequest{
-		Address: c.Account().Address().String(),
-	})
-	if err != nil {
+	if err := fundSender(ctx, notifier, c, sender, previous, reference); err != nil {
 		return err
 	}
-	balance, _ := new(big.Int).SetString(accountInfo.AccountMeta.Balance, 10)
-	if balance.Cmp(total) < 0 {
-		fmt.Printf("Account balance less than compound rewards: %s < %s\n", balance.String(), total.String())
-		if notifier != nil {
-			notifier.SendMessage(fmt.Sprintf("Account balance less than compound rewards: %s < %s", balance.String(), total.String()))
-		}
-		total = new(big.Int).Sub(balance, big.NewInt(1000000000000000000))
-	}
-	hash, _ := c.Transfer(sender, total).SetGasPrice(big.NewInt(1000000000000)).SetGasLimit(10000).Call(context.Background())
-	if notifier != nil {
-		notifier.SendMessage(fmt.Sprintf("transfer %s to compound sender with hash: %s", total.String(), hex.EncodeToString(hash[:])))
-	}
-	time.Sleep(20 * time.Second)
-	err = checkActionReceipt(c, hash)
-	if err != nil {
-		if notifier != nil {
-			notifier.SendMessage(fmt.Sprintf("send transfer sender action %s error: %v", hex.EncodeToString(hash[:]), err))
-		}
-	}
 
 	if notifier != nil {
-		notifier.SendMessage("Complete merge hermes rewards")
+		notifier.Infof("Complete merge hermes rewards")
 	}
 	return nil
 }
 
-// Reward distribute reward to voter group by delegate
-func Reward(notifier *Notifier, acc account.Account, lastDeposit *big.Int, lastEpoch uint64, sender address.Address) error {
-	tls := util.MustFetchNonEmptyParam("RPC_TLS")
-	endpoint := util.MustFetchNonEmptyParam("IO_ENDPOINT")
-	var conn *grpc.ClientConn
-	var err error
-
-	if tls == "true" {
-		conn, err = iotex.NewDefaultGRPCConn(endpoint)
-		if err != nil {
-			return err
-		}
-	} else {
-		conn, err = iotex.NewGRPCConnWithoutTLS(endpoint)
-		if err != nil {
-			return err
-		}
+// Reward distribute reward to voter group by delegate. Once ctx is done no
+// more chunks are sent and ctx.Err() is returned after the chunks in flight
+// are settled, but a run whose distributions are committed is finished.
+func Reward(ctx context.Context, notifier *notify.Alerter, acc account.Account, lastDeposit *big.Int, lastEpoch uint64, sender address.Address) error {
+	conn, err := chain.Dial(config.Get().Chain)
+	if err != nil {
+		return err
 	}
 	defer conn.Close()
-	c := iotex.NewAuthedClient(iotexapi.NewAPIServiceClient(conn), 1, acc)
+	c := chain.NewClient(conn, acc)
 
 	// query GraphQL to get the distribution list
-	endEpoch, tip, distributions, err := getDistribution(c)
+	endEpoch, tip, distributions, err := getDistribution(ctx, c)
 	if err != nil {
 		return err
 	}
+	if _, err := checkGasPrice(ctx, notifier, c); err != nil {
+		return err
+	}
 
 	if notifier != nil {
-		notifier.SendMessage(fmt.Sprintf("Begin send %d epoch hermes rewards", endEpoch.Uint64()))
+		notifier.Infof("Begin send %d epoch hermes rewards", endEpoch.Uint64())
 	}
 
-	// call distribution contract to send out rewards
-	chunkSizeStr := util.MustFetchNonEmptyParam("CHUNK_SIZE")
-	chunkSize, err := strconv.Atoi(chunkSizeStr)
+	run, err := dao.StartDistributionRun(endEpoch.Uint64(), tip.String())
 	if err != nil {
-		return err
+		return fmt.Errorf("start distribution run error: %v", err)
+	}
+	// a resumed run keeps the tip its chunks were sent with
+	tip, ok := new(big.Int).SetString(run.Tip, 10)
+	if !ok {
+		return fmt.Errorf("distribution run %d has invalid tip %s", run.ID, run.Tip)
+	}
+
+	// call distribution contract to send out rewards
+	inFlight := config.Get().Distribution.ChunksInFlight
+	if inFlight < 1 {
+		inFlight = 1
 	}
-	chargeFeeStr := util.MustFetchNonEmptyParam("CHARGE_FEE")
-	chargeFee, _ := new(big.Int).SetString(chargeFeeStr, 10)
-	minRewardsStr := util.MustFetchNonEmptyParam("MIN_REWARDS")
-	minRewards, _ := new(big.Int).SetString(minRewardsStr, 10)
 
 	delegateNames := make([][32]byte, 0, len(distributions))
 	total := big.NewInt(0)
 	for _, dist := range distributions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		fmt.Printf("%s total rewards: %s\n", dist.DelegateName, dist.Total.String())
 		total = new(big.Int).Add(total, dist.Total)
 		delegateNames = append(delegateNames, stringToBytes32(dist.DelegateName))
 
-		snapshot, err := LoadSnapshot(dist.DelegateName, endEpoch.Uint64())
+		chunks, totalRecipients, err := splitDelegate(c, run, dist, endEpoch.Uint64())
 		if err != nil {
 			return err
 		}
-		var divAddrList [][]common.Address
-		var divAmountList [][]*big.Int
-		var totalRecipients int
-		if snapshot == nil {
-			tx := dao.Transaction()
-			divAddrList, divAmountList, totalRecipients, err = splitRecipients(
-				c,
-				tx,
-				minRewards,
-				chargeFee,
-				dist.DelegateName,
-				endEpoch.Uint64(),
-				chunkSize,
-				dist.RecipientList,
-				dist.AmountList,
-			)
-			if err != nil {
-				tx.Rollback()
-				return err
-			}
-			tx.Commit()
-			snapshot = &Snapshot{
-				DivAddrList:     divAddrList,
-				DivAmountList:   divAmountList,
-				TotalRecipients: totalRecipients,
-			}
-			err = snapshot.Save(dist.DelegateName, endEpoch.Uint64())
-			if err != nil {
-				return err
-			}
-		} else {
-			divAddrList = snapshot.DivAddrList
-			divAmountList = snapshot.DivAmountList
-			totalRecipients = snapshot.TotalRecipients
+		pending, err := resumeChunks(c, dist.DelegateName, chunks)
+		if err != nil {
+			return err
 		}
-		for {
-			distrbutedCount, err := getDistributedCount(c, dist.DelegateName)
-			if err != nil {
-				return err
-			}
-			// distribution is done for the delegate
-			if int(distrbutedCount) == totalRecipients {
-				break
-			}
-			if int(distrbutedCount)%chunkSize != 0 {
-				return fmt.Errorf("invalid distributed count, Delegate Name: %s, Distributed Count: %d, Number of Recipients: %d",
-					dist.DelegateName, distrbutedCount, totalRecipients)
-			}
-			nextGroup := int(distrbutedCount) / chunkSize
-			if err := sendRewards(c, dist.DelegateName, endEpoch, tip, divAddrList[nextGroup], divAmountList[nextGroup]); err != nil {
-				return err
-			}
+		p := &pipeline{
+			c:        c,
+			endEpoch: endEpoch,
+			tip:      tip,
+			inFlight: inFlight,
+		}
+		if err := p.send(ctx, pending); err != nil {
+			return err
+		}
+		distributedCount, err := getDistributedCount(c, dist.DelegateName)
+		if err != nil {
+			return err
+		}
+		if int(distributedCount) != totalRecipients {
+			return fmt.Errorf("invalid distributed count, Delegate Name: %s, Distributed Count: %d, Number of Recipients: %d",
+				dist.DelegateName, distributedCount, totalRecipients)
 		}
 	}
 	if notifier != nil {
-		notifier.SendMessage(fmt.Sprintf("epoch %d total rewards: %s", endEpoch, total.String()))
+		notifier.Infof("epoch %d total rewards: %s", endEpoch, total.String())
 	}
 	err = dao.BakCompletedRecord()
 	if err != nil {
 		if notifier != nil {
-			notifier.SendMessage(fmt.Sprintf("Bak completed records error: %v", err))
+			notifier.Errorf("Bak completed records error: %v", err)
 		}
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	err = commitDistributions(c, endEpoch, delegateNames)
 	if err != nil {
 		return err
 	}
-	total, err = mergeCompound()
-	if err != nil {
+	if err := dao.CompleteDistributionRun(run); err != nil {
+		return fmt.Errorf("complete distribution run error: %v", err)
+	}
+	// the distributions are committed, finish the run even if ctx is done
+	if err := fundSender(context.WithoutCancel(ctx), notifier, c, sender, nil, ""); err != nil {
 		return err
 	}
 
-	accountInfo, err := c.API().GetAccount(context.Background(), &iotexapi.GetAccountRequest{
-		Address: c.Account().Address().String(),
-	})
+	if notifier != nil {
+		notifier.Infof("Complete epoch %d hermes rewards", endEpoch)
+	}
+	return nil
+}
+
+// splitDelegate returns the ledger chunks of a delegate in run, the recipients
+// are split and recorded along with the small records if the delegate is new
+func splitDelegate(c chain.Client, run *dao.DistributionRun, dist *DistributionInfo, endEpoch uint64) ([]*dao.DistributionChunk, int, error) {
+	delegate, err := dao.FindDistributionDelegate(run.ID, dist.DelegateName)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
-	balance, _ := new(big.Int).SetString(accountInfo.AccountMeta.Balance, 10)
-	if balance.Cmp(total) < 0 {
-		fmt.Printf("Account balance less than compound rewards: %s < %s\n", balance.String(), total.String())
-		if notifier != nil {
-			notifier.SendMessage(fmt.Sprintf("Account balance less than compound rewards: %s < %s", balance.String(), total.String()))
-		}
-		total = new(big.Int).Sub(balance, big.NewInt(1000000000000000000))
+	if delegate != nil {
+		chunks, err := loadChunks(delegate)
+		if err != nil {
+			return nil, 0, err
+		}
+		return chunks, delegate.TotalRecipients, nil
+	}
+
+	distCfg := config.Get().Distribution
+	tx := dao.Transaction()
+	divAddrList, divAmountList, totalRecipients, err := splitRecipients(
+		c,
+		tx,
+		distCfg.MinRewards.Int(),
+		distCfg.ChargeFee.Int(),
+		dist.DelegateName,
+		endEpoch,
+		distCfg.ChunkSize,
+		dist.RecipientList,
+		dist.AmountList,
+		nil,
+	)
+	if err != nil {
+		tx.Rollback()
+		return nil, 0, err
 	}
-	hash, _ := c.Transfer(sender, total).SetGasPrice(big.NewInt(1000000000000)).SetGasLimit(10000).Call(context.Background())
-	if notifier != nil {
-		notifier.SendMessage(fmt.Sprintf("transfer %s to compound sender with hash: %s", total.String(), hex.EncodeToString(hash[:])))
+	chunks, err := newChunks(divAddrList, divAmountList)
+	if err != nil {
+		tx.Rollback()
+		return nil, 0, err
 	}
-	time.Sleep(20 * time.Second)
-	err = checkActionReceipt(c, hash)
+	delegate = &dao.DistributionDelegate{
+		RunID:           run.ID,
+		EndEpoch:        endEpoch,
+		DelegateName:    dist.DelegateName,
+		Total:           dist.Total.String(),
+		ServiceFee:      dist.ServiceFee.String(),
+		ChargeFee:       distCfg.ChargeFee.Int().String(),
+		TotalRecipients: totalRecipients,
+	}
+	if err := dao.CreateDistributionDelegate(tx, delegate, chunks); err != nil {
+		tx.Rollback()
+		return nil, 0, fmt.Errorf("save distribution chunks error: %v", err)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, 0, err
+	}
+	return chunks, totalRecipients, nil
+}
+
+// DryRun calculates the next distribution in a rolled back transaction and
+// reports it without sending any action
+func DryRun(acc account.Account) (*Report, error) {
+	conn, err := chain.Dial(config.Get().Chain)
 	if err != nil {
-		if notifier != nil {
-			notifier.SendMessage(fmt.Sprintf("send transfer sender action %s error: %v", hex.EncodeToString(hash[:]), err))
+		return nil, err
+	}
+	defer conn.Close()
+	return dryRun(chain.NewClient(conn, acc))
+}
+
+// dryRun reports the next distribution. The delegates already split by a run
+// in progress are reported from its ledger, with its tip.
+func dryRun(c chain.Client) (*Report, error) {
+	endEpoch, tip, distributions, err := getDistribution(context.Background(), c)
+	if err != nil {
+		return nil, err
+	}
+	run, err := dao.FindRunningDistributionRun(endEpoch.Uint64())
+	if err != nil {
+		return nil, fmt.Errorf("query distribution run error: %v", err)
+	}
+	if run != nil {
+		var ok bool
+		if tip, ok = new(big.Int).SetString(run.Tip, 10); !ok {
+			return nil, fmt.Errorf("distribution run %d has invalid tip %s", run.ID, run.Tip)
 		}
 	}
 
-	if notifier != nil {
-		notifier.SendMessage(fmt.Sprintf("Complete epoch %d hermes rewards", endEpoch))
+	distCfg := config.Get().Distribution
+	report := &Report{
+		EndEpoch: endEpoch.Uint64(),
+		Tip:      tip,
 	}
-	return nil
+	tx := dao.Transaction()
+	defer tx.Rollback()
+	for _, dist := range distributions {
+		delegateReport := &DelegateReport{
+			DelegateName: dist.DelegateName,
+			Total:        dist.Total,
+			ServiceFee:   dist.ServiceFee,
+		}
+		if run != nil {
+			delegate, err := dao.FindDistributionDelegate(run.ID, dist.DelegateName)
+			if err != nil {
+				return nil, err
+			}
+			if delegate != nil {
+				if err := ledgerReport(delegateReport, delegate); err != nil {
+					return nil, err
+				}
+				report.Delegates = append(report.Delegates, delegateReport)
+				continue
+			}
+		}
+		_, _, _, err = splitRecipients(
+			c,
+			tx,
+			distCfg.MinRewards.Int(),
+			distCfg.ChargeFee.Int(),
+			dist.DelegateName,
+			endEpoch.Uint64(),
+			distCfg.ChunkSize,
+			dist.RecipientList,
+			dist.AmountList,
+			delegateReport,
+		)
+		if err != nil {
+			return nil, err
+		}
+		report.Delegates = append(report.Delegates, delegateReport)
+	}
+	return report, nil
 }
 
-func mergeCompound() (*big.Int, error) {
-	voters, err := dao.FindVotersByStatus("pending")
+// ledgerReport reports the split of a delegate from the ledger
+func ledgerReport(report *DelegateReport, delegate *dao.DistributionDelegate) error {
+	chunks, err := loadChunks(delegate)
 	if err != nil {
-		return nil, fmt.Errorf("query new voters error: %v", err)
+		return err
 	}
-	total := big.NewInt(0)
-	for _, voter := range voters {
-		rows, err := dao.FindByVoterAndStatus(voter, "pending")
+	// the small records the split merged were marked sent in its epoch, the
+	// same ones the export reports
+	merged, err := mergedSmalls(delegate.EndEpoch)
+	if err != nil {
+		return err
+	}
+	mergedOf := func(voter string) *big.Int {
+		return zeroIfNil(merged[payoutKey{delegate.DelegateName, voter}])
+	}
+
+	// unknown for splits recorded before the fourth migration
+	fee, _ := new(big.Int).SetString(delegate.ChargeFee, 10)
+	for _, chunk := range chunks {
+		recipients, amounts, err := chunkRecipients(chunk)
 		if err != nil {
-			return nil, fmt.Errorf("query new rewards by voter error: %v", err)
-		}
-		if len(rows) < 2 {
-			amount, _ := new(big.Int).SetString(rows[0].Amount, 10)
-			total = new(big.Int).Add(total, amount)
-			rows[0].Status = "new"
-			rows[0].Signature = ""
-			if err = rows[0].Save(dao.DB()); err != nil {
-				return nil, fmt.Errorf("save merged to record error: %v", err)
-			}
+			return err
+		}
+		for i, voter := range recipients {
+			report.addPaid(voter, amounts[i], mergedOf(voter), fee)
+		}
+	}
+	report.Chunks = len(chunks)
+
+	drops, err := dao.FindDropRecordsByEpoch(delegate.EndEpoch)
+	if err != nil {
+		return fmt.Errorf("query drop records error: %v", err)
+	}
+	for _, record := range drops {
+		if record.DelegateName != delegate.DelegateName {
 			continue
 		}
-		tx := dao.Transaction()
-		amount, _ := new(big.Int).SetString(rows[0].Amount, 10)
-		for i := 1; i < len(rows); i++ {
-			temp, _ := new(big.Int).SetString(rows[i].Amount, 10)
-			amount = new(big.Int).Add(amount, temp)
-			rows[i].Status = fmt.Sprintf("merged-%d", rows[0].ID)
-			if err = rows[i].Save(tx); err != nil {
-				tx.Rollback()
-				return nil, fmt.Errorf("save merged record error: %v", err)
-			}
+		amount, ok := new(big.Int).SetString(record.Amount, 10)
+		if !ok {
+			return fmt.Errorf("drop record %d has invalid amount %s", record.ID, record.Amount)
+		}
+		report.addCompounded(record.Voter, amount, mergedOf(record.Voter), record.Index)
+	}
+	smalls, err := dao.FindSmallRecordsByEpoch(delegate.EndEpoch)
+	if err != nil {
+		return fmt.Errorf("query small records error: %v", err)
+	}
+	for _, small := range smalls {
+		if small.DelegateName != delegate.DelegateName {
+			continue
 		}
-		total = new(big.Int).Add(total, amount)
-		rows[0].Status = "new"
-		rows[0].Signature = ""
-		rows[0].Amount = amount.String()
-		if err = rows[0].Save(tx); err != nil {
-			tx.Rollback()
-			return nil, fmt.Errorf("save merged to record error: %v", err)
+		amount, ok := new(big.Int).SetString(small.Amount, 10)
+		if !ok {
+			return fmt.Errorf("small record %d has invalid amount %s", small.ID, small.Amount)
 		}
-		tx.Commit()
+		report.addDeferred(small.Voter, amount)
 	}
-	return total, nil
+	return nil
 }
 
-func getDistribution(c iotex.AuthedClient) (*big.Int, *big.Int, []*DistributionInfo, error) {
+func getDistribution(ctx context.Context, c chain.Client) (*big.Int, *big.Int, []*DistributionInfo, error) {
 	minTips, err := getMinTips(c)
 	if err != nil {
 		return nil, nil, nil, err
@@ -327,11 +384,11 @@ func getDistribution(c iotex.AuthedClient) (*big.Int, *big.Int, []*DistributionI
 	}
 	startEpoch := lastEndEpoch + 1
 
-	resp, err := c.API().GetChainMeta(context.Background(), &iotexapi.GetChainMetaRequest{})
+	meta, err := c.ChainMeta(ctx)
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	curEpoch := resp.ChainMeta.Epoch.Num
+	curEpoch := meta.Epoch.Num
 
 	endEpoch := startEpoch + 23
 
@@ -348,34 +405,32 @@ func getDistribution(c iotex.AuthedClient) (*big.Int, *big.Int, []*DistributionI
 	fmt.Printf("Distribution Start Epoch: %d\n", startEpoch)
 	fmt.Printf("Distribution End Epoch: %d\n", endEpoch)
 
-	rewardAddress := util.MustFetchNonEmptyParam("VAULT_ADDRESS")
+	rewardAddresses := config.Get().Distribution.VaultAddresses.Strings()
 	epochCount := endEpoch - startEpoch + 1
-	distributions, err := GetBookkeeping(c, startEpoch, epochCount, rewardAddress)
+	distributions, err := GetBookkeeping(c, startEpoch, epochCount, rewardAddresses)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 	return big.NewInt(int64(endEpoch)), minTips, distributions, nil
 }
 
-func sendRewards(
-	c iotex.AuthedClient,
+// signRewards signs a distributeRewards action with nonce without sending it
+func signRewards(
+	c chain.Client,
 	delegateName string,
 	endEpoch *big.Int,
 	minTips *big.Int,
 	voterAddrList []common.Address,
 	amountList []*big.Int,
-) error {
-	cstring := util.MustFetchNonEmptyParam("HERMES_CONTRACT_ADDRESS")
-	caddr, err := address.FromString(cstring)
-	if err != nil {
-		return err
-	}
+	nonce uint64,
+) (*chain.SignedAction, error) {
+	caddr := config.Get().Contracts.Hermes.Address()
 
 	// call distribution contract to send out rewards
 	ctx := context.Background()
 	hermesABI, err := abi.JSON(strings.NewReader(HermesABI))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	totalAmount := new(big.Int).Set(minTips)
@@ -387,31 +442,28 @@ func sendRewards(
 
 	name := stringToBytes32(delegateName)
 
-	gasPriceStr := util.MustFetchNonEmptyParam("GAS_PRICE")
-	gasPrice, ok := big.NewInt(0).SetString(gasPriceStr, 10)
-	if !ok {
-		return errors.New("failed to convert string to big int")
+	gas, err := c.EstimateExecution(ctx, caddr, hermesABI, totalAmount, "distributeRewards", name, endEpoch, voterAddrList, amountList)
+	if err != nil {
+		return nil, fmt.Errorf("estimate distributeRewards gas error: %v", err)
 	}
-	gasLimitStr := util.MustFetchNonEmptyParam("GAS_LIMIT")
-	gasLimit, err := strconv.Atoi(gasLimitStr)
+	limit, err := gasLimit(gas)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	h, err := c.Contract(caddr, hermesABI).Execute("distributeRewards", name, endEpoch, voterAddrList, amountList).
-		SetAmount(totalAmount).SetGasPrice(gasPrice).SetGasLimit(uint64(gasLimit)).Call(ctx)
+	gasPrice, err := chain.GasPrice(ctx, c, config.Get().Gas)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	return checkActionReceipt(c, h)
+	return c.SignExecution(ctx, caddr, hermesABI, chain.Opts{
+		Amount:   totalAmount,
+		GasPrice: gasPrice,
+		GasLimit: limit,
+		Nonce:    nonce,
+	}, "distributeRewards", name, endEpoch, voterAddrList, amountList)
 }
 
-func commitDistributions(c iotex.AuthedClient, endEpoch *big.Int, delegateNames [][32]byte) error {
-	cstring := util.MustFetchNonEmptyParam("HERMES_CONTRACT_ADDRESS")
-	caddr, err := address.FromString(cstring)
-	if err != nil {
-		return err
-	}
+func commitDistributions(c chain.Client, endEpoch *big.Int, delegateNames [][32]byte) error {
+	caddr := config.Get().Contracts.Hermes.Address()
 
 	// call distribution contract to send out rewards
 	ctx := context.Background()
@@ -420,23 +472,27 @@ func commitDistributions(c iotex.AuthedClient, endEpoch *big.Int, delegateNames
 		return err
 	}
 
-	gasPriceStr := util.MustFetchNonEmptyParam("GAS_PRICE")
-	gasPrice, ok := big.NewInt(0).SetString(gasPriceStr, 10)
-	if !ok {
-		return errors.New("failed to convert string to big int")
+	gas, err := c.EstimateExecution(ctx, caddr, hermesABI, nil, "commitDistributions", endEpoch, delegateNames)
+	if err != nil {
+		return fmt.Errorf("estimate commitDistributions gas error: %v", err)
 	}
-	gasLimitStr := util.MustFetchNonEmptyParam("GAS_LIMIT")
-	gasLimit, err := strconv.Atoi(gasLimitStr)
+	limit, err := gasLimit(gas)
 	if err != nil {
 		return err
 	}
-	h, err := c.Contract(caddr, hermesABI).Execute("commitDistributions", endEpoch, delegateNames).
-		SetGasPrice(gasPrice).SetGasLimit(uint64(gasLimit)).Call(ctx)
+	gasPrice, err := chain.GasPrice(ctx, c, config.Get().Gas)
+	if err != nil {
+		return err
+	}
+	h, err := c.ExecuteContract(ctx, caddr, hermesABI, chain.Opts{
+		GasPrice: gasPrice,
+		GasLimit: limit,
+	}, "commitDistributions", endEpoch, delegateNames)
 	if err != nil {
 		return err
 	}
 
-	err = checkActionReceipt(c, h)
+	_, err = checkActionReceipt(c, h)
 	if err != nil {
 		return err
 	}
@@ -444,45 +500,29 @@ func commitDistributions(c iotex.AuthedClient, endEpoch *big.Int, delegateNames
 	return nil
 }
 
-func getMinTips(c iotex.AuthedClient) (*big.Int, error) {
-	cstring := util.MustFetchNonEmptyParam("MULTISEND_CONTRACT_ADDRESS")
-	caddr, err := address.FromString(cstring)
-	if err != nil {
-		return nil, err
-	}
+func getMinTips(c chain.Client) (*big.Int, error) {
+	caddr := config.Get().Contracts.Multisend.Address()
 	multisendABI, err := abi.JSON(strings.NewReader(MultisendABI))
 	if err != nil {
 		return nil, err
 	}
-	data, err := c.Contract(caddr, multisendABI).Read("minTips").Call(context.Background())
-	if err != nil {
-		return nil, err
-	}
-	decoded, err := data.Unmarshal()
+	decoded, err := c.ReadContract(context.Background(), caddr, multisendABI, "minTips")
 	if err != nil {
 		return nil, err
 	}
 	minTips := decoded[0].(*big.Int)
 
-	fmt.Printf("MultiSend Contract: %s, min tip: %s\n", cstring, minTips.String())
+	fmt.Printf("MultiSend Contract: %s, min tip: %s\n", caddr.String(), minTips.String())
 	return minTips, nil
 }
 
-func getContractStartEpoch(c iotex.AuthedClient) (uint64, error) {
-	cstring := util.MustFetchNonEmptyParam("HERMES_CONTRACT_ADDRESS")
-	caddr, err := address.FromString(cstring)
-	if err != nil {
-		return 0, err
-	}
+func getContractStartEpoch(c chain.Client) (uint64, error) {
+	caddr := config.Get().Contracts.Hermes.Address()
 	hermesABI, err := abi.JSON(strings.NewReader(HermesABI))
 	if err != nil {
 		return 0, err
 	}
-	data, err := c.Contract(caddr, hermesABI).Read("contractStartEpoch").Call(context.Background())
-	if err != nil {
-		return 0, err
-	}
-	decoded, err := data.Unmarshal()
+	decoded, err := c.ReadContract(context.Background(), caddr, hermesABI, "contractStartEpoch")
 	if err != nil {
 		return 0, err
 	}
@@ -491,21 +531,13 @@ func getContractStartEpoch(c iotex.AuthedClient) (uint64, error) {
 }
 
 // GetLastEndEpoch get last end epoch from hermes contract
-func GetLastEndEpoch(c iotex.AuthedClient) (uint64, error) {
-	cstring := util.MustFetchNonEmptyParam("HERMES_CONTRACT_ADDRESS")
-	caddr, err := address.FromString(cstring)
-	if err != nil {
-		return 0, err
-	}
+func GetLastEndEpoch(c chain.Client) (uint64, error) {
+	caddr := config.Get().Contracts.Hermes.Address()
 	hermesABI, err := abi.JSON(strings.NewReader(HermesABI))
 	if err != nil {
 		return 0, err
 	}
-	data, err := c.Contract(caddr, hermesABI).Read("getEndEpochCount").Call(context.Background())
-	if err != nil {
-		return 0, err
-	}
-	decoded, err := data.Unmarshal()
+	decoded, err := c.ReadContract(context.Background(), caddr, hermesABI, "getEndEpochCount")
 	if err != nil {
 		return 0, err
 	}
@@ -514,41 +546,29 @@ func GetLastEndEpoch(c iotex.AuthedClient) (uint64, error) {
 	if endEpochCount.String() == "0" {
 		return 0, nil
 	}
-	data, err = c.Contract(caddr, hermesABI).Read("endEpochs", endEpochCount.Sub(endEpochCount, big.NewInt(1))).Call(context.Background())
-	if err != nil {
-		return 0, err
-	}
-	decoded, err = data.Unmarshal()
+	decoded, err = c.ReadContract(context.Background(), caddr, hermesABI, "endEpochs", endEpochCount.Sub(endEpochCount, big.NewInt(1)))
 	if err != nil {
 		return 0, err
 	}
 	return decoded[0].(*big.Int).Uint64(), nil
 }
 
-func getDistributedCount(c iotex.AuthedClient, delegateName string) (uint64, error) {
-	cstring := util.MustFetchNonEmptyParam("HERMES_CONTRACT_ADDRESS")
-	caddr, err := address.FromString(cstring)
-	if err != nil {
-		return 0, err
-	}
+func getDistributedCount(c chain.Client, delegateName string) (uint64, error) {
+	caddr := config.Get().Contracts.Hermes.Address()
 	hermesABI, err := abi.JSON(strings.NewReader(HermesABI))
 	if err != nil {
 		return 0, err
 	}
 
 	name := stringToBytes32(delegateName)
-	data, err := c.Contract(caddr, hermesABI).Read("distributedCount", name).Call(context.Background())
-	if err != nil {
-		return 0, err
-	}
-	decoded, err := data.Unmarshal()
+	decoded, err := c.ReadContract(context.Background(), caddr, hermesABI, "distributedCount", name)
 	if err != nil {
 		return 0, err
 	}
 	return decoded[0].(*big.Int).Uint64(), nil
 }
 
-func GetBookkeeping(c iotex.AuthedClient, startEpoch uint64, epochCount uint64, rewardAddress string) ([]*DistributionInfo, error) {
+func GetBookkeeping(c chain.Client, startEpoch uint64, epochCount uint64, rewardAddresses []string) ([]*DistributionInfo, error) {
 	type query struct {
 		Hermes struct {
 			HermesDistribution []struct {
@@ -565,18 +585,17 @@ func GetBookkeeping(c iotex.AuthedClient, startEpoch uint64, epochCount uint64,
 		} `graphql:"Hermes(startEpoch: $startEpoch, epochCount: $epochCount, rewardAddress: $rewardAddress)"`
 	}
 
-	analyticsEndpoint := util.MustFetchNonEmptyParam("ANALYTICS_ENDPOINT")
+	analyticsCfg := config.Get().Analytics
 
 	src := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: util.MustFetchNonEmptyParam("ANALYTICS_TOKEN")},
+		&oauth2.Token{AccessToken: analyticsCfg.Token},
 	)
 	httpClient := oauth2.NewClient(context.Background(), src)
-	gqlClient := graphql.NewClient(analyticsEndpoint, httpClient)
+	gqlClient := graphql.NewClient(analyticsCfg.Endpoint, httpClient)
 
-	addresses := strings.Split(rewardAddress, ",")
-	queryAddresses := make([]graphql.String, len(addresses))
-	for i := 0; i < len(addresses); i++ {
-		queryAddresses[i] = graphql.String(addresses[i])
+	queryAddresses := make([]graphql.String, len(rewardAddresses))
+	for i := 0; i < len(rewardAddresses); i++ {
+		queryAddresses[i] = graphql.String(rewardAddresses[i])
 	}
 
 	// make sure every epoch does not miss hermes info
@@ -626,14 +645,9 @@ func GetBookkeeping(c iotex.AuthedClient, startEpoch uint64, epochCount uint64,
 		}
 		// charge fees
 		var err error
-		// serviceFee := big.NewInt(0)
-		// if !hermesDistribution.WaiveServiceFee {
-		// 	if serviceFee, refund, err = calculateServiceFee(int64(hermesDistribution.VoterCount), refund); err != nil {
-		// 		return nil, err
-		// 	}
-		// }
+		serviceFee := big.NewInt(0)
 		if !hermesDistribution.WaiveServiceFee {
-			if _, refund, err = calculateServiceFee(int64(hermesDistribution.VoterCount), refund); err != nil {
+			if serviceFee, refund, err = calculateServiceFee(int64(hermesDistribution.VoterCount), refund); err != nil {
 				return nil, err
 			}
 		}
@@ -680,6 +694,7 @@ func GetBookkeeping(c iotex.AuthedClient, startEpoch uint64, epochCount uint64,
 			RecipientList: recipientAddrList,
 			Total:         total,
 			AmountList:    amountList,
+			ServiceFee:    serviceFee,
 		})
 	}
 	// sort distributions by delegate name
@@ -689,16 +704,9 @@ func GetBookkeeping(c iotex.AuthedClient, startEpoch uint64, epochCount uint64,
 }
 
 func calculateServiceFee(voterCount int64, refund *big.Int) (*big.Int, *big.Int, error) {
-	baseChargeStr := util.MustFetchNonEmptyParam("BASE_CHARGE")
-	baseCharge, ok := big.NewInt(0).SetString(baseChargeStr, 10)
-	if !ok {
-		return nil, nil, errors.New("failed to convert string to big int")
-	}
-	chargePerRecipientStr := util.MustFetchNonEmptyParam("CHARGE_PER_RECIPIENT")
-	chargePerRecipient, ok := big.NewInt(0).SetString(chargePerRecipientStr, 10)
-	if !ok {
-		return nil, nil, errors.New("failed to convert string to big int")
-	}
+	distCfg := config.Get().Distribution
+	baseCharge := distCfg.BaseCharge.Int()
+	chargePerRecipient := distCfg.ChargePerRecipient.Int()
 	serviceFee := baseCharge
 	extraCharge := big.NewInt(voterCount)
 	extraCharge.Mul(extraCharge, chargePerRecipient)
@@ -713,7 +721,7 @@ func calculateServiceFee(voterCount int64, refund *big.Int) (*big.Int, *big.Int,
 }
 
 func splitRecipients(
-	c iotex.AuthedClient,
+	c chain.Client,
 	tx *gorm.DB,
 	minRewards *big.Int,
 	chargeFee *big.Int,
@@ -722,6 +730,7 @@ func splitRecipients(
 	chunkSize int,
 	recipientAddrList []common.Address,
 	amountList []*big.Int,
+	report *DelegateReport,
 ) ([][]common.Address, [][]*big.Int, int, error) {
 	if len(recipientAddrList) != len(amountList) {
 		return nil, nil, 0, errors.New("length does not match")
@@ -737,10 +746,15 @@ func splitRecipients(
 			return nil, nil, 0, err
 		}
 		for _, v := range smallRecords {
-			if v.Verify() != nil {
+			if err := v.Verify(); err != nil {
+				if errors.Is(err, dao.ErrSignatureVersion) {
+					// left new for migrate-signatures
+					return nil, nil, 0, fmt.Errorf("small record %d: %v", v.ID, err)
+				}
 				v.Status = "invalid"
 				v.Save(tx)
 				fmt.Printf("Invalid verify: %v\n", err)
+				report.addInvalid()
 				continue
 			}
 			recordAmount, _ := new(big.Int).SetString(v.Amount, 10)
@@ -769,9 +783,11 @@ func splitRecipients(
 					fmt.Printf("Save drop record error: %v\n", err)
 					return nil, nil, 0, err
 				}
+				report.addCompounded(recipient.String(), mergedAmount, smallAmount, uint64(bucketID))
 			} else {
 				innerAddrList = append(innerAddrList, recipientAddrList[i])
 				innerAmountList = append(innerAmountList, new(big.Int).Sub(mergedAmount, chargeFee))
+				report.addPaid(recipient.String(), new(big.Int).Sub(mergedAmount, chargeFee), smallAmount, chargeFee)
 			}
 			for _, v := range smallRecords {
 				if v.Status == "new" {
@@ -799,6 +815,7 @@ func splitRecipients(
 				fmt.Printf("Save small record error: %v\n", err)
 				return nil, nil, 0, err
 			}
+			report.addDeferred(recipient.String(), amountList[i])
 		}
 	}
 
@@ -815,12 +832,15 @@ func splitRecipients(
 		divAddrList = append(divAddrList, innerAddrList[i:end])
 		divAmountList = append(divAmountList, innerAmountList[i:end])
 	}
+	if report != nil {
+		report.Chunks = len(divAddrList)
+	}
 
 	return divAddrList, divAmountList, len(innerAddrList), nil
 }
 
 // ioAddrToEvmAddr converts IoTeX address into evm address
-func ioAddrToEvmAddr(c iotex.AuthedClient, ioAddr string) (common.Address, error) {
+func ioAddrToEvmAddr(c chain.Client, ioAddr string) (common.Address, error) {
 	address, err := address.FromString(ioAddr)
 	if err != nil {
 		return common.Address{}, err
@@ -835,63 +855,24 @@ func stringToBytes32(delegateName string) [32]byte {
 	return name
 }
 
-func checkActionReceipt(c iotex.AuthedClient, hash hash.Hash256) error {
-	time.Sleep(5 * time.Second)
-	var resp *iotexapi.GetReceiptByActionResponse
-	var err error
-	for i := 0; i < 120; i++ {
-		resp, err = c.API().GetReceiptByAction(context.Background(), &iotexapi.GetReceiptByActionRequest{
-			ActionHash: hex.EncodeToString(hash[:]),
-		})
-		if err != nil {
-			if strings.Contains(err.Error(), "code = NotFound") {
-				time.Sleep(2 * time.Second)
-				continue
-			}
-			return err
-		}
-		if resp.ReceiptInfo.Receipt.Status != 1 {
-			return errors.Errorf("action %x check receipt failed", hash)
-		}
-		return nil
+// checkGasPrice returns the gas price to send at, it alerts when the price
+// is above the ceiling and nothing can be sent
+func checkGasPrice(ctx context.Context, notifier *notify.Alerter, c chain.Client) (*big.Int, error) {
+	gasPrice, err := chain.GasPrice(ctx, c, config.Get().Gas)
+	if err != nil && notifier != nil {
+		notifier.Criticalf("Refuse to send hermes actions: %v", err)
 	}
-	fmt.Printf("action %x check receipt not found\n", hash)
-	return err
+	return gasPrice, err
 }
 
-func GetDelegate(c iotex.AuthedClient, name string) (*iotextypes.CandidateV2, error) {
-	method := &iotexapi.ReadStakingDataMethod{
-		Method: iotexapi.ReadStakingDataMethod_CANDIDATE_BY_NAME,
-	}
-	methodBytes, err := proto.Marshal(method)
-	if err != nil {
-		return nil, err
-	}
-	arguments := &iotexapi.ReadStakingDataRequest{
-		Request: &iotexapi.ReadStakingDataRequest_CandidateByName_{
-			CandidateByName: &iotexapi.ReadStakingDataRequest_CandidateByName{
-				CandName: name,
-			},
-		},
-	}
-	argumentsBytes, err := proto.Marshal(arguments)
-	if err != nil {
-		return nil, err
-	}
-	request := &iotexapi.ReadStateRequest{
-		ProtocolID: []byte("staking"),
-		MethodName: methodBytes,
-		Arguments:  [][]byte{argumentsBytes},
-	}
-	response, err := c.API().ReadState(context.Background(), request)
-	if err != nil {
-		return nil, err
-	}
+// receiptPolling is how long sent distribution actions are waited for
+var receiptPolling = chain.DefaultPolling
 
-	var result iotextypes.CandidateV2
-	if err := proto.Unmarshal(response.Data, &result); err != nil {
-		return nil, err
-	}
+func checkActionReceipt(c chain.Client, hash hash.Hash256) (*iotextypes.Receipt, error) {
+	return chain.CheckReceipt(context.Background(), c, hash, receiptPolling)
+}
 
-	return &result, nil
+// GetDelegate reads the candidate registered as name
+func GetDelegate(c chain.Client, name string) (*iotextypes.CandidateV2, error) {
+	return c.Candidate(context.Background(), name)
 }
@@ -7,13 +7,32 @@
 package distribute
 
 import (
-	"os"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/iotexproject/iotex-address/address"
 	"github.com/iotexproject/iotex-antenna-go/v2/account"
 	"github.com/iotexproject/iotex-antenna-go/v2/iotex"
-	"github.com/iotexproject/iotex-proto/golang/iotexapi"
+	"github.com/iotexproject/iotex-proto/golang/iotextypes"
 	"github.com/stretchr/testify/require"
+
+	"github.com/ququzone/hermes-patch/hermes/analytics"
+	"github.com/ququzone/hermes-patch/hermes/chain"
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+	"github.com/ququzone/hermes-patch/hermes/config"
 )
 
 const (
@@ -33,10 +52,319 @@ func TestGetMinTips(t *testing.T) {
 	require.NoError(err)
 	defer conn.Close()
 
-	c := iotex.NewAuthedClient(iotexapi.NewAPIServiceClient(conn), 1, account)
+	c := chain.NewClient(conn, account)
 
-	os.Setenv("MULTISEND_CONTRACT_ADDRESS", multiSendAddress)
+	cfg := &config.Config{}
+	require.NoError(cfg.Contracts.Multisend.Set(multiSendAddress))
+	config.Set(cfg)
 
 	minTips, err := getMinTips(c)
 	require.Equal(minTips.String(), expectedMinTips)
 }
+
+var (
+	testHermesAddress      = common.HexToAddress("0x0000000000000000000000000000000000000a01")
+	testAutoDepositAddress = common.HexToAddress("0x0000000000000000000000000000000000000a02")
+)
+
+// newFakeChain returns a fake chain with the hermes, multisend and auto deposit
+// contracts deployed, and a client of the test account
+func newFakeChain(t *testing.T) (*chain.Fake, chain.Client) {
+	require := require.New(t)
+
+	acc, err := account.HexStringToAccount(testPrivateKey)
+	require.NoError(err)
+	hermesAddr, err := address.FromBytes(testHermesAddress.Bytes())
+	require.NoError(err)
+	autoDepositAddr, err := address.FromBytes(testAutoDepositAddress.Bytes())
+	require.NoError(err)
+
+	cfg := &config.Config{}
+	require.NoError(cfg.Contracts.Hermes.Set(hermesAddr.String()))
+	require.NoError(cfg.Contracts.Multisend.Set(multiSendAddress))
+	require.NoError(cfg.Contracts.AutoDeposit.Set(autoDepositAddr.String()))
+	require.NoError(cfg.Gas.Price.Set("1000000000000"))
+	cfg.Gas.Limit = 5000000
+	config.Set(cfg)
+
+	hermesABI, err := abi.JSON(strings.NewReader(HermesABI))
+	require.NoError(err)
+	multisendABI, err := abi.JSON(strings.NewReader(MultisendABI))
+	require.NoError(err)
+	autoDepositABI, err := abi.JSON(strings.NewReader(AutoDepositABI))
+	require.NoError(err)
+
+	fake := chain.NewFake()
+	fake.Deploy(hermesAddr, hermesABI, chain.NewFakeHermes(100))
+	minTips, _ := new(big.Int).SetString(expectedMinTips, 10)
+	fake.Deploy(cfg.Contracts.Multisend.Address(), multisendABI, &chain.FakeMultisend{MinTips: minTips})
+	fake.Deploy(autoDepositAddr, autoDepositABI, &chain.FakeAutoDeposit{})
+
+	receiptPolling = chain.Polling{Attempts: 3}
+	depositPolling = chain.Polling{Attempts: 3}
+	fundingPolling = chain.Polling{Attempts: 3}
+	bucketStateMap = make(map[uint64]bool)
+	return fake, fake.Client(acc.Address())
+}
+
+func TestDistributeOnFakeChain(t *testing.T) {
+	require := require.New(t)
+
+	fake, c := newFakeChain(t)
+	fake.SetBalance(c.Address(), new(big.Int).Mul(big.NewInt(100), big.NewInt(1000000000000000000)))
+
+	minTips, err := getMinTips(c)
+	require.NoError(err)
+	require.Equal(expectedMinTips, minTips.String())
+
+	startEpoch, err := getContractStartEpoch(c)
+	require.NoError(err)
+	require.Equal(uint64(100), startEpoch)
+
+	lastEndEpoch, err := GetLastEndEpoch(c)
+	require.NoError(err)
+	require.Zero(lastEndEpoch)
+
+	voter, err := account.HexStringToAccount(testVoterPrivateKey)
+	require.NoError(err)
+	recipients := []common.Address{common.BytesToAddress(voter.Address().Bytes())}
+	amounts := []*big.Int{big.NewInt(1000)}
+	signed, err := signRewards(c, "delegate", big.NewInt(123), minTips, recipients, amounts, 0)
+	require.NoError(err)
+	require.NoError(c.SendAction(context.Background(), signed))
+	h := signed.Hash
+	receipt, err := checkActionReceipt(c, h)
+	require.NoError(err)
+	require.Equal(receipt.GasConsumed*12/10, fake.Action(h).GasLimit)
+	require.NotNil(fake.Action(h).GetExecution())
+	require.Equal(big.NewInt(1000), fake.BalanceOf(voter.Address()))
+
+	count, err := getDistributedCount(c, "delegate")
+	require.NoError(err)
+	require.Equal(uint64(1), count)
+
+	require.NoError(commitDistributions(c, big.NewInt(123), [][32]byte{stringToBytes32("delegate")}))
+	lastEndEpoch, err = GetLastEndEpoch(c)
+	require.NoError(err)
+	require.Equal(uint64(123), lastEndEpoch)
+	count, err = getDistributedCount(c, "delegate")
+	require.NoError(err)
+	require.Zero(count)
+
+	// an epoch can't be committed twice
+	require.Error(commitDistributions(c, big.NewInt(123), [][32]byte{stringToBytes32("delegate")}))
+}
+
+func TestGetBookkeeping(t *testing.T) {
+	require := require.New(t)
+
+	fake, c := newFakeChain(t)
+	fake.SetCandidate(&iotextypes.CandidateV2{Name: "alpha", OwnerAddress: "io12w7xsqsd7an9prrqdjz4qhuw8z0ms5dtlhs6mt"})
+	fake.SetCandidate(&iotextypes.CandidateV2{Name: "beta", OwnerAddress: "io1h7awscz42lwu7frgxncu4029w7hayt6d3e6vp0"})
+	cfg := config.Get()
+	require.NoError(cfg.Distribution.BaseCharge.Set("1000000000000000000"))
+	require.NoError(cfg.Distribution.ChargePerRecipient.Set("100000000000000000"))
+
+	serve := func(fixtures string) {
+		f, err := analytics.LoadFixtures("../../analytics/testdata/" + fixtures)
+		require.NoError(err)
+		server := httptest.NewServer(analytics.NewServer(f, "token"))
+		t.Cleanup(server.Close)
+		cfg.Analytics.Endpoint = server.URL
+		cfg.Analytics.Token = "token"
+	}
+
+	serve("complete.json")
+	distributions, err := GetBookkeeping(c, 100, 2, nil)
+	require.NoError(err)
+	require.Len(distributions, 2)
+
+	alpha := distributions[0]
+	require.Equal("alpha", alpha.DelegateName)
+	require.Equal("1200000000000000000", alpha.ServiceFee.String())
+	require.Equal("12800000000000000000", alpha.Total.String())
+	require.Len(alpha.RecipientList, 3)
+
+	beta := distributions[1]
+	require.Equal("beta", beta.DelegateName)
+	require.Zero(beta.ServiceFee.Sign())
+	require.Equal("8000000000000000000", beta.Total.String())
+	amounts := make(map[string]string)
+	for i, r := range beta.RecipientList {
+		addr, err := address.FromBytes(r.Bytes())
+		require.NoError(err)
+		amounts[addr.String()] = beta.AmountList[i].String()
+	}
+	require.Equal(map[string]string{
+		"io182snufsea63rz44jlcwh6z3pcf8e25p4790w9u": "6000000000000000000",
+		"io1h7awscz42lwu7frgxncu4029w7hayt6d3e6vp0": "2000000000000000000",
+	}, amounts)
+
+	// gamma is only served to its reward address
+	fake.SetCandidate(&iotextypes.CandidateV2{Name: "gamma", OwnerAddress: "io1jznvs6r54wsgelnpwuqygj0ln7j92d34zk4um4"})
+	distributions, err = GetBookkeeping(c, 100, 2, []string{"io1jznvs6r54wsgelnpwuqygj0ln7j92d34zk4um4"})
+	require.NoError(err)
+	require.Len(distributions, 3)
+	require.Equal("gamma", distributions[2].DelegateName)
+
+	serve("missing_epoch.json")
+	_, err = GetBookkeeping(c, 100, 3, nil)
+	require.Error(err)
+	require.Contains(err.Error(), "bookkeeping info doesn't exist for Epoch 101")
+
+	serve("malformed_amount.json")
+	_, err = GetBookkeeping(c, 100, 1, nil)
+	require.EqualError(err, "failed to convert string to big int")
+}
+
+// setTestDatabase connects a migrated SQLite database with a new signing key
+func setTestDatabase(t *testing.T) {
+	require := require.New(t)
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(err)
+	private, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(err)
+	public, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(err)
+	cfg := config.Get()
+	cfg.Database = config.Database{
+		Dialect:    config.DialectSQLite,
+		Conn:       filepath.Join(t.TempDir(), "hermes.db"),
+		RSAPrivate: base64.StdEncoding.EncodeToString(private),
+		RSAPublic:  base64.StdEncoding.EncodeToString(public),
+	}
+
+	require.NoError(dao.Open(cfg.Database.Dialect, cfg.Database.Conn))
+	_, err = dao.MigrateUp(0)
+	require.NoError(err)
+	require.NoError(dao.DB().Close())
+	require.NoError(dao.ConnectDatabase())
+	t.Cleanup(func() { dao.DB().Close() })
+}
+
+// tableRows returns the rows of every table as text
+func tableRows(t *testing.T) map[string][]string {
+	require := require.New(t)
+
+	rows, err := dao.DB().Raw("SELECT name FROM sqlite_master WHERE type = 'table'").Rows()
+	require.NoError(err)
+	var tables []string
+	for rows.Next() {
+		var name string
+		require.NoError(rows.Scan(&name))
+		tables = append(tables, name)
+	}
+	require.NoError(rows.Close())
+
+	result := make(map[string][]string, len(tables))
+	for _, table := range tables {
+		rows, err := dao.DB().Raw(fmt.Sprintf("SELECT * FROM %q", table)).Rows()
+		require.NoError(err)
+		columns, err := rows.Columns()
+		require.NoError(err)
+		result[table] = []string{}
+		for rows.Next() {
+			values := make([]sql.RawBytes, len(columns))
+			dest := make([]interface{}, len(columns))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			require.NoError(rows.Scan(dest...))
+			fields := make([]string, len(values))
+			for i, v := range values {
+				fields[i] = string(v)
+			}
+			result[table] = append(result[table], strings.Join(fields, "|"))
+		}
+		require.NoError(rows.Err())
+		require.NoError(rows.Close())
+	}
+	return result
+}
+
+func TestDryRun(t *testing.T) {
+	require := require.New(t)
+
+	fake, c := newFakeChain(t)
+	setTestDatabase(t)
+	fake.SetBalance(c.Address(), new(big.Int).Mul(big.NewInt(100), big.NewInt(1000000000000000000)))
+	require.NoError(commitDistributions(c, big.NewInt(99), nil))
+	fake.SetEpoch(130)
+	fake.SetCandidate(&iotextypes.CandidateV2{Name: "alpha", OwnerAddress: "io12w7xsqsd7an9prrqdjz4qhuw8z0ms5dtlhs6mt"})
+	fake.SetCandidate(&iotextypes.CandidateV2{Name: "beta", OwnerAddress: "io1h7awscz42lwu7frgxncu4029w7hayt6d3e6vp0"})
+	cfg := config.Get()
+	require.NoError(cfg.Distribution.BaseCharge.Set("1000000000000000000"))
+	require.NoError(cfg.Distribution.ChargePerRecipient.Set("100000000000000000"))
+	require.NoError(cfg.Distribution.MinRewards.Set("30000000000000000000"))
+	require.NoError(cfg.Distribution.ChargeFee.Set("10000000000000000"))
+	cfg.Distribution.ChunkSize = 2
+
+	// the epoch 100 bookkeeping is served for every epoch from 100 to 123
+	complete, err := analytics.LoadFixtures("../../analytics/testdata/complete.json")
+	require.NoError(err)
+	f := &analytics.Fixtures{Epochs: make(map[uint64][]analytics.Distribution)}
+	for epoch := uint64(100); epoch <= 123; epoch++ {
+		f.Epochs[epoch] = complete.Epochs[100]
+	}
+	server := httptest.NewServer(analytics.NewServer(f, "token"))
+	t.Cleanup(server.Close)
+	cfg.Analytics.Endpoint = server.URL
+	cfg.Analytics.Token = "token"
+
+	// the beta voter compounds to bucket 5
+	compounder, err := address.FromString("io182snufsea63rz44jlcwh6z3pcf8e25p4790w9u")
+	require.NoError(err)
+	autoDepositABI, err := abi.JSON(strings.NewReader(AutoDepositABI))
+	require.NoError(err)
+	fake.Deploy(cfg.Contracts.AutoDeposit.Address(), autoDepositABI, &chain.FakeAutoDeposit{
+		Buckets: map[common.Address]int64{common.BytesToAddress(compounder.Bytes()): 5},
+	})
+	// deferred by alpha before and merged into this distribution
+	small := dao.SmallRecord{EndEpoch: 99, DelegateName: "alpha", Voter: "io1kdges754nup2w5sy4snan7kfh4l292aglmkkfk",
+		Amount: "10000000000000000000", Status: "new"}
+	require.NoError(small.Save(nil))
+
+	before := tableRows(t)
+	report, err := dryRun(c)
+	require.NoError(err)
+	require.Equal(before, tableRows(t))
+
+	require.Equal(uint64(123), report.EndEpoch)
+	require.Equal(expectedMinTips, report.Tip.String())
+	require.Len(report.Delegates, 2)
+	alpha, beta := report.Delegates[0], report.Delegates[1]
+	require.Equal("alpha", alpha.DelegateName)
+	require.Len(alpha.Paid, 3)
+	require.Equal(2, alpha.Chunks)
+	require.Empty(alpha.Deferred)
+	require.Equal("beta", beta.DelegateName)
+	require.Len(beta.Compounded, 1)
+	require.Equal(uint64(5), beta.Compounded[0].Bucket)
+	require.Len(beta.Deferred, 1)
+	require.Empty(beta.Paid)
+
+	// alpha is split by the run in progress and reported from its ledger, split
+	// again its small record would be deferred as it was merged by the run
+	run, err := dao.StartDistributionRun(123, "7")
+	require.NoError(err)
+	_, _, distributions, err := getDistribution(context.Background(), c)
+	require.NoError(err)
+	_, _, err = splitDelegate(c, run, distributions[0], 123)
+	require.NoError(err)
+
+	before = tableRows(t)
+	ledger, err := dryRun(c)
+	require.NoError(err)
+	require.Equal(before, tableRows(t))
+
+	require.Equal("7", ledger.Tip.String())
+	require.Len(ledger.Delegates, 2)
+	toJSON := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		require.NoError(err)
+		return string(data)
+	}
+	require.JSONEq(toJSON(alpha), toJSON(ledger.Delegates[0]))
+	require.JSONEq(toJSON(beta), toJSON(ledger.Delegates[1]))
+}
@@ -0,0 +1,390 @@
+package distribute
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iotexproject/iotex-address/address"
+
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+)
+
+// Routes of a payout
+const (
+	// RouteTransfer is paid by a distributeRewards call
+	RouteTransfer = "transfer"
+	// RouteCompound is deposited to the voter bucket by the sender
+	RouteCompound = "compound"
+	// RouteDeferred is kept as a small record until it adds up to the minimum
+	RouteDeferred = "deferred"
+)
+
+// StatusUnpaid is the status of a payout of a drop record completed without an
+// action, its amount didn't cover the gas
+const StatusUnpaid = "unpaid"
+
+// Payout is what a voter got from a delegate in an end epoch
+type Payout struct {
+	EndEpoch     uint64 `json:"endEpoch"`
+	DelegateName string `json:"delegateName"`
+	Voter        string `json:"voter"`
+	Route        string `json:"route"`
+	// Gross is the bookkeeping amount of the epoch, nil when it is unknown
+	Gross *big.Int `json:"gross"`
+	// MergedSmall is the deferred amount of earlier epochs paid along
+	MergedSmall *big.Int `json:"mergedSmall,omitempty"`
+	// ChargeFee is the charge fee deducted from a transfer, nil on other
+	// routes or when it is unknown
+	ChargeFee *big.Int `json:"chargeFee"`
+	// GasFee is the gas deducted from a compound deposit, nil on other
+	// routes or when it is unknown
+	GasFee *big.Int `json:"gasFee"`
+	Status string   `json:"status"`
+	// PaidEpoch is the end epoch a deferred payout was paid in
+	PaidEpoch uint64 `json:"paidEpoch,omitempty"`
+	Hash      string `json:"hash"`
+}
+
+// payment is how a voter of a delegate was paid in an end epoch
+type payment struct {
+	hash   string
+	status string
+}
+
+// payoutKey is a voter of a delegate
+type payoutKey struct {
+	delegate string
+	voter    string
+}
+
+// payoutLedger loads the records paying voters, the payments of each end
+// epoch are loaded once
+type payoutLedger struct {
+	payments map[uint64]map[payoutKey]payment
+	drops    map[uint]dao.DropRecord
+}
+
+// Payouts returns one payout per voter per delegate of endEpoch, of every
+// delegate when delegate is empty, ordered by delegate and voter
+func Payouts(endEpoch uint64, delegate string) ([]*Payout, error) {
+	l := &payoutLedger{
+		payments: make(map[uint64]map[payoutKey]payment),
+		drops:    make(map[uint]dao.DropRecord),
+	}
+	merged, err := mergedSmalls(endEpoch)
+	if err != nil {
+		return nil, err
+	}
+	delegates, err := dao.FindDistributionDelegatesByEpoch(endEpoch)
+	if err != nil {
+		return nil, fmt.Errorf("query distribution delegates error: %v", err)
+	}
+	chargeFees := make(map[string]*big.Int)
+	for _, d := range delegates {
+		if fee, ok := new(big.Int).SetString(d.ChargeFee, 10); ok {
+			chargeFees[d.DelegateName] = fee
+		}
+	}
+
+	var payouts []*Payout
+	chunks, err := dao.FindDistributionChunksByEpoch(endEpoch)
+	if err != nil {
+		return nil, fmt.Errorf("query distribution chunks error: %v", err)
+	}
+	for _, chunk := range chunks {
+		recipients, amounts, err := chunkRecipients(chunk)
+		if err != nil {
+			return nil, err
+		}
+		for i, voter := range recipients {
+			p := &Payout{
+				EndEpoch:     endEpoch,
+				DelegateName: chunk.DelegateName,
+				Voter:        voter,
+				Route:        RouteTransfer,
+				MergedSmall:  merged[payoutKey{chunk.DelegateName, voter}],
+				ChargeFee:    chargeFees[chunk.DelegateName],
+				Status:       chunk.Status,
+				Hash:         chunk.Hash,
+			}
+			if p.ChargeFee != nil {
+				p.Gross = new(big.Int).Add(amounts[i], p.ChargeFee)
+				p.Gross.Sub(p.Gross, zeroIfNil(p.MergedSmall))
+			}
+			payouts = append(payouts, p)
+		}
+	}
+
+	drops, err := dao.FindDropRecordsByEpoch(endEpoch)
+	if err != nil {
+		return nil, fmt.Errorf("query drop records error: %v", err)
+	}
+	mergedInto, err := mergedDropAmounts(drops)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range drops {
+		key := payoutKey{record.DelegateName, record.Voter}
+		p := &Payout{
+			EndEpoch:     endEpoch,
+			DelegateName: record.DelegateName,
+			Voter:        record.Voter,
+			Route:        RouteCompound,
+			MergedSmall:  merged[key],
+		}
+		amount, ok := new(big.Int).SetString(record.Amount, 10)
+		if !ok {
+			return nil, fmt.Errorf("drop record %d has invalid amount %s", record.ID, record.Amount)
+		}
+		if target, ok := mergedTarget(record.Status); ok {
+			// paid by the deposit of the record it was merged into, along with its fee
+			pay, err := l.drop(target)
+			if err != nil {
+				return nil, err
+			}
+			p.Status, p.Hash, p.GasFee = pay.status, pay.hash, big.NewInt(0)
+		} else {
+			pay := dropPayment(record)
+			p.Status, p.Hash = pay.status, pay.hash
+			if deposited, ok := new(big.Int).SetString(record.Deposited, 10); ok && pay.status != StatusUnpaid {
+				p.GasFee = new(big.Int).Sub(amount, deposited)
+			}
+			if other := mergedInto[record.ID]; other != nil {
+				amount.Sub(amount, other)
+			}
+		}
+		p.Gross = amount.Sub(amount, zeroIfNil(p.MergedSmall))
+		payouts = append(payouts, p)
+	}
+
+	smalls, err := dao.FindSmallRecordsByEpoch(endEpoch)
+	if err != nil {
+		return nil, fmt.Errorf("query small records error: %v", err)
+	}
+	for _, small := range smalls {
+		amount, ok := new(big.Int).SetString(small.Amount, 10)
+		if !ok {
+			return nil, fmt.Errorf("small record %d has invalid amount %s", small.ID, small.Amount)
+		}
+		p := &Payout{
+			EndEpoch:     endEpoch,
+			DelegateName: small.DelegateName,
+			Voter:        small.Voter,
+			Route:        RouteDeferred,
+			Gross:        amount,
+			Status:       small.Status,
+		}
+		if small.Status == "completed" {
+			p.PaidEpoch = small.SentEpoch
+			pay, err := l.payment(small.SentEpoch, payoutKey{small.DelegateName, small.Voter})
+			if err != nil {
+				return nil, err
+			}
+			p.Hash = pay.hash
+		}
+		payouts = append(payouts, p)
+	}
+
+	result := payouts[:0]
+	for _, p := range payouts {
+		if delegate == "" || p.DelegateName == delegate {
+			result = append(result, p)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].DelegateName != result[j].DelegateName {
+			return result[i].DelegateName < result[j].DelegateName
+		}
+		return result[i].Voter < result[j].Voter
+	})
+	return result, nil
+}
+
+// mergedSmalls returns the deferred amounts of earlier epochs paid in endEpoch
+func mergedSmalls(endEpoch uint64) (map[payoutKey]*big.Int, error) {
+	smalls, err := dao.FindSmallRecordsBySentEpoch(endEpoch)
+	if err != nil {
+		return nil, fmt.Errorf("query small records error: %v", err)
+	}
+	result := make(map[payoutKey]*big.Int)
+	for _, small := range smalls {
+		if small.EndEpoch == endEpoch {
+			continue
+		}
+		amount, ok := new(big.Int).SetString(small.Amount, 10)
+		if !ok {
+			return nil, fmt.Errorf("small record %d has invalid amount %s", small.ID, small.Amount)
+		}
+		key := payoutKey{small.DelegateName, small.Voter}
+		if result[key] == nil {
+			result[key] = big.NewInt(0)
+		}
+		result[key].Add(result[key], amount)
+	}
+	return result, nil
+}
+
+// mergedDropAmounts returns the amounts of the records merged into each of drops
+func mergedDropAmounts(drops []dao.DropRecord) (map[uint]*big.Int, error) {
+	statuses := make([]string, len(drops))
+	for i, record := range drops {
+		statuses[i] = fmt.Sprintf("merged-%d", record.ID)
+	}
+	result := make(map[uint]*big.Int)
+	if len(statuses) == 0 {
+		return result, nil
+	}
+	merged, err := dao.FindDropRecordsByStatuses(statuses)
+	if err != nil {
+		return nil, fmt.Errorf("query merged drop records error: %v", err)
+	}
+	for _, record := range merged {
+		target, _ := mergedTarget(record.Status)
+		amount, ok := new(big.Int).SetString(record.Amount, 10)
+		if !ok {
+			return nil, fmt.Errorf("drop record %d has invalid amount %s", record.ID, record.Amount)
+		}
+		if result[target] == nil {
+			result[target] = big.NewInt(0)
+		}
+		result[target].Add(result[target], amount)
+	}
+	return result, nil
+}
+
+// mergedTarget returns the ID of the record a merged-N record was merged into
+func mergedTarget(status string) (uint, bool) {
+	if !strings.HasPrefix(status, "merged-") {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(status, "merged-"), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// drop returns the payment of the drop record id
+func (l *payoutLedger) drop(id uint) (payment, error) {
+	record, ok := l.drops[id]
+	if !ok {
+		records, err := dao.FindDropRecordsByIDs([]uint{id})
+		if err != nil {
+			return payment{}, fmt.Errorf("query drop record %d error: %v", id, err)
+		}
+		if len(records) == 0 {
+			return payment{status: "missing"}, nil
+		}
+		record = records[0]
+		l.drops[id] = record
+	}
+	return dropPayment(record), nil
+}
+
+// dropPayment returns the payment of a drop record
+func dropPayment(record dao.DropRecord) payment {
+	if record.Status == "completed" && record.Deposited == "0" {
+		return payment{status: StatusUnpaid}
+	}
+	return payment{hash: record.Hash, status: record.Status}
+}
+
+// payment returns how key was paid in endEpoch, by transfer or deposit
+func (l *payoutLedger) payment(endEpoch uint64, key payoutKey) (payment, error) {
+	payments, ok := l.payments[endEpoch]
+	if !ok {
+		payments = make(map[payoutKey]payment)
+		chunks, err := dao.FindDistributionChunksByEpoch(endEpoch)
+		if err != nil {
+			return payment{}, fmt.Errorf("query distribution chunks error: %v", err)
+		}
+		for _, chunk := range chunks {
+			recipients, _, err := chunkRecipients(chunk)
+			if err != nil {
+				return payment{}, err
+			}
+			for _, voter := range recipients {
+				payments[payoutKey{chunk.DelegateName, voter}] = payment{hash: chunk.Hash, status: chunk.Status}
+			}
+		}
+		drops, err := dao.FindDropRecordsByEpoch(endEpoch)
+		if err != nil {
+			return payment{}, fmt.Errorf("query drop records error: %v", err)
+		}
+		for _, record := range drops {
+			pay := dropPayment(record)
+			if target, ok := mergedTarget(record.Status); ok {
+				if pay, err = l.drop(target); err != nil {
+					return payment{}, err
+				}
+			}
+			payments[payoutKey{record.DelegateName, record.Voter}] = pay
+		}
+		l.payments[endEpoch] = payments
+	}
+	return payments[key], nil
+}
+
+// chunkRecipients returns the io addresses and amounts of a chunk
+func chunkRecipients(chunk *dao.DistributionChunk) ([]string, []*big.Int, error) {
+	addrList, amountList, err := decodeChunk(chunk)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s %v", chunk.DelegateName, err)
+	}
+	recipients := make([]string, len(addrList))
+	for i, addr := range addrList {
+		ioAddr, err := address.FromBytes(addr.Bytes())
+		if err != nil {
+			return nil, nil, err
+		}
+		recipients[i] = ioAddr.String()
+	}
+	return recipients, amountList, nil
+}
+
+func zeroIfNil(v *big.Int) *big.Int {
+	if v == nil {
+		return big.NewInt(0)
+	}
+	return v
+}
+
+// WritePayoutsCSV writes payouts as CSV with a header row, unknown amounts are empty
+func WritePayoutsCSV(w io.Writer, payouts []*Payout) error {
+	amount := func(v *big.Int) string {
+		if v == nil {
+			return ""
+		}
+		return v.String()
+	}
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"end_epoch", "delegate", "voter", "route", "gross", "merged_small", "charge_fee", "gas_fee", "status", "paid_epoch", "hash"})
+	for _, p := range payouts {
+		paidEpoch := ""
+		if p.PaidEpoch != 0 {
+			paidEpoch = strconv.FormatUint(p.PaidEpoch, 10)
+		}
+		cw.Write([]string{
+			strconv.FormatUint(p.EndEpoch, 10), p.DelegateName, p.Voter, p.Route,
+			amount(p.Gross), amount(p.MergedSmall), amount(p.ChargeFee), amount(p.GasFee), p.Status, paidEpoch, p.Hash,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WritePayoutsJSON writes payouts as an indented JSON array
+func WritePayoutsJSON(w io.Writer, payouts []*Payout) error {
+	if payouts == nil {
+		payouts = []*Payout{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(payouts)
+}
@@ -0,0 +1,108 @@
+package distribute
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/iotexproject/iotex-address/address"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+func TestPayouts(t *testing.T) {
+	require := require.New(t)
+	require.NoError(dao.Open(config.DialectSQLite, filepath.Join(t.TempDir(), "hermes.db")))
+	t.Cleanup(func() { dao.DB().Close() })
+	_, err := dao.MigrateUp(0)
+	require.NoError(err)
+
+	voters := make([]string, 3)
+	for i := range voters {
+		addr, err := address.FromBytes(common.BigToAddress(big.NewInt(int64(i + 1))).Bytes())
+		require.NoError(err)
+		voters[i] = addr.String()
+	}
+	recipients := func(voter string) string {
+		data, err := json.Marshal([]string{voter})
+		require.NoError(err)
+		return string(data)
+	}
+	create := func(v interface{}) {
+		require.NoError(dao.DB().Create(v).Error)
+	}
+	create(&dao.DistributionDelegate{EndEpoch: 20, DelegateName: "a", ChargeFee: "3"})
+	create(&dao.DistributionChunk{DelegateID: 1, EndEpoch: 20, DelegateName: "a", Recipients: recipients(voters[0]),
+		Amounts: `["97"]`, Status: "completed", Hash: "c1"})
+	// deferred at 10 and paid with the transfer of 20
+	create(&dao.SmallRecord{EndEpoch: 10, SentEpoch: 20, DelegateName: "a", Voter: voters[0], Amount: "5", Status: "completed"})
+	// compounded at 10 and merged into the deposit of 20
+	older := &dao.DropRecord{EndEpoch: 10, DelegateName: "a", Voter: voters[1], Amount: "40", Status: "new"}
+	create(older)
+	record := &dao.DropRecord{EndEpoch: 20, DelegateName: "a", Voter: voters[1], Amount: "140",
+		Status: "completed", Hash: "d1", Deposited: "139"}
+	create(record)
+	older.Status = "merged-2"
+	require.NoError(dao.DB().Save(older).Error)
+	create(&dao.DropRecord{EndEpoch: 20, DelegateName: "b", Voter: voters[1], Amount: "8", Status: "merged-2"})
+	create(&dao.SmallRecord{EndEpoch: 20, DelegateName: "a", Voter: voters[2], Amount: "2", Status: "new"})
+	create(&dao.SmallRecord{EndEpoch: 20, DelegateName: "b", Voter: voters[2], Amount: "1", Status: "completed", SentEpoch: 30})
+	create(&dao.DistributionDelegate{EndEpoch: 30, DelegateName: "b", ChargeFee: "3"})
+	create(&dao.DistributionChunk{DelegateID: 2, EndEpoch: 30, DelegateName: "b", Recipients: recipients(voters[2]),
+		Amounts: `["7"]`, Status: "completed", Hash: "c2"})
+
+	payouts, err := Payouts(20, "")
+	require.NoError(err)
+	require.Len(payouts, 5)
+	amount := func(v *big.Int) string {
+		if v == nil {
+			return ""
+		}
+		return v.String()
+	}
+	rows := make([][]string, len(payouts))
+	for i, p := range payouts {
+		rows[i] = []string{p.DelegateName, p.Voter, p.Route, amount(p.Gross), amount(p.MergedSmall), amount(p.ChargeFee), amount(p.GasFee), p.Status, p.Hash}
+	}
+	require.Equal([][]string{
+		{"a", voters[0], RouteTransfer, "95", "5", "3", "", "completed", "c1"},
+		{"a", voters[2], RouteDeferred, "2", "", "", "", "new", ""},
+		{"a", voters[1], RouteCompound, "92", "", "", "1", "completed", "d1"},
+		{"b", voters[2], RouteDeferred, "1", "", "", "", "completed", "c2"},
+		{"b", voters[1], RouteCompound, "8", "", "", "0", "completed", "d1"},
+	}, rows)
+	require.Equal(uint64(30), payouts[3].PaidEpoch)
+
+	payouts, err = Payouts(20, "b")
+	require.NoError(err)
+	require.Len(payouts, 2)
+
+	var buf bytes.Buffer
+	require.NoError(WritePayoutsCSV(&buf, payouts[1:]))
+	require.Equal("end_epoch,delegate,voter,route,gross,merged_small,charge_fee,gas_fee,status,paid_epoch,hash\n"+
+		"20,b,"+voters[1]+",compound,8,,,0,completed,,d1\n", buf.String())
+
+	buf.Reset()
+	require.NoError(WritePayoutsJSON(&buf, nil))
+	require.Equal("[]\n", buf.String())
+
+	// the amount didn't cover the gas, nothing was sent
+	skipped := &dao.DropRecord{EndEpoch: 40, DelegateName: "a", Voter: voters[0], Amount: "3", Status: "completed", Deposited: "0"}
+	create(skipped)
+	create(&dao.DropRecord{EndEpoch: 40, DelegateName: "b", Voter: voters[0], Amount: "1", Status: fmt.Sprintf("merged-%d", skipped.ID)})
+	payouts, err = Payouts(40, "")
+	require.NoError(err)
+	require.Len(payouts, 2)
+	for _, p := range payouts {
+		require.Equal(StatusUnpaid, p.Status)
+		require.Empty(p.Hash)
+	}
+	require.Nil(payouts[0].GasFee)
+	require.Equal("2", payouts[0].Gross.String())
+}
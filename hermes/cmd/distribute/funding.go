@@ -0,0 +1,308 @@
+package distribute
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/iotexproject/go-pkgs/hash"
+	"github.com/iotexproject/iotex-address/address"
+
+	"github.com/ququzone/hermes-patch/hermes/chain"
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+	"github.com/ququzone/hermes-patch/hermes/metrics"
+	"github.com/ququzone/hermes-patch/hermes/notify"
+)
+
+// fundingPolling is how long a funding transfer receipt is waited for
+var fundingPolling = chain.DefaultPolling
+
+// storeFunding writes a funding transfer
+var storeFunding = func(funding *dao.FundingTransfer) error {
+	return funding.Save(nil)
+}
+
+// fundSender settles the funding transfers left by previous runs, then merges
+// the pending records and transfers their total plus previous to sender
+func fundSender(ctx context.Context, notifier *notify.Alerter, c chain.Client, sender address.Address, previous *big.Int, reference string) error {
+	unfinished, err := dao.FindUnfinishedFundingTransfers()
+	if err != nil {
+		return fmt.Errorf("query funding transfers error: %v", err)
+	}
+	for _, funding := range unfinished {
+		if err := settleFunding(ctx, notifier, c, funding); err != nil {
+			return err
+		}
+	}
+	funding, err := mergeCompound(sender, previous, reference)
+	if err != nil {
+		return err
+	}
+	if funding == nil {
+		return nil
+	}
+	return settleFunding(ctx, notifier, c, funding)
+}
+
+// mergeCompound merges the pending drop records of each voter into one new
+// record and links them to a new funding transfer of their total plus
+// previous, in one transaction. A previous balance needs a reference, and one
+// already included by a funding transfer to recipient is not added again. It
+// returns nil when there is nothing to fund.
+func mergeCompound(recipient address.Address, previous *big.Int, reference string) (*dao.FundingTransfer, error) {
+	voters, err := dao.FindVotersByStatus("pending")
+	if err != nil {
+		return nil, fmt.Errorf("query new voters error: %v", err)
+	}
+	if previous == nil {
+		previous = big.NewInt(0)
+	}
+	if previous.Sign() > 0 {
+		if reference == "" {
+			return nil, fmt.Errorf("previous %s has no reference", previous.String())
+		}
+		funding, err := dao.FindFundingTransferByReference(recipient.String(), reference)
+		if err != nil {
+			return nil, fmt.Errorf("query funding transfers error: %v", err)
+		}
+		if funding != nil && funding.Previous != previous.String() {
+			return nil, fmt.Errorf("reference %s is used by funding transfer %d for previous %s",
+				reference, funding.ID, funding.Previous)
+		}
+		if funding != nil {
+			log.Printf("previous %s of %s is already included by funding transfer %d\n", previous.String(), reference, funding.ID)
+			previous = big.NewInt(0)
+		}
+	}
+	if len(voters) == 0 && previous.Sign() == 0 {
+		return nil, nil
+	}
+
+	tx := dao.Transaction()
+	funding := &dao.FundingTransfer{
+		Recipient: recipient.String(),
+		Status:    dao.FundingPending,
+	}
+	if previous.Sign() > 0 {
+		funding.Previous = previous.String()
+		funding.Reference = reference
+	}
+	if err := funding.Save(tx); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("save funding transfer error: %v", err)
+	}
+	total := new(big.Int).Set(previous)
+	for _, voter := range voters {
+		rows, err := dao.FindByVoterAndStatus(voter, "pending")
+		if err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("query new rewards by voter error: %v", err)
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		amount, _ := new(big.Int).SetString(rows[0].Amount, 10)
+		for i := 1; i < len(rows); i++ {
+			temp, _ := new(big.Int).SetString(rows[i].Amount, 10)
+			amount = new(big.Int).Add(amount, temp)
+			rows[i].Status = fmt.Sprintf("merged-%d", rows[0].ID)
+			if err = rows[i].Save(tx); err != nil {
+				tx.Rollback()
+				return nil, fmt.Errorf("save merged record error: %v", err)
+			}
+		}
+		total = new(big.Int).Add(total, amount)
+		rows[0].Status = "new"
+		rows[0].Signature = ""
+		rows[0].Amount = amount.String()
+		rows[0].FundingID = funding.ID
+		if err = rows[0].Save(tx); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("save merged to record error: %v", err)
+		}
+		if rows[0].EndEpoch > funding.EndEpoch {
+			funding.EndEpoch = rows[0].EndEpoch
+		}
+	}
+	funding.Total = total.String()
+	if err := funding.Save(tx); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("save funding transfer error: %v", err)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("commit funding transfer error: %v", err)
+	}
+	return funding, nil
+}
+
+// settleFunding completes a funding transfer. A sent transfer is checked on
+// chain before it is sent again, with the same nonce so it can't pay twice.
+func settleFunding(ctx context.Context, notifier *notify.Alerter, c chain.Client, funding *dao.FundingTransfer) error {
+	if funding.Status == dao.FundingSent {
+		done, err := checkFunding(ctx, notifier, c, funding)
+		if err != nil || done {
+			return err
+		}
+	}
+	return sendFunding(ctx, notifier, c, funding)
+}
+
+// sendFunding sends a pending funding transfer, or a sent one whose action
+// is not minted, and waits for its receipt
+func sendFunding(ctx context.Context, notifier *notify.Alerter, c chain.Client, funding *dao.FundingTransfer) error {
+	recipient, err := address.FromString(funding.Recipient)
+	if err != nil {
+		return fmt.Errorf("funding transfer %d has invalid recipient %s", funding.ID, funding.Recipient)
+	}
+	total, ok := new(big.Int).SetString(funding.Total, 10)
+	if !ok {
+		return fmt.Errorf("funding transfer %d has invalid total %s", funding.ID, funding.Total)
+	}
+	amount := total
+	if funding.Status == dao.FundingSent || funding.Nonce != 0 {
+		// the transfer may have reached the node, send the same amount again
+		amount, _ = new(big.Int).SetString(funding.Amount, 10)
+	} else {
+		balance, err := c.Balance(ctx, c.Address())
+		if err != nil {
+			return err
+		}
+		metrics.Balance.WithLabelValues(c.Address().String()).Set(metrics.IOTX(balance))
+		if balance.Cmp(total) < 0 {
+			fmt.Printf("Account balance less than compound rewards: %s < %s\n", balance.String(), total.String())
+			notifier.Criticalf("Account balance less than compound rewards: %s < %s", balance.String(), total.String())
+			amount = new(big.Int).Sub(balance, big.NewInt(1000000000000000000))
+		}
+	}
+	if amount == nil || amount.Sign() <= 0 {
+		return fmt.Errorf("funding transfer %d has nothing to send", funding.ID)
+	}
+
+	gasPrice, err := checkGasPrice(ctx, notifier, c)
+	if err != nil {
+		return err
+	}
+	gas, err := c.EstimateTransfer(ctx, recipient, amount)
+	if err != nil {
+		return fmt.Errorf("estimate compound transfer gas error: %v", err)
+	}
+	limit, err := gasLimit(gas)
+	if err != nil {
+		return err
+	}
+	if funding.Nonce == 0 {
+		if funding.Nonce, err = c.PendingNonce(ctx); err != nil {
+			return fmt.Errorf("get pending nonce error: %v", err)
+		}
+		funding.Amount = amount.String()
+		if err := storeFunding(funding); err != nil {
+			return fmt.Errorf("save funding transfer error: %v", err)
+		}
+	}
+
+	h, err := c.Transfer(ctx, recipient, amount, chain.Opts{
+		GasPrice: gasPrice,
+		GasLimit: limit,
+		Nonce:    funding.Nonce,
+	})
+	if errors.Is(err, chain.ErrNonceConflict) {
+		// the nonce is used, by the transfer sent before if it was minted since
+		if funding.Status == dao.FundingSent {
+			if done, err := checkFunding(ctx, notifier, c, funding); err != nil || done {
+				return err
+			}
+		}
+		return fmt.Errorf("nonce %d of funding transfer %d is used by another action, check the account history "+
+			"and run `funding resolve %d` with --hash of the transfer or --failed before running again",
+			funding.Nonce, funding.ID, funding.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("transfer to compound sender error: %v", err)
+	}
+	funding.Hash = hex.EncodeToString(h[:])
+	funding.Status = dao.FundingSent
+	if err := storeFunding(funding); err != nil {
+		return fmt.Errorf("save funding transfer error: %v", err)
+	}
+	notifier.Infof("transfer %s to compound sender with hash: %s", amount.String(), funding.Hash)
+
+	// the transfer is sent, its receipt is waited for even if ctx is done
+	done, err := checkFunding(context.WithoutCancel(ctx), notifier, c, funding)
+	switch {
+	case err != nil || done:
+		return err
+	case funding.Status == dao.FundingPending:
+		return fmt.Errorf("funding transfer %d failed: %s", funding.ID, funding.ErrorMessage)
+	}
+	notifier.Errorf("funding transfer %d action %s is not minted yet, it is checked again next run", funding.ID, funding.Hash)
+	return nil
+}
+
+// ResolveFunding settles by hand a funding transfer whose nonce was used by
+// another action. With hash, that action is the transfer: it is recorded as
+// sent and its receipt is checked by the next run. Without it, the transfer
+// was not sent and the next run sends it with a new nonce.
+func ResolveFunding(id uint, hash string) error {
+	funding, err := dao.FindFundingTransfer(id)
+	if err != nil {
+		return fmt.Errorf("query funding transfer %d error: %v", id, err)
+	}
+	if funding.Status == dao.FundingCompleted {
+		return fmt.Errorf("funding transfer %d is completed", id)
+	}
+	if hash != "" {
+		if data, err := hex.DecodeString(hash); err != nil || len(data) != 32 {
+			return fmt.Errorf("invalid hash %s", hash)
+		}
+		funding.Hash = hash
+		funding.Status = dao.FundingSent
+	} else {
+		funding.Hash = ""
+		funding.Nonce = 0
+		funding.Amount = ""
+		funding.Status = dao.FundingPending
+	}
+	if err := storeFunding(funding); err != nil {
+		return fmt.Errorf("save funding transfer error: %v", err)
+	}
+	return nil
+}
+
+// checkFunding waits for the receipt of a sent funding transfer and reports
+// whether it is settled. A failed transfer is pending again, to be sent with
+// a new nonce.
+func checkFunding(ctx context.Context, notifier *notify.Alerter, c chain.Client, funding *dao.FundingTransfer) (bool, error) {
+	data, err := hex.DecodeString(funding.Hash)
+	if err != nil {
+		return false, fmt.Errorf("funding transfer %d has invalid hash %s", funding.ID, funding.Hash)
+	}
+	h := hash.BytesToHash256(data)
+	receipt, err := chain.WaitReceipt(ctx, c, h, fundingPolling)
+	if err == chain.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	funding.GasConsumed = receipt.GasConsumed
+	if err := chain.ReceiptError(h, receipt); err != nil {
+		notifier.Errorf("send transfer sender action %s error: %v", funding.Hash, err)
+		funding.Status = dao.FundingPending
+		funding.ErrorMessage = err.Error()
+		funding.Nonce = 0
+		funding.Hash = ""
+		if err := storeFunding(funding); err != nil {
+			return false, fmt.Errorf("save funding transfer error: %v", err)
+		}
+		return false, nil
+	}
+	funding.Status = dao.FundingCompleted
+	funding.ErrorMessage = ""
+	if err := storeFunding(funding); err != nil {
+		return false, fmt.Errorf("save funding transfer error: %v", err)
+	}
+	return true, nil
+}
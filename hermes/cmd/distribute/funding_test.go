@@ -0,0 +1,198 @@
+package distribute
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/iotexproject/iotex-antenna-go/v2/account"
+	"github.com/iotexproject/iotex-proto/golang/iotextypes"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ququzone/hermes-patch/hermes/chain"
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+func TestSettleFunding(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	fake, c := newFakeChain(t)
+	fake.SetBalance(c.Address(), new(big.Int).Mul(big.NewInt(1000), big.NewInt(1000000000000000000)))
+	sender, err := account.HexStringToAccount(testVoterPrivateKey)
+	require.NoError(err)
+	stored := 0
+	storeFunding = func(*dao.FundingTransfer) error {
+		stored++
+		return nil
+	}
+	amount := big.NewInt(1000000000000000000)
+	newFunding := func() *dao.FundingTransfer {
+		return &dao.FundingTransfer{
+			Recipient: sender.Address().String(),
+			Total:     amount.String(),
+			Status:    dao.FundingPending,
+		}
+	}
+
+	funding := newFunding()
+	nonce := fake.Nonce(c.Address())
+	require.NoError(settleFunding(ctx, nil, c, funding))
+	require.Equal(dao.FundingCompleted, funding.Status)
+	require.Equal(nonce+1, funding.Nonce)
+	require.Equal(amount.String(), funding.Amount)
+	require.Equal(chain.FakeActionGas, funding.GasConsumed)
+	require.Equal(amount, fake.BalanceOf(sender.Address()))
+
+	t.Run("sent transfer minted later is not sent again", func(t *testing.T) {
+		funding := newFunding()
+		fake.DelayNext(4)
+		require.NoError(settleFunding(ctx, nil, c, funding))
+		require.Equal(dao.FundingSent, funding.Status)
+
+		nonce := fake.Nonce(c.Address())
+		require.NoError(settleFunding(ctx, nil, c, funding))
+		require.Equal(dao.FundingCompleted, funding.Status)
+		require.Equal(nonce, fake.Nonce(c.Address()))
+		require.Equal(new(big.Int).Mul(amount, big.NewInt(2)), fake.BalanceOf(sender.Address()))
+	})
+
+	t.Run("nonce used by the lost transfer is not paid twice", func(t *testing.T) {
+		funding := newFunding()
+		fake.DelayNext(-1)
+		require.NoError(settleFunding(ctx, nil, c, funding))
+		require.Equal(dao.FundingSent, funding.Status)
+
+		err := settleFunding(ctx, nil, c, funding)
+		require.ErrorContains(err, "is used by another action")
+		require.Equal(new(big.Int).Mul(amount, big.NewInt(3)), fake.BalanceOf(sender.Address()))
+	})
+
+	t.Run("pending transfer with a used nonce is not sent", func(t *testing.T) {
+		funding := newFunding()
+		funding.Nonce = fake.Nonce(c.Address())
+		funding.Amount = amount.String()
+		err := settleFunding(ctx, nil, c, funding)
+		require.ErrorContains(err, "is used by another action")
+		require.Equal(dao.FundingPending, funding.Status)
+	})
+
+	t.Run("pending transfer with a pinned nonce sends its amount", func(t *testing.T) {
+		funding := newFunding()
+		funding.Nonce = fake.Nonce(c.Address()) + 1
+		funding.Amount = "5"
+		balance := fake.BalanceOf(sender.Address())
+		require.NoError(settleFunding(ctx, nil, c, funding))
+		require.Equal(dao.FundingCompleted, funding.Status)
+		require.Equal(new(big.Int).Add(balance, big.NewInt(5)), fake.BalanceOf(sender.Address()))
+	})
+
+	t.Run("failed transfer is sent again with a new nonce", func(t *testing.T) {
+		funding := newFunding()
+		fake.FailNext(iotextypes.ReceiptStatus_Failure)
+		require.ErrorContains(settleFunding(ctx, nil, c, funding), "failed")
+		require.Equal(dao.FundingPending, funding.Status)
+		require.Zero(funding.Nonce)
+		require.NotEmpty(funding.ErrorMessage)
+
+		require.NoError(settleFunding(ctx, nil, c, funding))
+		require.Equal(dao.FundingCompleted, funding.Status)
+		require.Empty(funding.ErrorMessage)
+	})
+	require.NotZero(stored)
+}
+
+func TestMergeCompoundPrevious(t *testing.T) {
+	require := require.New(t)
+	require.NoError(dao.Open(config.DialectSQLite, filepath.Join(t.TempDir(), "hermes.db")))
+	t.Cleanup(func() { dao.DB().Close() })
+	_, err := dao.MigrateUp(0)
+	require.NoError(err)
+	sender, err := account.HexStringToAccount(testVoterPrivateKey)
+	require.NoError(err)
+
+	_, err = mergeCompound(sender.Address(), big.NewInt(7), "")
+	require.ErrorContains(err, "has no reference")
+
+	funding, err := mergeCompound(sender.Address(), big.NewInt(7), "epoch-100")
+	require.NoError(err)
+	require.Equal("7", funding.Total)
+	require.Equal("7", funding.Previous)
+	require.Equal("epoch-100", funding.Reference)
+
+	// the same merge run again doesn't transfer previous twice
+	funding, err = mergeCompound(sender.Address(), big.NewInt(7), "epoch-100")
+	require.NoError(err)
+	require.Nil(funding)
+
+	_, err = mergeCompound(sender.Address(), big.NewInt(8), "epoch-100")
+	require.ErrorContains(err, "is used by funding transfer")
+
+	// another balance of the same amount is transferred
+	funding, err = mergeCompound(sender.Address(), big.NewInt(7), "epoch-200")
+	require.NoError(err)
+	require.Equal("7", funding.Total)
+}
+
+func TestResolveFunding(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	fake, c := newFakeChain(t)
+	fake.SetBalance(c.Address(), new(big.Int).Mul(big.NewInt(1000), big.NewInt(1000000000000000000)))
+	require.NoError(dao.Open(config.DialectSQLite, filepath.Join(t.TempDir(), "hermes.db")))
+	t.Cleanup(func() { dao.DB().Close() })
+	_, err := dao.MigrateUp(0)
+	require.NoError(err)
+	storeFunding = func(funding *dao.FundingTransfer) error {
+		return funding.Save(nil)
+	}
+	sender, err := account.HexStringToAccount(testVoterPrivateKey)
+	require.NoError(err)
+	newFunding := func() *dao.FundingTransfer {
+		funding := &dao.FundingTransfer{
+			Recipient: sender.Address().String(),
+			Total:     "5",
+			Amount:    "5",
+			Nonce:     fake.Nonce(c.Address()) + 1,
+			Status:    dao.FundingPending,
+		}
+		require.NoError(funding.Save(nil))
+		return funding
+	}
+	reload := func(id uint) *dao.FundingTransfer {
+		funding, err := dao.FindFundingTransfer(id)
+		require.NoError(err)
+		return funding
+	}
+
+	// the transfer was sent by an action the run didn't record
+	funding := newFunding()
+	h, err := c.Transfer(ctx, sender.Address(), big.NewInt(5), chain.Opts{Nonce: funding.Nonce, GasLimit: 10000})
+	require.NoError(err)
+	require.ErrorContains(settleFunding(ctx, nil, c, funding), "funding resolve")
+	require.NoError(ResolveFunding(funding.ID, hex.EncodeToString(h[:])))
+	funding = reload(funding.ID)
+	require.Equal(dao.FundingSent, funding.Status)
+	require.NoError(settleFunding(ctx, nil, c, funding))
+	require.Equal(dao.FundingCompleted, reload(funding.ID).Status)
+	require.ErrorContains(ResolveFunding(funding.ID, ""), "is completed")
+
+	// the nonce was used by another action
+	funding = newFunding()
+	_, err = c.Transfer(ctx, c.Address(), big.NewInt(1), chain.Opts{Nonce: funding.Nonce, GasLimit: 10000})
+	require.NoError(err)
+	require.ErrorContains(settleFunding(ctx, nil, c, funding), "funding resolve")
+	require.ErrorContains(ResolveFunding(funding.ID, "zz"), "invalid hash")
+	require.NoError(ResolveFunding(funding.ID, ""))
+	funding = reload(funding.ID)
+	require.Equal(dao.FundingPending, funding.Status)
+	require.Zero(funding.Nonce)
+	balance := fake.BalanceOf(sender.Address())
+	require.NoError(settleFunding(ctx, nil, c, funding))
+	require.Equal(dao.FundingCompleted, reload(funding.ID).Status)
+	require.Equal(new(big.Int).Add(balance, big.NewInt(5)), fake.BalanceOf(sender.Address()))
+}
@@ -0,0 +1,28 @@
+package distribute
+
+import (
+	"fmt"
+
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+// defaultGasMargin is the percentage added to gas estimates when gas.margin is unset
+const defaultGasMargin = 20
+
+// gasLimit returns the gas limit of an action the node estimated, raised by
+// the configured margin and capped by the configured limit
+func gasLimit(estimate uint64) (uint64, error) {
+	cfg := config.Get().Gas
+	if cfg.Limit > 0 && estimate > cfg.Limit {
+		return 0, fmt.Errorf("estimated gas %d exceeds gas limit %d", estimate, cfg.Limit)
+	}
+	margin := cfg.Margin
+	if margin == 0 {
+		margin = defaultGasMargin
+	}
+	limit := estimate * uint64(100+margin) / 100
+	if cfg.Limit > 0 && limit > cfg.Limit {
+		limit = cfg.Limit
+	}
+	return limit, nil
+}
@@ -0,0 +1,31 @@
+package distribute
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+func TestGasLimit(t *testing.T) {
+	require := require.New(t)
+
+	config.Set(&config.Config{Gas: config.Gas{Limit: 100000}})
+	limit, err := gasLimit(50000)
+	require.NoError(err)
+	require.Equal(uint64(60000), limit)
+
+	// the margin is capped by the limit
+	limit, err = gasLimit(90000)
+	require.NoError(err)
+	require.Equal(uint64(100000), limit)
+
+	_, err = gasLimit(100001)
+	require.Error(err)
+
+	config.Set(&config.Config{Gas: config.Gas{Limit: 100000, Margin: 50}})
+	limit, err = gasLimit(50000)
+	require.NoError(err)
+	require.Equal(uint64(75000), limit)
+}
@@ -0,0 +1,97 @@
+package distribute
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/iotexproject/iotex-address/address"
+
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+)
+
+// newChunks converts the split recipients into ledger chunks
+func newChunks(divAddrList [][]common.Address, divAmountList [][]*big.Int) ([]*dao.DistributionChunk, error) {
+	chunks := make([]*dao.DistributionChunk, 0, len(divAddrList))
+	for i := range divAddrList {
+		recipients := make([]string, len(divAddrList[i]))
+		for j, addr := range divAddrList[i] {
+			ioAddr, err := address.FromBytes(addr.Bytes())
+			if err != nil {
+				return nil, err
+			}
+			recipients[j] = ioAddr.String()
+		}
+		amounts := make([]string, len(divAmountList[i]))
+		total := big.NewInt(0)
+		for j, amount := range divAmountList[i] {
+			amounts[j] = amount.String()
+			total.Add(total, amount)
+		}
+		recipientsJSON, err := json.Marshal(recipients)
+		if err != nil {
+			return nil, err
+		}
+		amountsJSON, err := json.Marshal(amounts)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, &dao.DistributionChunk{
+			ChunkIndex: i,
+			Recipients: string(recipientsJSON),
+			Amounts:    string(amountsJSON),
+			Total:      total.String(),
+		})
+	}
+	return chunks, nil
+}
+
+// decodeChunk returns the recipients and amounts of a ledger chunk
+func decodeChunk(chunk *dao.DistributionChunk) ([]common.Address, []*big.Int, error) {
+	var recipients, amounts []string
+	if err := json.Unmarshal([]byte(chunk.Recipients), &recipients); err != nil {
+		return nil, nil, fmt.Errorf("decode chunk %d recipients error: %v", chunk.ChunkIndex, err)
+	}
+	if err := json.Unmarshal([]byte(chunk.Amounts), &amounts); err != nil {
+		return nil, nil, fmt.Errorf("decode chunk %d amounts error: %v", chunk.ChunkIndex, err)
+	}
+	if len(recipients) != len(amounts) {
+		return nil, nil, fmt.Errorf("chunk %d has %d recipients but %d amounts", chunk.ChunkIndex, len(recipients), len(amounts))
+	}
+	addrList := make([]common.Address, len(recipients))
+	amountList := make([]*big.Int, len(amounts))
+	for i := range recipients {
+		ioAddr, err := address.FromString(recipients[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		addrList[i] = common.BytesToAddress(ioAddr.Bytes())
+		amount, ok := new(big.Int).SetString(amounts[i], 10)
+		if !ok {
+			return nil, nil, fmt.Errorf("chunk %d has invalid amount %s", chunk.ChunkIndex, amounts[i])
+		}
+		amountList[i] = amount
+	}
+	return addrList, amountList, nil
+}
+
+// loadChunks returns the ledger chunks of a delegate after checking their signatures
+func loadChunks(delegate *dao.DistributionDelegate) ([]*dao.DistributionChunk, error) {
+	chunks, err := dao.FindDistributionChunks(delegate.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) != delegate.Chunks {
+		return nil, fmt.Errorf("delegate %s has %d chunks in ledger, expected %d", delegate.DelegateName, len(chunks), delegate.Chunks)
+	}
+	for i, chunk := range chunks {
+		if chunk.ChunkIndex != i {
+			return nil, fmt.Errorf("delegate %s misses chunk %d in ledger", delegate.DelegateName, i)
+		}
+		if err := chunk.Verify(); err != nil {
+			return nil, fmt.Errorf("chunk %d of delegate %s has invalid signature: %v", i, delegate.DelegateName, err)
+		}
+	}
+	return chunks, nil
+}
@@ -0,0 +1,38 @@
+package distribute
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunksRoundTrip(t *testing.T) {
+	require := require.New(t)
+
+	divAddrList := [][]common.Address{
+		{common.HexToAddress("0x01"), common.HexToAddress("0x02")},
+		{common.HexToAddress("0x03")},
+	}
+	divAmountList := [][]*big.Int{
+		{big.NewInt(10), big.NewInt(20)},
+		{big.NewInt(30)},
+	}
+	chunks, err := newChunks(divAddrList, divAmountList)
+	require.NoError(err)
+	require.Len(chunks, 2)
+	require.Equal(1, chunks[1].ChunkIndex)
+	require.Equal("30", chunks[0].Total)
+
+	for i, chunk := range chunks {
+		addrList, amountList, err := decodeChunk(chunk)
+		require.NoError(err)
+		require.Equal(divAddrList[i], addrList)
+		require.Equal(divAmountList[i], amountList)
+	}
+
+	chunks[0].Amounts = `["10"]`
+	_, _, err = decodeChunk(chunks[0])
+	require.EqualError(err, "chunk 0 has 2 recipients but 1 amounts")
+}
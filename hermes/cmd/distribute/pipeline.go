@@ -0,0 +1,275 @@
+package distribute
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/iotexproject/go-pkgs/hash"
+	"github.com/iotexproject/iotex-proto/golang/iotextypes"
+
+	"github.com/ququzone/hermes-patch/hermes/chain"
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+	"github.com/ququzone/hermes-patch/hermes/metrics"
+)
+
+// pipeline sends distribution chunks with consecutive nonces, keeping up to
+// inFlight chunks waiting for their receipts. A chunk that fails is sent again
+// by the next run, after the chunks sent behind it were minted. The contract
+// doesn't depend on the order of the chunks: it adds their recipients to the
+// distributedCount of the delegate, which is checked against the ledger.
+type pipeline struct {
+	c        chain.Client
+	endEpoch *big.Int
+	tip      *big.Int
+	inFlight int
+}
+
+type chunkResult struct {
+	chunk   *dao.DistributionChunk
+	receipt *iotextypes.Receipt
+	err     error
+}
+
+// send submits chunks until one fails or ctx is done, then waits for the
+// chunks in flight and returns the first error. The ledger records every chunk
+// action before it is broadcast and every outcome, and the next run starts
+// again from the pending nonce of the account.
+func (p *pipeline) send(ctx context.Context, chunks []*dao.DistributionChunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	nonce, err := p.c.PendingNonce(ctx)
+	if err != nil {
+		return fmt.Errorf("get pending nonce error: %v", err)
+	}
+
+	results := make(chan chunkResult, len(chunks))
+	var firstErr error
+	inFlight := 0
+	next := 0
+	for {
+		for firstErr == nil && next < len(chunks) && inFlight < p.inFlight {
+			if err := ctx.Err(); err != nil {
+				firstErr = err
+				break
+			}
+			chunk := chunks[next]
+			h, err := p.submit(ctx, chunk, nonce)
+			if errors.Is(err, chain.ErrNonceConflict) {
+				// another sender used the account, rewind to the node's nonce
+				if nonce, err = p.c.PendingNonce(ctx); err == nil {
+					h, err = p.submit(ctx, chunk, nonce)
+				}
+			}
+			if errors.Is(err, errLedger) {
+				return err
+			}
+			if err != nil {
+				firstErr = fmt.Errorf("send chunk %d of delegate %s error: %v", chunk.ChunkIndex, chunk.DelegateName, err)
+				if err := saveChunk(chunk, dao.ChunkFailed, err); err != nil {
+					return err
+				}
+				break
+			}
+			nonce++
+			next++
+			inFlight++
+			go func(chunk *dao.DistributionChunk, h hash.Hash256, start time.Time) {
+				receipt, err := checkActionReceipt(p.c, h)
+				metrics.Since(metrics.ChunkLatency, start)
+				results <- chunkResult{chunk: chunk, receipt: receipt, err: err}
+			}(chunk, h, time.Now())
+		}
+		if inFlight == 0 {
+			return firstErr
+		}
+
+		result := <-results
+		inFlight--
+		if result.receipt != nil {
+			result.chunk.GasConsumed = result.receipt.GasConsumed
+		}
+		if result.err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("chunk %d of delegate %s error: %v", result.chunk.ChunkIndex, result.chunk.DelegateName, result.err)
+			}
+			if result.receipt == nil {
+				// the action may still be minted, the chunk stays sent until a
+				// run settles it
+				continue
+			}
+			if err := saveChunk(result.chunk, dao.ChunkFailed, result.err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := saveChunk(result.chunk, dao.ChunkCompleted, nil); err != nil {
+			return err
+		}
+		chunkPaid(result.chunk)
+	}
+}
+
+// errLedger is a chunk that couldn't be written to the ledger
+var errLedger = errors.New("ledger")
+
+// submit signs the action of chunk with nonce, records it as sent and
+// broadcasts it. An action the node may have taken is left to its receipt.
+func (p *pipeline) submit(ctx context.Context, chunk *dao.DistributionChunk, nonce uint64) (hash.Hash256, error) {
+	voterAddrList, amountList, err := decodeChunk(chunk)
+	if err != nil {
+		return hash.ZeroHash256, err
+	}
+	signed, err := signRewards(p.c, chunk.DelegateName, p.endEpoch, p.tip, voterAddrList, amountList, nonce)
+	if err != nil {
+		return hash.ZeroHash256, err
+	}
+	data, err := signed.Bytes()
+	if err != nil {
+		return hash.ZeroHash256, err
+	}
+	chunk.Hash = hex.EncodeToString(signed.Hash[:])
+	chunk.Nonce = signed.Nonce()
+	chunk.SignedAction = hex.EncodeToString(data)
+	if err := saveChunk(chunk, dao.ChunkSent, nil); err != nil {
+		return hash.ZeroHash256, fmt.Errorf("%w: %v", errLedger, err)
+	}
+	if err := p.c.SendAction(ctx, signed); err != nil {
+		if !errors.Is(err, chain.ErrRetryable) {
+			return hash.ZeroHash256, err
+		}
+		log.Printf("send chunk %d of delegate %s action %x error: %v\n", chunk.ChunkIndex, chunk.DelegateName, signed.Hash, err)
+	}
+	return signed.Hash, nil
+}
+
+// resumeChunks settles the chunks a previous run left in flight, checks the
+// ledger against the distributedCount of the contract and returns the chunks
+// still to send
+func resumeChunks(c chain.Client, delegateName string, chunks []*dao.DistributionChunk) ([]*dao.DistributionChunk, error) {
+	var pending []*dao.DistributionChunk
+	completed := 0
+	for _, chunk := range chunks {
+		if chunk.Status == dao.ChunkSent {
+			if err := settleChunk(c, chunk); err != nil {
+				return nil, err
+			}
+		}
+		if chunk.Status != dao.ChunkCompleted {
+			pending = append(pending, chunk)
+			continue
+		}
+		recipients, _, err := decodeChunk(chunk)
+		if err != nil {
+			return nil, err
+		}
+		completed += len(recipients)
+	}
+	distributedCount, err := getDistributedCount(c, delegateName)
+	if err != nil {
+		return nil, err
+	}
+	if int(distributedCount) != completed {
+		return nil, fmt.Errorf("distributed count %d of delegate %s doesn't match the %d recipients of completed chunks",
+			distributedCount, delegateName, completed)
+	}
+	return pending, nil
+}
+
+// settleChunk updates a sent chunk from its receipt. An action the node
+// doesn't know is broadcast again while its nonce is free, and the chunk
+// failed once another action took the nonce.
+func settleChunk(c chain.Client, chunk *dao.DistributionChunk) error {
+	ctx := context.Background()
+	data, err := hex.DecodeString(chunk.Hash)
+	if err != nil || len(data) != len(hash.ZeroHash256) {
+		return fmt.Errorf("chunk %d of delegate %s has invalid hash %s", chunk.ChunkIndex, chunk.DelegateName, chunk.Hash)
+	}
+	h := hash.BytesToHash256(data)
+	// the nonce is read before the receipt, an action minted by then has one
+	confirmed, err := c.ConfirmedNonce(ctx, c.Address())
+	if err != nil {
+		return err
+	}
+	receipt, err := chain.WaitReceipt(ctx, c, h, receiptPolling)
+	if err == chain.ErrNotFound && chunk.SignedAction != "" {
+		if _, err := c.Action(ctx, h); err == chain.ErrNotFound {
+			if confirmed >= chunk.Nonce {
+				return saveChunk(chunk, dao.ChunkFailed, fmt.Errorf("nonce %d is used by another action", chunk.Nonce))
+			}
+			if err := rebroadcastChunk(ctx, c, chunk, h); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+		receipt, err = chain.WaitReceipt(ctx, c, h, receiptPolling)
+	}
+	if err == chain.ErrNotFound {
+		return fmt.Errorf("chunk %d of delegate %s action %s is not minted yet", chunk.ChunkIndex, chunk.DelegateName, chunk.Hash)
+	}
+	if err != nil {
+		return err
+	}
+	chunk.GasConsumed = receipt.GasConsumed
+	if err := chain.ReceiptError(h, receipt); err != nil {
+		return saveChunk(chunk, dao.ChunkFailed, err)
+	}
+	if err := saveChunk(chunk, dao.ChunkCompleted, nil); err != nil {
+		return err
+	}
+	chunkPaid(chunk)
+	return nil
+}
+
+// rebroadcastChunk sends the recorded action of a sent chunk again
+func rebroadcastChunk(ctx context.Context, c chain.Client, chunk *dao.DistributionChunk, h hash.Hash256) error {
+	data, err := hex.DecodeString(chunk.SignedAction)
+	if err != nil {
+		return fmt.Errorf("chunk %d of delegate %s has invalid action", chunk.ChunkIndex, chunk.DelegateName)
+	}
+	signed, err := chain.ParseSignedAction(h, data)
+	if err != nil {
+		return err
+	}
+	if signed.Nonce() != chunk.Nonce {
+		return fmt.Errorf("chunk %d of delegate %s action has nonce %d instead of %d", chunk.ChunkIndex, chunk.DelegateName, signed.Nonce(), chunk.Nonce)
+	}
+	if err := c.SendAction(ctx, signed); err != nil && !errors.Is(err, chain.ErrNonceConflict) {
+		return fmt.Errorf("broadcast chunk %d of delegate %s again error: %v", chunk.ChunkIndex, chunk.DelegateName, err)
+	}
+	log.Printf("chunk %d of delegate %s action %x was broadcast again\n", chunk.ChunkIndex, chunk.DelegateName, h)
+	return nil
+}
+
+// chunkPaid adds the total of a completed chunk to the paid metric
+func chunkPaid(chunk *dao.DistributionChunk) {
+	if total, ok := new(big.Int).SetString(chunk.Total, 10); ok {
+		metrics.Paid.Add(metrics.IOTX(total))
+	}
+}
+
+// storeChunk writes a chunk to the ledger
+var storeChunk = func(chunk *dao.DistributionChunk) error {
+	return chunk.Save(nil)
+}
+
+func saveChunk(chunk *dao.DistributionChunk, status string, cause error) error {
+	chunk.Status = status
+	chunk.ErrorMessage = ""
+	if cause != nil {
+		chunk.ErrorMessage = cause.Error()
+	}
+	if err := storeChunk(chunk); err != nil {
+		return fmt.Errorf("save distribution chunk error: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package distribute
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/iotexproject/iotex-proto/golang/iotextypes"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ququzone/hermes-patch/hermes/chain"
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+)
+
+func newTestChunks(t *testing.T, name string, count, size int) []*dao.DistributionChunk {
+	divAddrList := make([][]common.Address, count)
+	divAmountList := make([][]*big.Int, count)
+	for i := 0; i < count; i++ {
+		for j := 0; j < size; j++ {
+			divAddrList[i] = append(divAddrList[i], common.BigToAddress(big.NewInt(int64(1000+i*size+j))))
+			divAmountList[i] = append(divAmountList[i], big.NewInt(int64(i+1)))
+		}
+	}
+	chunks, err := newChunks(divAddrList, divAmountList)
+	require.NoError(t, err)
+	for _, chunk := range chunks {
+		chunk.DelegateName = name
+		chunk.Status = dao.ChunkPending
+	}
+	storeChunk = func(*dao.DistributionChunk) error { return nil }
+	return chunks
+}
+
+func TestPipeline(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	fake, c := newFakeChain(t)
+	fake.SetBalance(c.Address(), new(big.Int).Mul(big.NewInt(1000), big.NewInt(1000000000000000000)))
+	minTips, err := getMinTips(c)
+	require.NoError(err)
+	p := &pipeline{c: c, endEpoch: big.NewInt(123), tip: minTips, inFlight: 3}
+
+	chunks := newTestChunks(t, "delegate", 5, 2)
+	nonce := fake.Nonce(c.Address())
+	// nothing more is sent once the first chunk reverts
+	p.inFlight = 1
+	fake.FailNext(iotextypes.ReceiptStatus_ErrExecutionReverted)
+	require.Error(p.send(ctx, chunks))
+	require.Equal(dao.ChunkFailed, chunks[0].Status)
+	require.Equal(dao.ChunkPending, chunks[1].Status)
+	require.Equal(nonce+1, fake.Nonce(c.Address()))
+
+	pending, err := resumeChunks(c, "delegate", chunks)
+	require.NoError(err)
+	require.Equal(chunks, pending)
+
+	// the node rejects a reused nonce once, the pipeline rewinds and goes on
+	p.inFlight = 3
+	fake.RejectNext(chain.ErrNonceTooLow)
+	require.NoError(p.send(ctx, pending))
+	for _, chunk := range chunks {
+		require.Equal(dao.ChunkCompleted, chunk.Status)
+		require.Equal(chain.FakeExecutionGas+2*25000, chunk.GasConsumed)
+	}
+	require.Equal(nonce+6, fake.Nonce(c.Address()))
+	count, err := getDistributedCount(c, "delegate")
+	require.NoError(err)
+	require.Equal(uint64(10), count)
+
+	pending, err = resumeChunks(c, "delegate", chunks)
+	require.NoError(err)
+	require.Empty(pending)
+
+	// chunks already in flight still complete after an earlier one reverts
+	chunks = newTestChunks(t, "other", 4, 2)
+	p.inFlight = 4
+	fake.FailNext(iotextypes.ReceiptStatus_ErrExecutionReverted)
+	require.Error(p.send(ctx, chunks))
+	require.Equal(dao.ChunkFailed, chunks[0].Status)
+	for _, chunk := range chunks[1:] {
+		require.Equal(dao.ChunkCompleted, chunk.Status)
+	}
+	pending, err = resumeChunks(c, "other", chunks)
+	require.NoError(err)
+	require.Equal(chunks[:1], pending)
+	require.NoError(p.send(ctx, pending))
+	count, err = getDistributedCount(c, "other")
+	require.NoError(err)
+	require.Equal(uint64(8), count)
+
+	// nothing is sent once shutdown started
+	chunks = newTestChunks(t, "stopped", 2, 2)
+	nonce = fake.Nonce(c.Address())
+	stopped, cancel := context.WithCancel(ctx)
+	cancel()
+	require.Equal(context.Canceled, p.send(stopped, chunks))
+	require.Equal(dao.ChunkPending, chunks[0].Status)
+	require.Equal(nonce, fake.Nonce(c.Address()))
+}
+
+func TestResumeSentChunks(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	fake, c := newFakeChain(t)
+	fake.SetBalance(c.Address(), new(big.Int).Mul(big.NewInt(1000), big.NewInt(1000000000000000000)))
+	minTips, err := getMinTips(c)
+	require.NoError(err)
+	p := &pipeline{c: c, endEpoch: big.NewInt(123), tip: minTips, inFlight: 2}
+
+	chunks := newTestChunks(t, "delegate", 2, 3)
+	// the receipt of the second chunk is never seen, as if the host stopped
+	fake.DelayNext(0)
+	fake.DelayNext(-1)
+	require.Error(p.send(ctx, chunks))
+	require.Equal(dao.ChunkCompleted, chunks[0].Status)
+	require.Equal(dao.ChunkSent, chunks[1].Status)
+
+	_, err = resumeChunks(c, "delegate", chunks)
+	require.Error(err)
+	require.Contains(err.Error(), "is not minted yet")
+
+	// the contract counted recipients the ledger doesn't know about
+	chunks[1].Status = dao.ChunkPending
+	_, err = resumeChunks(c, "delegate", chunks)
+	require.EqualError(err, "distributed count 6 of delegate delegate doesn't match the 3 recipients of completed chunks")
+}
+
+func TestResumeUnbroadcastChunks(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	fake, c := newFakeChain(t)
+	fake.SetBalance(c.Address(), new(big.Int).Mul(big.NewInt(1000), big.NewInt(1000000000000000000)))
+	minTips, err := getMinTips(c)
+	require.NoError(err)
+	p := &pipeline{c: c, endEpoch: big.NewInt(123), tip: minTips, inFlight: 1}
+
+	// the node loses the action, the ledger has it as sent with its nonce
+	chunks := newTestChunks(t, "delegate", 2, 2)
+	nonce := fake.Nonce(c.Address())
+	fake.RejectNext(chain.ErrRetryable)
+	require.Error(p.send(ctx, chunks[:1]))
+	require.Equal(dao.ChunkSent, chunks[0].Status)
+	require.Equal(nonce+1, chunks[0].Nonce)
+	require.NotEmpty(chunks[0].SignedAction)
+	require.Equal(nonce, fake.Nonce(c.Address()))
+
+	// the same action is broadcast again while its nonce is free
+	pending, err := resumeChunks(c, "delegate", chunks)
+	require.NoError(err)
+	require.Equal(chunks[1:], pending)
+	require.Equal(dao.ChunkCompleted, chunks[0].Status)
+	require.Equal(nonce+1, fake.Nonce(c.Address()))
+
+	// once another action took the nonce the chunk is sent again
+	fake.RejectNext(chain.ErrRetryable)
+	require.Error(p.send(ctx, pending))
+	require.Equal(dao.ChunkSent, chunks[1].Status)
+	_, err = c.Transfer(ctx, c.Address(), big.NewInt(1), chain.Opts{GasPrice: big.NewInt(chain.FakeGasPrice)})
+	require.NoError(err)
+	pending, err = resumeChunks(c, "delegate", chunks)
+	require.NoError(err)
+	require.Equal(chunks[1:], pending)
+	require.Equal(dao.ChunkFailed, chunks[1].Status)
+	require.NoError(p.send(ctx, pending))
+	count, err := getDistributedCount(c, "delegate")
+	require.NoError(err)
+	require.Equal(uint64(4), count)
+}
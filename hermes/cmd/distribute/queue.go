@@ -0,0 +1,129 @@
+package distribute
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+	"github.com/ququzone/hermes-patch/hermes/notify"
+	"github.com/ququzone/hermes-patch/hermes/util"
+)
+
+// benchDuration is how long an account that can't send is out of rotation
+var benchDuration = 30 * time.Minute
+
+// workQueue hands drop records to the account workers. A record is pending
+// from the time it is queued until a worker is done with it, so a batch
+// fetched while stragglers finish doesn't queue it twice.
+type workQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	records []dao.DropRecord
+	pending map[uint]bool
+	closed  bool
+}
+
+func newWorkQueue() *workQueue {
+	q := &workQueue{pending: make(map[uint]bool)}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push queues the records that are not pending and returns how many it queued
+func (q *workQueue) push(records []dao.DropRecord) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	added := 0
+	for _, record := range records {
+		if q.pending[record.ID] {
+			continue
+		}
+		q.pending[record.ID] = true
+		q.records = append(q.records, record)
+		added++
+	}
+	q.cond.Broadcast()
+	return added
+}
+
+// pull waits for a record, it returns false once the queue is closed
+func (q *workQueue) pull() (dao.DropRecord, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.records) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return dao.DropRecord{}, false
+	}
+	record := q.records[0]
+	q.records = q.records[1:]
+	q.cond.Broadcast()
+	return record, true
+}
+
+// requeue puts back a record a worker couldn't send, ahead of the others
+func (q *workQueue) requeue(record dao.DropRecord) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.records = append([]dao.DropRecord{record}, q.records...)
+	q.cond.Broadcast()
+}
+
+// done releases a record pulled by a worker
+func (q *workQueue) done(record dao.DropRecord) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.pending, record.ID)
+}
+
+// isPending reports whether the record id is queued or pulled by a worker
+func (q *workQueue) isPending(id uint) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.pending[id]
+}
+
+// waitEmpty waits until every queued record is pulled, it returns false once
+// the queue is closed
+func (q *workQueue) waitEmpty() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.records) > 0 && !q.closed {
+		q.cond.Wait()
+	}
+	return !q.closed
+}
+
+// close wakes up everyone waiting, the queued records are dropped and stay
+// new in the database
+func (q *workQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// work pulls records for one account until the queue is closed. When process
+// fails the record goes back to the queue and the account is out of rotation
+// for benchDuration.
+func work(ctx context.Context, q *workQueue, name string, notifier *notify.Alerter, process func(context.Context, dao.DropRecord) error) {
+	for {
+		record, ok := q.pull()
+		if !ok {
+			return
+		}
+		if err := process(ctx, record); err != nil {
+			q.requeue(record)
+			log.Printf("sender %s out of rotation for %v: %v\n", name, benchDuration, err)
+			notifier.Criticalf("Sender %s out of rotation for %v: %v", name, benchDuration, err)
+			if util.Sleep(ctx, benchDuration) != nil {
+				return
+			}
+			continue
+		}
+		q.done(record)
+	}
+}
@@ -0,0 +1,106 @@
+package distribute
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+)
+
+func testRecords(ids ...uint) []dao.DropRecord {
+	records := make([]dao.DropRecord, len(ids))
+	for i, id := range ids {
+		records[i].ID = id
+	}
+	return records
+}
+
+func TestWorkQueue(t *testing.T) {
+	require := require.New(t)
+
+	q := newWorkQueue()
+	require.Equal(3, q.push(testRecords(1, 2, 3)))
+	record, ok := q.pull()
+	require.True(ok)
+	require.Equal(uint(1), record.ID)
+
+	// records queued or in flight are not queued again
+	require.Equal(1, q.push(testRecords(1, 2, 4)))
+	q.done(record)
+	require.Equal(1, q.push(testRecords(1)))
+
+	// a requeued record is pulled first
+	record, _ = q.pull()
+	require.Equal(uint(2), record.ID)
+	q.requeue(record)
+	record, _ = q.pull()
+	require.Equal(uint(2), record.ID)
+
+	q.close()
+	_, ok = q.pull()
+	require.False(ok)
+	require.False(q.waitEmpty())
+}
+
+func TestWorkRotation(t *testing.T) {
+	require := require.New(t)
+	benchDuration = time.Hour
+	defer func() { benchDuration = 30 * time.Minute }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := newWorkQueue()
+	var (
+		mu      sync.Mutex
+		sent    = make(map[uint]int)
+		failing = make(chan uint, 1)
+	)
+	healthy := func(ctx context.Context, record dao.DropRecord) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sent[record.ID]++
+		return nil
+	}
+	underfunded := func(ctx context.Context, record dao.DropRecord) error {
+		failing <- record.ID
+		return errors.New("insufficient funds")
+	}
+
+	// the underfunded account takes the first record and is benched
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		work(ctx, q, "underfunded", nil, underfunded)
+	}()
+	q.push(testRecords(1, 2, 3, 4))
+	require.Equal(uint(1), <-failing)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		work(ctx, q, "healthy", nil, healthy)
+	}()
+	require.True(q.waitEmpty())
+
+	// the next batch is queued while the last records may still be in flight
+	require.Equal(1, q.push(testRecords(5)))
+	require.Eventually(func() bool { return sentCount(&mu, sent) == 5 }, time.Second, time.Millisecond)
+
+	cancel()
+	q.close()
+	wg.Wait()
+	for id, count := range sent {
+		require.Equal(1, count, "record %d", id)
+	}
+}
+
+func sentCount(mu *sync.Mutex, sent map[uint]int) int {
+	mu.Lock()
+	defer mu.Unlock()
+	return len(sent)
+}
@@ -0,0 +1,294 @@
+package distribute
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/iotexproject/go-pkgs/hash"
+	"github.com/iotexproject/iotex-address/address"
+	"github.com/iotexproject/iotex-proto/golang/iotextypes"
+
+	"github.com/ququzone/hermes-patch/hermes/chain"
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+// Reconciliation is the ledger of an epoch range to check against the chain
+type Reconciliation struct {
+	Drops  []dao.DropRecord
+	Smalls []dao.SmallRecord
+	Chunks []*dao.DistributionChunk
+	// Tips are the tips of the distribution runs by end epoch
+	Tips map[uint64]*big.Int
+}
+
+// Records returns the number of records checked
+func (r *Reconciliation) Records() int {
+	return len(r.Drops) + len(r.Smalls) + len(r.Chunks)
+}
+
+type reconciler struct {
+	c        chain.Client
+	hermes   abi.ABI
+	hashes   map[string]string
+	payees   map[string]string
+	findings []*dao.ReconcileFinding
+}
+
+// Reconcile fetches the receipt and action of every completed drop record and
+// chunk, and returns the payments which are missing, failed, duplicated or don't
+// match the ledger. A completed small record must be paid by a drop record or a
+// chunk of its sent epoch.
+func Reconcile(ctx context.Context, c chain.Client, r *Reconciliation) ([]*dao.ReconcileFinding, error) {
+	hermesABI, err := abi.JSON(strings.NewReader(HermesABI))
+	if err != nil {
+		return nil, err
+	}
+	rc := &reconciler{
+		c:      c,
+		hermes: hermesABI,
+		hashes: make(map[string]string),
+		payees: make(map[string]string),
+	}
+	for i := range r.Drops {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := rc.drop(ctx, &r.Drops[i]); err != nil {
+			return nil, err
+		}
+	}
+	for _, chunk := range r.Chunks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := rc.chunk(ctx, chunk, r.Tips[chunk.EndEpoch]); err != nil {
+			return nil, err
+		}
+	}
+	for i := range r.Smalls {
+		rc.small(&r.Smalls[i])
+	}
+	return rc.findings, nil
+}
+
+func paymentKey(endEpoch uint64, delegateName, voter string) string {
+	return fmt.Sprintf("%d,%s,%s", endEpoch, delegateName, voter)
+}
+
+func (r *reconciler) flag(base dao.ReconcileFinding, kind, expected, actual, format string, args ...interface{}) {
+	base.Kind = kind
+	base.Expected = expected
+	base.Actual = actual
+	base.Message = fmt.Sprintf(format, args...)
+	r.findings = append(r.findings, &base)
+}
+
+// pay records that voter is paid by record, a voter paid twice is flagged
+func (r *reconciler) pay(base dao.ReconcileFinding, voter, record string) {
+	key := paymentKey(base.EndEpoch, base.DelegateName, voter)
+	if other, ok := r.payees[key]; ok {
+		r.flag(base, dao.FindingDuplicated, "", voter, "voter %s of %s is also paid by %s", voter, record, other)
+		return
+	}
+	r.payees[key] = record
+}
+
+// action returns the action of a completed payment, it is nil if the payment
+// is flagged missing, failed or duplicated
+func (r *reconciler) action(ctx context.Context, base dao.ReconcileFinding, record string) (*iotextypes.ActionCore, error) {
+	if other, ok := r.hashes[base.Hash]; ok {
+		r.flag(base, dao.FindingDuplicated, "", base.Hash, "action %s of %s is also used by %s", base.Hash, record, other)
+		return nil, nil
+	}
+	data, err := hex.DecodeString(base.Hash)
+	if err != nil || len(data) != len(hash.ZeroHash256) {
+		r.flag(base, dao.FindingMissing, "", base.Hash, "%s has invalid hash %q", record, base.Hash)
+		return nil, nil
+	}
+	r.hashes[base.Hash] = record
+	h := hash.BytesToHash256(data)
+
+	receipt, err := r.c.Receipt(ctx, h)
+	if err == chain.ErrNotFound {
+		r.flag(base, dao.FindingMissing, "", "", "action %s of %s is not on chain", base.Hash, record)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get receipt of %s error: %v", record, err)
+	}
+	if receipt.Status != uint64(iotextypes.ReceiptStatus_Success) {
+		r.flag(base, dao.FindingFailed, fmt.Sprint(uint64(iotextypes.ReceiptStatus_Success)), fmt.Sprint(receipt.Status),
+			"action %s of %s failed with status %d", base.Hash, record, receipt.Status)
+		return nil, nil
+	}
+	core, err := r.c.Action(ctx, h)
+	if err == chain.ErrNotFound {
+		r.flag(base, dao.FindingMissing, "", "", "action %s of %s has a receipt but no action", base.Hash, record)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get action of %s error: %v", record, err)
+	}
+	return core, nil
+}
+
+// drop checks the transfer or deposit of a drop record, the gas fee is taken
+// from the record amount so the sent amount is at most the fee below it
+func (r *reconciler) drop(ctx context.Context, record *dao.DropRecord) error {
+	if record.Deposited == "0" {
+		// completed without an action, its amount didn't cover the gas
+		return nil
+	}
+	base := dao.ReconcileFinding{
+		RecordType:   dao.RecordDrop,
+		RecordID:     record.ID,
+		EndEpoch:     record.EndEpoch,
+		DelegateName: record.DelegateName,
+		Hash:         record.Hash,
+	}
+	name := fmt.Sprintf("drop record %d", record.ID)
+	r.pay(base, record.Voter, name)
+	core, err := r.action(ctx, base, name)
+	if err != nil || core == nil {
+		return err
+	}
+
+	var sent string
+	switch {
+	case core.GetTransfer() != nil:
+		sent = core.GetTransfer().Amount
+		if core.GetTransfer().Recipient != record.Voter {
+			r.flag(base, dao.FindingRecipientMismatch, record.Voter, core.GetTransfer().Recipient,
+				"%s is transferred to %s instead of %s", name, core.GetTransfer().Recipient, record.Voter)
+		}
+	case core.GetStakeAddDeposit() != nil:
+		sent = core.GetStakeAddDeposit().Amount
+		if core.GetStakeAddDeposit().BucketIndex != record.Index {
+			r.flag(base, dao.FindingRecipientMismatch, fmt.Sprint(record.Index), fmt.Sprint(core.GetStakeAddDeposit().BucketIndex),
+				"%s is deposited to bucket %d instead of %d", name, core.GetStakeAddDeposit().BucketIndex, record.Index)
+		}
+	default:
+		r.flag(base, dao.FindingRecipientMismatch, "", "", "action %s of %s is neither a transfer nor a deposit", record.Hash, name)
+		return nil
+	}
+
+	amount, _ := new(big.Int).SetString(record.Amount, 10)
+	actual, ok := new(big.Int).SetString(sent, 10)
+	gasPrice, _ := new(big.Int).SetString(core.GasPrice, 10)
+	if gasPrice == nil {
+		gasPrice = big.NewInt(0)
+	}
+	maxFee := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(core.GasLimit))
+	if amount == nil || !ok || actual.Cmp(amount) > 0 || new(big.Int).Sub(amount, actual).Cmp(maxFee) > 0 {
+		r.flag(base, dao.FindingAmountMismatch, record.Amount, sent,
+			"%s sent %s of %s with a gas fee of at most %s", name, sent, record.Amount, maxFee)
+	}
+	return nil
+}
+
+// chunk checks that the distributeRewards call of a chunk pays its recipients
+// and amounts, and that the value sent is the chunk total plus the tip
+func (r *reconciler) chunk(ctx context.Context, chunk *dao.DistributionChunk, tip *big.Int) error {
+	base := dao.ReconcileFinding{
+		RecordType:   dao.RecordChunk,
+		RecordID:     chunk.ID,
+		EndEpoch:     chunk.EndEpoch,
+		DelegateName: chunk.DelegateName,
+		Hash:         chunk.Hash,
+	}
+	name := fmt.Sprintf("chunk %d of delegate %s", chunk.ChunkIndex, chunk.DelegateName)
+	recipients, amounts, err := decodeChunk(chunk)
+	if err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		ioAddr, err := address.FromBytes(recipient.Bytes())
+		if err != nil {
+			return err
+		}
+		r.pay(base, ioAddr.String(), name)
+	}
+	core, err := r.action(ctx, base, name)
+	if err != nil || core == nil {
+		return err
+	}
+
+	hermes := config.Get().Contracts.Hermes.Address().String()
+	execution := core.GetExecution()
+	if execution == nil || execution.Contract != hermes || len(execution.Data) < 4 {
+		r.flag(base, dao.FindingRecipientMismatch, hermes, "", "action %s of %s is not a Hermes execution", chunk.Hash, name)
+		return nil
+	}
+	method, err := r.hermes.MethodById(execution.Data[:4])
+	if err != nil || method.Name != "distributeRewards" {
+		r.flag(base, dao.FindingRecipientMismatch, "distributeRewards", "", "action %s of %s doesn't call distributeRewards", chunk.Hash, name)
+		return nil
+	}
+	inputs, err := method.Inputs.Unpack(execution.Data[4:])
+	if err != nil {
+		r.flag(base, dao.FindingRecipientMismatch, "", "", "decode action %s of %s error: %v", chunk.Hash, name, err)
+		return nil
+	}
+	if inputs[0].([32]byte) != stringToBytes32(chunk.DelegateName) || inputs[1].(*big.Int).Uint64() != chunk.EndEpoch {
+		r.flag(base, dao.FindingRecipientMismatch, fmt.Sprint(chunk.EndEpoch), inputs[1].(*big.Int).String(),
+			"action %s of %s distributes another delegate or end epoch", chunk.Hash, name)
+	}
+	paidRecipients := inputs[2].([]common.Address)
+	paidAmounts := inputs[3].([]*big.Int)
+	if len(paidRecipients) != len(recipients) || len(paidAmounts) != len(amounts) {
+		r.flag(base, dao.FindingRecipientMismatch, fmt.Sprint(len(recipients)), fmt.Sprint(len(paidRecipients)),
+			"action %s of %s pays %d recipients instead of %d", chunk.Hash, name, len(paidRecipients), len(recipients))
+		return nil
+	}
+	for i := range recipients {
+		if paidRecipients[i] != recipients[i] {
+			r.flag(base, dao.FindingRecipientMismatch, recipients[i].Hex(), paidRecipients[i].Hex(),
+				"recipient %d of %s is %s instead of %s", i, name, paidRecipients[i].Hex(), recipients[i].Hex())
+		}
+		if paidAmounts[i].Cmp(amounts[i]) != 0 {
+			r.flag(base, dao.FindingAmountMismatch, amounts[i].String(), paidAmounts[i].String(),
+				"recipient %d of %s is paid %s instead of %s", i, name, paidAmounts[i], amounts[i])
+		}
+	}
+	total, ok := new(big.Int).SetString(chunk.Total, 10)
+	if ok && tip != nil {
+		expected := new(big.Int).Add(total, tip)
+		if execution.Amount != expected.String() {
+			r.flag(base, dao.FindingAmountMismatch, expected.String(), execution.Amount,
+				"%s sent %s instead of the total %s and tip %s", name, execution.Amount, chunk.Total, tip)
+		}
+	}
+	return nil
+}
+
+// small checks that a completed small record is paid in its sent epoch
+func (r *reconciler) small(record *dao.SmallRecord) {
+	if _, ok := r.payees[paymentKey(record.SentEpoch, record.DelegateName, record.Voter)]; ok {
+		return
+	}
+	r.flag(dao.ReconcileFinding{
+		RecordType:   dao.RecordSmall,
+		RecordID:     record.ID,
+		EndEpoch:     record.EndEpoch,
+		DelegateName: record.DelegateName,
+	}, dao.FindingMissing, fmt.Sprint(record.SentEpoch), "",
+		"small record %d of voter %s is not paid in epoch %d", record.ID, record.Voter, record.SentEpoch)
+}
+
+// PrintFindings writes the findings as a table
+func PrintFindings(w io.Writer, findings []*dao.ReconcileFinding) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tRECORD\tEND EPOCH\tDELEGATE\tHASH\tMESSAGE")
+	for _, f := range findings {
+		fmt.Fprintf(tw, "%s\t%s %d\t%d\t%s\t%s\t%s\n", f.Kind, f.RecordType, f.RecordID, f.EndEpoch, f.DelegateName, f.Hash, f.Message)
+	}
+	tw.Flush()
+}
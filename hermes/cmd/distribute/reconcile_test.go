@@ -0,0 +1,121 @@
+package distribute
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/iotexproject/iotex-address/address"
+	"github.com/iotexproject/iotex-antenna-go/v2/account"
+	"github.com/iotexproject/iotex-proto/golang/iotextypes"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+)
+
+func TestReconcile(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	fake, c := newFakeChain(t)
+	fake.SetBalance(c.Address(), new(big.Int).Mul(big.NewInt(1000), big.NewInt(1000000000000000000)))
+	voter, err := account.HexStringToAccount(testVoterPrivateKey)
+	require.NoError(err)
+	fake.SetBucket(&iotextypes.VoteBucket{Index: 7, Owner: voter.Address().String(), AutoStake: true})
+	fake.SetBucket(&iotextypes.VoteBucket{Index: 8, Owner: voter.Address().String(), AutoStake: false})
+
+	voterOf := func(i int64) string {
+		addr, err := address.FromBytes(common.BigToAddress(big.NewInt(i)).Bytes())
+		require.NoError(err)
+		return addr.String()
+	}
+	amount := big.NewInt(100000000000000000)
+	drop := func(id uint, index uint64, voter string) dao.DropRecord {
+		// a failed action still returns its hash
+		h, _, _, _, _ := addDepositOrTransfer(c, id, index, voter, "delegate", amount, nil)
+		record := dao.DropRecord{EndEpoch: 123, DelegateName: "delegate", Voter: voter, Index: index, Amount: amount.String(), Hash: hex.EncodeToString(h[:])}
+		record.ID = id
+		return record
+	}
+	transferred := drop(1, 8, voter.Address().String())
+	deposited := drop(2, 7, voterOf(2))
+	deposited.Index = 9
+	duplicated := drop(3, 8, voterOf(3))
+	duplicated.Hash = transferred.Hash
+	missing := drop(4, 8, voterOf(4))
+	missing.Hash = hex.EncodeToString(make([]byte, 32))
+	fake.FailNext(iotextypes.ReceiptStatus_ErrExecutionReverted)
+	failed := drop(5, 8, voterOf(5))
+	mismatched := drop(6, 8, voterOf(6))
+	mismatched.Amount = new(big.Int).Mul(amount, big.NewInt(2)).String()
+
+	minTips, err := getMinTips(c)
+	require.NoError(err)
+	p := &pipeline{c: c, endEpoch: big.NewInt(123), tip: minTips, inFlight: 2}
+	chunks := newTestChunks(t, "delegate", 2, 2)
+	for i, chunk := range chunks {
+		chunk.ID = uint(i + 1)
+		chunk.EndEpoch = 123
+	}
+	require.NoError(p.send(ctx, chunks))
+	var recipients []string
+	require.NoError(json.Unmarshal([]byte(chunks[0].Recipients), &recipients))
+	chunks[1].Amounts = `["2","3"]`
+
+	smalls := []dao.SmallRecord{
+		{EndEpoch: 120, SentEpoch: 123, DelegateName: "delegate", Voter: recipients[0], Status: "completed"},
+		{EndEpoch: 120, SentEpoch: 123, DelegateName: "delegate", Voter: voterOf(4), Status: "completed"},
+		{EndEpoch: 121, SentEpoch: 123, DelegateName: "other", Voter: recipients[0], Status: "completed"},
+	}
+	for i := range smalls {
+		smalls[i].ID = uint(i + 1)
+	}
+
+	r := &Reconciliation{
+		Drops:  []dao.DropRecord{transferred, deposited, duplicated, missing, failed, mismatched},
+		Smalls: smalls,
+		Chunks: chunks,
+		Tips:   map[uint64]*big.Int{123: minTips},
+	}
+	require.Equal(11, r.Records())
+	findings, err := Reconcile(ctx, c, r)
+	require.NoError(err)
+
+	type found struct {
+		recordType string
+		id         uint
+		kind       string
+	}
+	var got []found
+	for _, f := range findings {
+		got = append(got, found{f.RecordType, f.RecordID, f.Kind})
+	}
+	require.Equal([]found{
+		{dao.RecordDrop, 2, dao.FindingRecipientMismatch},
+		{dao.RecordDrop, 3, dao.FindingDuplicated},
+		{dao.RecordDrop, 4, dao.FindingMissing},
+		{dao.RecordDrop, 5, dao.FindingFailed},
+		{dao.RecordDrop, 6, dao.FindingAmountMismatch},
+		{dao.RecordChunk, 2, dao.FindingAmountMismatch},
+		{dao.RecordSmall, 3, dao.FindingMissing},
+	}, got)
+	require.Equal("3", findings[5].Expected)
+	require.Equal("2", findings[5].Actual)
+
+	// a chunk tampered with after it was sent no longer matches its action
+	chunks[0].Hash = chunks[1].Hash
+	r = &Reconciliation{Chunks: chunks[:1], Tips: r.Tips}
+	findings, err = Reconcile(ctx, c, r)
+	require.NoError(err)
+	require.Len(findings, 5)
+	require.Equal(dao.FindingRecipientMismatch, findings[0].Kind)
+	require.Equal(dao.FindingAmountMismatch, findings[4].Kind)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = Reconcile(cancelled, c, r)
+	require.Equal(context.Canceled, err)
+}
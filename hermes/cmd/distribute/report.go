@@ -0,0 +1,97 @@
+package distribute
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"text/tabwriter"
+)
+
+// Report lists what a distribution run pays, compounds and defers
+type Report struct {
+	EndEpoch  uint64            `json:"endEpoch"`
+	Tip       *big.Int          `json:"tip"`
+	Delegates []*DelegateReport `json:"delegates"`
+}
+
+// DelegateReport is the distribution of one delegate
+type DelegateReport struct {
+	DelegateName string         `json:"delegateName"`
+	Total        *big.Int       `json:"total"`
+	ServiceFee   *big.Int       `json:"serviceFee"`
+	Chunks       int            `json:"chunks"`
+	Paid         []*ReportEntry `json:"paid"`
+	Compounded   []*ReportEntry `json:"compounded"`
+	Deferred     []*ReportEntry `json:"deferred"`
+	Invalid      int            `json:"invalidSmallRecords"`
+}
+
+// ReportEntry is the amount of one voter
+type ReportEntry struct {
+	Voter  string   `json:"voter"`
+	Amount *big.Int `json:"amount"`
+	Merged *big.Int `json:"mergedSmall,omitempty"`
+	Fee    *big.Int `json:"fee,omitempty"`
+	Bucket uint64   `json:"bucket,omitempty"`
+}
+
+func (r *DelegateReport) addPaid(voter string, amount, merged, fee *big.Int) {
+	if r != nil {
+		r.Paid = append(r.Paid, &ReportEntry{Voter: voter, Amount: amount, Merged: merged, Fee: fee})
+	}
+}
+
+func (r *DelegateReport) addCompounded(voter string, amount, merged *big.Int, bucket uint64) {
+	if r != nil {
+		r.Compounded = append(r.Compounded, &ReportEntry{Voter: voter, Amount: amount, Merged: merged, Bucket: bucket})
+	}
+}
+
+func (r *DelegateReport) addDeferred(voter string, amount *big.Int) {
+	if r != nil {
+		r.Deferred = append(r.Deferred, &ReportEntry{Voter: voter, Amount: amount})
+	}
+}
+
+func (r *DelegateReport) addInvalid() {
+	if r != nil {
+		r.Invalid++
+	}
+}
+
+func sumEntries(entries []*ReportEntry) *big.Int {
+	total := big.NewInt(0)
+	for _, e := range entries {
+		total.Add(total, e.Amount)
+	}
+	return total
+}
+
+// Print writes a human readable summary of the report
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "Distribution End Epoch: %d, Tip per chunk: %s\n\n", r.EndEpoch, r.Tip.String())
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DELEGATE\tTOTAL\tSERVICE FEE\tPAID\tCOMPOUNDED\tDEFERRED\tCHUNKS\tINVALID")
+	paid, compounded, deferred := big.NewInt(0), big.NewInt(0), big.NewInt(0)
+	for _, d := range r.Delegates {
+		p, c, s := sumEntries(d.Paid), sumEntries(d.Compounded), sumEntries(d.Deferred)
+		paid.Add(paid, p)
+		compounded.Add(compounded, c)
+		deferred.Add(deferred, s)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s (%d)\t%s (%d)\t%s (%d)\t%d\t%d\n",
+			d.DelegateName, d.Total, d.ServiceFee, p, len(d.Paid), c, len(d.Compounded), s, len(d.Deferred), d.Chunks, d.Invalid)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\nTotal paid: %s, compounded: %s, deferred: %s\n", paid, compounded, deferred)
+}
+
+// Save writes the full report as JSON to path
+func (r *Report) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
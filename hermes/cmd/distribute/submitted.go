@@ -0,0 +1,157 @@
+package distribute
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/iotexproject/go-pkgs/hash"
+	"github.com/iotexproject/iotex-address/address"
+
+	"github.com/ququzone/hermes-patch/hermes/chain"
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+	"github.com/ququzone/hermes-patch/hermes/notify"
+)
+
+// submittedState is what the chain knows of the action of a submitted record
+type submittedState struct {
+	// Status is the status the record moves to, "submitted" while its action
+	// is known but not minted
+	Status      string
+	Amount      *big.Int
+	GasConsumed uint64
+	Err         error
+}
+
+// checkSubmitted looks up the action of a submitted record on chain. An action
+// without receipt can only be sent again once its nonce is used by another
+// action of the sender, until then the same action is broadcast again.
+func checkSubmitted(ctx context.Context, c chain.Client, record dao.DropRecord) (submittedState, error) {
+	data, err := hex.DecodeString(record.Hash)
+	if err != nil || len(data) != 32 {
+		return submittedState{}, fmt.Errorf("drop record %d has invalid hash %s", record.ID, record.Hash)
+	}
+	h := hash.BytesToHash256(data)
+	sender, err := address.FromString(record.Sender)
+	if err != nil {
+		return submittedState{}, fmt.Errorf("drop record %d has invalid sender %q", record.ID, record.Sender)
+	}
+	// the nonce is read before the receipt, an action minted by then has one
+	confirmed, err := c.ConfirmedNonce(ctx, sender)
+	if err != nil {
+		return submittedState{}, err
+	}
+	receipt, err := c.Receipt(ctx, h)
+	if err != nil && err != chain.ErrNotFound {
+		return submittedState{}, err
+	}
+	if err == chain.ErrNotFound {
+		if _, err := c.Action(ctx, h); err == nil {
+			return submittedState{Status: "submitted"}, nil
+		} else if err != chain.ErrNotFound {
+			return submittedState{}, err
+		}
+		if confirmed >= record.Nonce {
+			// another action took the nonce, this one can't be minted anymore
+			return submittedState{Status: "new"}, nil
+		}
+		if err := rebroadcast(ctx, c, record, h); err != nil {
+			return submittedState{}, err
+		}
+		return submittedState{Status: "submitted"}, nil
+	}
+
+	err = chain.ReceiptError(h, receipt)
+	switch {
+	case errors.Is(err, chain.ErrBucketInvalid):
+		// the bucket stopped auto staking, the record is sent again as a transfer
+		bucketStateMu.Lock()
+		delete(bucketStateMap, record.Index)
+		bucketStateMu.Unlock()
+		return submittedState{Status: "new", GasConsumed: receipt.GasConsumed}, nil
+	case err != nil:
+		return submittedState{Status: "error", GasConsumed: receipt.GasConsumed, Err: fmt.Errorf("add deposit staking failed: %w", err)}, nil
+	}
+	core, err := c.Action(ctx, h)
+	if err != nil {
+		return submittedState{}, fmt.Errorf("query action %x error: %v", h, err)
+	}
+	amount := core.GetTransfer().GetAmount()
+	if deposit := core.GetStakeAddDeposit(); deposit != nil {
+		amount = deposit.Amount
+	}
+	ra, ok := new(big.Int).SetString(amount, 10)
+	if !ok {
+		return submittedState{}, fmt.Errorf("action %x has invalid amount %s", h, amount)
+	}
+	return submittedState{Status: "completed", Amount: ra, GasConsumed: receipt.GasConsumed}, nil
+}
+
+// resolveSubmitted settles the submitted records against the chain, except
+// those pending in the queue. It runs before new records are fetched, so an
+// action broadcast by a previous run is never sent again.
+func resolveSubmitted(ctx context.Context, clients []chain.Client, notifier *notify.Alerter, pending func(uint) bool) {
+	records, err := dao.FindByStatus("submitted")
+	if err != nil {
+		log.Fatalf("query submitted drop records error: %v", err)
+	}
+	for _, record := range records {
+		if pending(record.ID) {
+			continue
+		}
+		if err := record.Verify(); err != nil {
+			if errors.Is(err, dao.ErrSignatureVersion) {
+				// left submitted for migrate-signatures
+				log.Printf("skip submitted drop record %d: %v\n", record.ID, err)
+				continue
+			}
+			invalidRecord(notifier, record)
+			continue
+		}
+		// the action is broadcast again by its sender when it is configured
+		c := clients[0]
+		for _, client := range clients {
+			if client.Address().String() == record.Sender {
+				c = client
+			}
+		}
+		state, err := checkSubmitted(ctx, c, record)
+		if err != nil {
+			log.Printf("check submitted drop record %d error: %v\n", record.ID, err)
+			continue
+		}
+		switch state.Status {
+		case "completed":
+			completeRecord(notifier, record, state.Amount, state.GasConsumed)
+		case "error":
+			failRecord(notifier, record, state.Err, state.GasConsumed)
+		case "new":
+			log.Printf("drop record %d action %s was replaced by nonce %d, it is sent again\n", record.ID, record.Hash, record.Nonce)
+			resetRecord(record)
+		}
+	}
+}
+
+// rebroadcast sends the recorded action of a submitted record again, its nonce
+// may still be free. A nonce taken meanwhile is left to the next check.
+func rebroadcast(ctx context.Context, c chain.Client, record dao.DropRecord, h hash.Hash256) error {
+	data, err := hex.DecodeString(record.SignedAction)
+	if err != nil || len(data) == 0 {
+		return fmt.Errorf("drop record %d has no action to broadcast again", record.ID)
+	}
+	signed, err := chain.ParseSignedAction(h, data)
+	if err != nil {
+		return err
+	}
+	if signed.Nonce() != record.Nonce {
+		return fmt.Errorf("drop record %d action has nonce %d instead of %d", record.ID, signed.Nonce(), record.Nonce)
+	}
+	if err := c.SendAction(ctx, signed); err != nil && !errors.Is(err, chain.ErrNonceConflict) {
+		return fmt.Errorf("broadcast action %x again error: %v", h, err)
+	}
+	log.Printf("drop record %d action %x was broadcast again\n", record.ID, h)
+	return nil
+}
@@ -0,0 +1,136 @@
+package distribute
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/iotexproject/iotex-antenna-go/v2/account"
+	"github.com/iotexproject/iotex-proto/golang/iotextypes"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ququzone/hermes-patch/hermes/chain"
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+)
+
+func TestCheckSubmitted(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	fake, c := newFakeChain(t)
+	voter, err := account.HexStringToAccount(testVoterPrivateKey)
+	require.NoError(err)
+	fake.SetBalance(c.Address(), big.NewInt(1000000000000000000))
+	fake.SetBucket(&iotextypes.VoteBucket{Index: 8, Owner: voter.Address().String(), AutoStake: false})
+	amount := big.NewInt(100000000000000000)
+
+	// send records a submitted record the way process does
+	send := func(id uint) (dao.DropRecord, error) {
+		record := dao.DropRecord{Index: 8, Voter: voter.Address().String()}
+		record.ID = id
+		_, _, _, _, err := addDepositOrTransfer(c, id, 8, record.Voter, "delegate", amount, func(signed *chain.SignedAction) {
+			data, err := signed.Bytes()
+			require.NoError(err)
+			record.Status = "submitted"
+			record.Hash = hex.EncodeToString(signed.Hash[:])
+			record.Nonce = signed.Nonce()
+			record.Sender = c.Address().String()
+			record.SignedAction = hex.EncodeToString(data)
+		})
+		return record, err
+	}
+
+	t.Run("record is submitted before its action is sent", func(t *testing.T) {
+		nonce := fake.Nonce(c.Address())
+		record, err := send(1)
+		require.NoError(err)
+		require.Equal("submitted", record.Status)
+		require.Equal(nonce+1, record.Nonce)
+
+		state, err := checkSubmitted(ctx, c, record)
+		require.NoError(err)
+		require.Equal("completed", state.Status)
+		require.Equal(chain.FakeActionGas, state.GasConsumed)
+		gas := new(big.Int).Mul(big.NewInt(chain.FakeGasPrice), new(big.Int).SetUint64(chain.FakeActionGas))
+		require.Equal(new(big.Int).Sub(amount, gas), state.Amount)
+	})
+
+	t.Run("action not minted yet stays submitted", func(t *testing.T) {
+		fake.DelayNext(-1)
+		record, err := send(2)
+		require.ErrorIs(err, chain.ErrNotFound)
+		require.Equal("submitted", record.Status)
+
+		nonce := fake.Nonce(c.Address())
+		state, err := checkSubmitted(ctx, c, record)
+		require.NoError(err)
+		require.Equal("submitted", state.Status)
+		require.Equal(nonce, fake.Nonce(c.Address()))
+	})
+
+	t.Run("failed action", func(t *testing.T) {
+		fake.FailNext(iotextypes.ReceiptStatus_ErrExecutionReverted)
+		record, err := send(3)
+		require.ErrorIs(err, chain.ErrReverted)
+
+		state, err := checkSubmitted(ctx, c, record)
+		require.NoError(err)
+		require.Equal("error", state.Status)
+		require.ErrorIs(state.Err, chain.ErrReverted)
+	})
+
+	// sign records a submitted record whose action is not broadcast
+	sign := func() dao.DropRecord {
+		signed, err := c.SignTransfer(ctx, voter.Address(), amount, chain.Opts{GasPrice: big.NewInt(chain.FakeGasPrice), GasLimit: chain.FakeActionGas})
+		require.NoError(err)
+		data, err := signed.Bytes()
+		require.NoError(err)
+		return dao.DropRecord{Status: "submitted", Hash: hex.EncodeToString(signed.Hash[:]), Nonce: signed.Nonce(),
+			Sender: c.Address().String(), SignedAction: hex.EncodeToString(data)}
+	}
+
+	t.Run("action never broadcast is broadcast again", func(t *testing.T) {
+		record := sign()
+		state, err := checkSubmitted(ctx, c, record)
+		require.NoError(err)
+		require.Equal("submitted", state.Status)
+		require.Equal(record.Nonce, fake.Nonce(c.Address()))
+
+		state, err = checkSubmitted(ctx, c, record)
+		require.NoError(err)
+		require.Equal("completed", state.Status)
+	})
+
+	t.Run("action replaced by another of the same nonce is sent again", func(t *testing.T) {
+		record := sign()
+		_, err := send(4)
+		require.NoError(err)
+		require.Equal(record.Nonce, fake.Nonce(c.Address()))
+
+		state, err := checkSubmitted(ctx, c, record)
+		require.NoError(err)
+		require.Equal("new", state.Status)
+	})
+
+	t.Run("action not stored", func(t *testing.T) {
+		record := sign()
+		record.SignedAction = ""
+		_, err := checkSubmitted(ctx, c, record)
+		require.ErrorContains(err, "no action to broadcast again")
+		require.Equal(record.Nonce-1, fake.Nonce(c.Address()))
+	})
+
+	t.Run("stored action of another nonce", func(t *testing.T) {
+		record := sign()
+		record.Nonce++
+		_, err := checkSubmitted(ctx, c, record)
+		require.ErrorContains(err, "instead of")
+		require.Equal(record.Nonce-2, fake.Nonce(c.Address()))
+	})
+
+	_, err = checkSubmitted(ctx, c, dao.DropRecord{Hash: hex.EncodeToString(make([]byte, 32))})
+	require.ErrorContains(err, "invalid sender")
+	_, err = checkSubmitted(ctx, c, dao.DropRecord{Hash: "zz"})
+	require.ErrorContains(err, "invalid hash")
+}
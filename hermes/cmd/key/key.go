@@ -4,8 +4,13 @@ import (
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
 )
 
 // Sign sign message with sha256
@@ -62,3 +67,87 @@ func LoadPublicKey(pem string) (*rsa.PublicKey, error) {
 	}
 	return pub.(*rsa.PublicKey), nil
 }
+
+// KeyID returns the ID of a public key, the hex of the first 8 bytes of the
+// sha256 of its PKIX encoding
+func KeyID(pub *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// Keyring signs with one current private key and verifies with any of its
+// public keys, picked by key ID
+type Keyring struct {
+	signer   *rsa.PrivateKey
+	signerID string
+	public   map[string]*rsa.PublicKey
+}
+
+// NewKeyring returns a keyring signing with priv, its public key is added
+func NewKeyring(priv *rsa.PrivateKey) (*Keyring, error) {
+	k := &Keyring{signer: priv, public: make(map[string]*rsa.PublicKey)}
+	id, err := k.Add(&priv.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	k.signerID = id
+	return k, nil
+}
+
+// Add adds a verification key and returns its ID
+func (k *Keyring) Add(pub *rsa.PublicKey) (string, error) {
+	id, err := KeyID(pub)
+	if err != nil {
+		return "", err
+	}
+	k.public[id] = pub
+	return id, nil
+}
+
+// CurrentID returns the ID of the signing key
+func (k *Keyring) CurrentID() string {
+	return k.signerID
+}
+
+// IDs returns the IDs of the verification keys
+func (k *Keyring) IDs() []string {
+	ids := make([]string, 0, len(k.public))
+	for id := range k.public {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Sign signs message with the current key and returns the signature and key ID
+func (k *Keyring) Sign(message string) (string, string, error) {
+	signature, err := Sign(message, k.signer)
+	if err != nil {
+		return "", "", err
+	}
+	return signature, k.signerID, nil
+}
+
+// Verify verifies sign with the public key of keyID
+func (k *Keyring) Verify(message, sign, keyID string) error {
+	pub, ok := k.public[keyID]
+	if !ok {
+		return fmt.Errorf("unknown key %q", keyID)
+	}
+	return Verify(message, sign, pub)
+}
+
+// VerifyAny verifies sign with every public key, for signatures made before
+// key IDs were recorded
+func (k *Keyring) VerifyAny(message, sign string) error {
+	for _, id := range k.IDs() {
+		if Verify(message, sign, k.public[id]) == nil {
+			return nil
+		}
+	}
+	return errors.New("no key verifies the signature")
+}
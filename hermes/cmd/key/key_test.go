@@ -0,0 +1,40 @@
+package key
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyring(t *testing.T) {
+	require := require.New(t)
+
+	old, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(err)
+	current, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(err)
+
+	oldRing, err := NewKeyring(old)
+	require.NoError(err)
+	signature, oldID, err := oldRing.Sign("message")
+	require.NoError(err)
+	require.Equal(oldRing.CurrentID(), oldID)
+
+	ring, err := NewKeyring(current)
+	require.NoError(err)
+	require.NotEqual(oldID, ring.CurrentID())
+	require.EqualError(ring.Verify("message", signature, oldID), `unknown key "`+oldID+`"`)
+	require.Error(ring.VerifyAny("message", signature))
+
+	id, err := ring.Add(&old.PublicKey)
+	require.NoError(err)
+	require.Equal(oldID, id)
+	require.Len(ring.IDs(), 2)
+	require.NoError(ring.Verify("message", signature, oldID))
+	require.NoError(ring.VerifyAny("message", signature))
+	// the key is picked by ID, another key doesn't verify it
+	require.Error(ring.Verify("message", signature, ring.CurrentID()))
+	require.Error(ring.Verify("other", signature, oldID))
+}
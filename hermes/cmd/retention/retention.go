@@ -0,0 +1,163 @@
+package retention
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+const (
+	// defaultKeepEpochs is how many end epochs are not archived by default
+	defaultKeepEpochs = 720
+	// batchSize is how many rows are archived, and exported to one file, at once
+	batchSize = 10000
+	// exportExt is the extension of export files
+	exportExt = ".ndjson.gz"
+)
+
+// Result is how many rows of a table were archived or restored
+type Result struct {
+	Table string
+	Rows  int
+}
+
+// Before returns the end epoch before which records are archived, 0 when
+// nothing is old enough
+func Before(cfg config.Retention) (uint64, error) {
+	last, err := dao.LastEndEpoch()
+	if err != nil {
+		return 0, fmt.Errorf("query last end epoch error: %v", err)
+	}
+	keep := cfg.KeepEpochs
+	if keep == 0 {
+		keep = defaultKeepEpochs
+	}
+	if last < keep {
+		return 0, nil
+	}
+	return last - keep + 1, nil
+}
+
+// Archive moves the completed and merged records ending before the end epoch
+// before to the archive tables. They are first exported when cfg.ExportDir is
+// set. With dryRun it only counts them.
+func Archive(cfg config.Retention, before uint64, dryRun bool) ([]Result, error) {
+	var results []Result
+	for _, t := range dao.RetainedTables {
+		result := Result{Table: t.Table}
+		if dryRun {
+			count, err := t.CountArchivable(before)
+			if err != nil {
+				return results, err
+			}
+			result.Rows = int(count)
+			results = append(results, result)
+			continue
+		}
+		var export func(first, last uint, rows interface{}) error
+		if cfg.ExportDir != "" {
+			export = func(first, last uint, rows interface{}) error {
+				return exportRows(cfg.ExportDir, t.Table, first, last, rows)
+			}
+		}
+		for {
+			n, err := t.Archive(before, batchSize, export, !cfg.DeleteExported || export == nil)
+			result.Rows += n
+			if err != nil {
+				return append(results, result), err
+			}
+			if n == 0 {
+				break
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// exportRows writes rows to a new file of dir named by table and the ID range,
+// one JSON row per line. The file is complete on disk when it returns.
+func exportRows(dir, table string, first, last uint, rows interface{}) error {
+	name := filepath.Join(dir, fmt.Sprintf("%s-%d-%d%s", table, first, last, exportExt))
+	f, err := os.CreateTemp(dir, filepath.Base(name)+".*")
+	if err != nil {
+		return fmt.Errorf("create export file error: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	encoder := json.NewEncoder(gz)
+	v := reflect.ValueOf(rows).Elem()
+	for i := 0; i < v.Len(); i++ {
+		if err := encoder.Encode(v.Index(i).Interface()); err != nil {
+			return fmt.Errorf("export %s row error: %v", table, err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("write export file error: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("sync export file error: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close export file error: %v", err)
+	}
+	if err := os.Rename(f.Name(), name); err != nil {
+		return fmt.Errorf("rename export file error: %v", err)
+	}
+	return nil
+}
+
+// RestoreFile inserts the rows of an export file back in their table, rows
+// that are already there or archived are skipped
+func RestoreFile(path string) (Result, error) {
+	base := filepath.Base(path)
+	if !strings.HasSuffix(base, exportExt) {
+		return Result{}, fmt.Errorf("%s is not an export file", path)
+	}
+	t, ok := dao.FindRetainedTable(strings.SplitN(base, "-", 2)[0])
+	if !ok {
+		return Result{}, fmt.Errorf("%s is not the export of a record table", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return Result{}, fmt.Errorf("open export file error: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return Result{}, fmt.Errorf("read export file %s error: %v", path, err)
+	}
+	defer gz.Close()
+	n, err := t.Import(gz)
+	return Result{Table: t.Table, Rows: n}, err
+}
+
+// RestoreEpochs moves the archived records of the end epochs in [from, to]
+// back to their tables
+func RestoreEpochs(from, to uint64) ([]Result, error) {
+	var results []Result
+	for _, t := range dao.RetainedTables {
+		result := Result{Table: t.Table}
+		for {
+			n, err := t.Unarchive(from, to, batchSize)
+			result.Rows += n
+			if err != nil {
+				return append(results, result), err
+			}
+			if n == 0 {
+				break
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
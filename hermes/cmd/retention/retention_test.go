@@ -0,0 +1,127 @@
+package retention
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ququzone/hermes-patch/hermes/cmd/dao"
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+func openTestDB(t *testing.T) {
+	require.NoError(t, dao.Open(config.DialectSQLite, filepath.Join(t.TempDir(), "hermes.db")))
+	t.Cleanup(func() { dao.DB().Close() })
+	_, err := dao.MigrateUp(0)
+	require.NoError(t, err)
+
+	for _, r := range []dao.DropRecord{
+		{EndEpoch: 10, Voter: "io1a", Amount: "1", Status: "completed", Index: 7},
+		{EndEpoch: 10, DelegateName: "a", Voter: "io1b", Amount: "2", Status: "merged-3"},
+		{EndEpoch: 10, DelegateName: "b", Voter: "io1b", Amount: "3", Status: "new"},
+		{EndEpoch: 10, Voter: "io1c", Amount: "4", Status: "error"},
+		{EndEpoch: 34, Voter: "io1a", Amount: "5", Status: "completed"},
+	} {
+		require.NoError(t, dao.DB().Create(&r).Error)
+	}
+	for _, r := range []dao.SmallRecord{
+		{EndEpoch: 10, Voter: "io1d", Amount: "6", Status: "completed"},
+		{EndEpoch: 10, Voter: "io1e", Amount: "7", Status: "pending"},
+	} {
+		require.NoError(t, dao.DB().Create(&r).Error)
+	}
+}
+
+func count(t *testing.T, table string) int {
+	var n int
+	require.NoError(t, dao.DB().Table(table).Count(&n).Error)
+	return n
+}
+
+func TestArchive(t *testing.T) {
+	require := require.New(t)
+	openTestDB(t)
+
+	before, err := Before(config.Retention{KeepEpochs: 24})
+	require.NoError(err)
+	require.Equal(uint64(11), before)
+	before, err = Before(config.Retention{})
+	require.NoError(err)
+	require.Zero(before)
+
+	cfg := config.Retention{KeepEpochs: 24}
+	results, err := Archive(cfg, 11, true)
+	require.NoError(err)
+	require.Equal([]Result{{"drop_records", 2}, {"small_records", 1}}, results)
+	require.Equal(5, count(t, "drop_records"))
+
+	results, err = Archive(cfg, 11, false)
+	require.NoError(err)
+	require.Equal([]Result{{"drop_records", 2}, {"small_records", 1}}, results)
+	require.Equal(3, count(t, "drop_records"))
+	require.Equal(2, count(t, "drop_record_archives"))
+	require.Equal(1, count(t, "small_record_baks"))
+
+	records, err := dao.FindCompletedDropRecords(0, 100)
+	require.NoError(err)
+	require.Len(records, 2)
+	require.Equal(uint64(7), records[1].Index)
+
+	results, err = RestoreEpochs(10, 10)
+	require.NoError(err)
+	require.Equal([]Result{{"drop_records", 2}, {"small_records", 1}}, results)
+	require.Equal(5, count(t, "drop_records"))
+	require.Zero(count(t, "drop_record_archives"))
+	require.Zero(count(t, "small_record_baks"))
+}
+
+func TestArchiveExport(t *testing.T) {
+	require := require.New(t)
+	openTestDB(t)
+
+	dir := t.TempDir()
+	cfg := config.Retention{ExportDir: dir, DeleteExported: true}
+	results, err := Archive(cfg, 11, false)
+	require.NoError(err)
+	require.Equal([]Result{{"drop_records", 2}, {"small_records", 1}}, results)
+	require.Zero(count(t, "drop_record_archives"))
+	require.Zero(count(t, "small_record_baks"))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(err)
+	require.Equal([]string{
+		filepath.Join(dir, "drop_records-1-2.ndjson.gz"),
+		filepath.Join(dir, "small_records-1-1.ndjson.gz"),
+	}, files)
+
+	for _, f := range files {
+		result, err := RestoreFile(f)
+		require.NoError(err)
+		require.NotZero(result.Rows)
+	}
+	require.Equal(5, count(t, "drop_records"))
+	require.Equal(2, count(t, "small_records"))
+	var record dao.DropRecord
+	require.NoError(dao.DB().First(&record, 1).Error)
+	require.Equal("completed", record.Status)
+	require.Equal(uint64(7), record.Index)
+	require.False(record.CreatedAt.IsZero())
+
+	// rows already restored are skipped
+	result, err := RestoreFile(files[0])
+	require.NoError(err)
+	require.Zero(result.Rows)
+
+	// rows kept in the archive tables are exported too
+	cfg.DeleteExported = false
+	require.NoError(os.Remove(files[0]))
+	_, err = Archive(cfg, 11, false)
+	require.NoError(err)
+	require.FileExists(files[0])
+	require.Equal(2, count(t, "drop_record_archives"))
+
+	_, err = RestoreFile(filepath.Join(dir, "reconcile_reports-1-1.ndjson.gz"))
+	require.ErrorContains(err, "not the export of a record table")
+}
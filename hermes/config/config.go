@@ -0,0 +1,443 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var errNotSet = errors.New("not set")
+
+var current *Config
+
+// Config is the typed configuration of hermes-patch. Every field can be set in
+// the YAML config file and overridden by the environment variable in its env tag.
+type Config struct {
+	Chain        Chain        `yaml:"chain"`
+	Database     Database     `yaml:"database"`
+	Notify       Notify       `yaml:"notify"`
+	Contracts    Contracts    `yaml:"contracts"`
+	Analytics    Analytics    `yaml:"analytics"`
+	Distribution Distribution `yaml:"distribution"`
+	Gas          Gas          `yaml:"gas"`
+	Vault        Vault        `yaml:"vault"`
+	Metrics      Metrics      `yaml:"metrics"`
+	Signer       Signer       `yaml:"signer"`
+	Retention    Retention    `yaml:"retention"`
+
+	envProblems Problems
+}
+
+// Chain is the IoTeX node connection
+type Chain struct {
+	Endpoint string `yaml:"endpoint" env:"IO_ENDPOINT"`
+	TLS      bool   `yaml:"tls" env:"RPC_TLS"`
+}
+
+// Database dialects
+const (
+	DialectMySQL    = "mysql"
+	DialectPostgres = "postgres"
+	DialectSQLite   = "sqlite3"
+)
+
+// Database is the record database and the RSA keys signing its rows.
+// RSAPrivate signs new signatures, RSAPublic and the comma separated
+// RSAPublicKeys verify them, so rotated keys can still verify older rows.
+type Database struct {
+	// Dialect is mysql, postgres or sqlite3, it defaults to mysql
+	Dialect       string `yaml:"dialect" env:"DB_DIALECT" optional:"true"`
+	Conn          string `yaml:"conn" env:"DB_CONN"`
+	RSAPrivate    string `yaml:"rsaPrivate" env:"RSA_PRIVATE"`
+	RSAPublic     string `yaml:"rsaPublic" env:"RSA_PUBLIC"`
+	RSAPublicKeys string `yaml:"rsaPublicKeys" env:"RSA_PUBLIC_KEYS" optional:"true"`
+}
+
+// Notify lists the notifier backends, every configured backend gets each
+// message and at least one must be configured
+type Notify struct {
+	Lark     Lark     `yaml:"lark"`
+	Slack    Slack    `yaml:"slack"`
+	Telegram Telegram `yaml:"telegram"`
+	Webhook  Webhook  `yaml:"webhook"`
+	SMTP     SMTP     `yaml:"smtp"`
+	// DedupeMinutes is how long identical errors are not repeated, it defaults to 30
+	DedupeMinutes int `yaml:"dedupeMinutes" env:"NOTIFY_DEDUPE_MINUTES" optional:"true"`
+	// DigestMinutes is how often progress digests are sent, it defaults to 60
+	DigestMinutes int `yaml:"digestMinutes" env:"NOTIFY_DIGEST_MINUTES" optional:"true"`
+}
+
+// Lark is the Lark signed webhook notifier
+type Lark struct {
+	Endpoint string `yaml:"endpoint" env:"LARK_ENDPOINT" optional:"true"`
+	Key      string `yaml:"key" env:"LARK_KEY" optional:"true"`
+}
+
+// Slack is a Slack incoming webhook
+type Slack struct {
+	WebhookURL string `yaml:"webhookURL" env:"SLACK_WEBHOOK_URL" optional:"true"`
+}
+
+// Telegram is a Telegram bot posting to a chat
+type Telegram struct {
+	Token  string `yaml:"token" env:"TELEGRAM_BOT_TOKEN" optional:"true"`
+	ChatID string `yaml:"chatID" env:"TELEGRAM_CHAT_ID" optional:"true"`
+}
+
+// Webhook is a generic JSON webhook, Token is sent as bearer token if set
+type Webhook struct {
+	URL   string `yaml:"url" env:"NOTIFY_WEBHOOK_URL" optional:"true"`
+	Token string `yaml:"token" env:"NOTIFY_WEBHOOK_TOKEN" optional:"true"`
+}
+
+// SMTP sends notifications by email, To is a comma separated address list
+type SMTP struct {
+	Addr     string `yaml:"addr" env:"SMTP_ADDR" optional:"true"`
+	Username string `yaml:"username" env:"SMTP_USERNAME" optional:"true"`
+	Password string `yaml:"password" env:"SMTP_PASSWORD" optional:"true"`
+	From     string `yaml:"from" env:"SMTP_FROM" optional:"true"`
+	To       string `yaml:"to" env:"SMTP_TO" optional:"true"`
+}
+
+// Contracts are the contract addresses used by the distribution
+type Contracts struct {
+	Hermes      Address `yaml:"hermes" env:"HERMES_CONTRACT_ADDRESS"`
+	Multisend   Address `yaml:"multisend" env:"MULTISEND_CONTRACT_ADDRESS"`
+	AutoDeposit Address `yaml:"autoDeposit" env:"AUTO_DEPOSIT_CONTRACT_ADDRESS"`
+}
+
+// Analytics is the bookkeeping GraphQL service
+type Analytics struct {
+	Endpoint string `yaml:"endpoint" env:"ANALYTICS_ENDPOINT"`
+	Token    string `yaml:"token" env:"ANALYTICS_TOKEN"`
+}
+
+// Distribution holds the reward distribution parameters
+type Distribution struct {
+	VaultAddresses Addresses `yaml:"vaultAddresses" env:"VAULT_ADDRESS"`
+	SenderAddress  Address   `yaml:"senderAddress" env:"SENDER_ADDR"`
+	ChunkSize      int       `yaml:"chunkSize" env:"CHUNK_SIZE"`
+	// ChunksInFlight is how many chunks are sent before waiting for receipts,
+	// it defaults to one
+	ChunksInFlight     int    `yaml:"chunksInFlight" env:"CHUNKS_IN_FLIGHT" optional:"true"`
+	ChargeFee          Amount `yaml:"chargeFee" env:"CHARGE_FEE"`
+	MinRewards         Amount `yaml:"minRewards" env:"MIN_REWARDS"`
+	BaseCharge         Amount `yaml:"baseCharge" env:"BASE_CHARGE"`
+	ChargePerRecipient Amount `yaml:"chargePerRecipient" env:"CHARGE_PER_RECIPIENT"`
+	// MinSenderBalance is the balance every sender account needs to start, it
+	// defaults to the fee of one action at gas.price and gas.limit
+	MinSenderBalance Amount `yaml:"minSenderBalance" env:"MIN_SENDER_BALANCE" optional:"true"`
+}
+
+// Gas holds the gas settings of sent actions. Gas limits are estimated by the
+// node per action, raised by Margin percent and capped by Limit. Gas prices are
+// suggested by the node, scaled by Multiplier percent and raised to Price.
+type Gas struct {
+	// Price is the lowest gas price sent
+	Price Amount `yaml:"price" env:"GAS_PRICE"`
+	// Ceiling is the highest gas price sent, nothing is sent above it
+	Ceiling Amount `yaml:"ceiling" env:"GAS_PRICE_CEILING" optional:"true"`
+	// Multiplier is the percentage applied to suggested prices, it defaults to 100
+	Multiplier int    `yaml:"multiplier" env:"GAS_PRICE_MULTIPLIER" optional:"true"`
+	Limit      uint64 `yaml:"limit" env:"GAS_LIMIT"`
+	// Margin is the percentage added to estimates, it defaults to 20
+	Margin int `yaml:"margin" env:"GAS_MARGIN" optional:"true"`
+}
+
+// Metrics is the Prometheus endpoint of the daemons
+type Metrics struct {
+	// Addr is the listen address of /metrics, it is not served when empty
+	Addr string `yaml:"addr" env:"METRICS_ADDR" optional:"true"`
+}
+
+// Signer is the remote transaction signer reached over HTTPS with a client
+// certificate, actions are signed with the local keystore when Endpoint is empty
+type Signer struct {
+	Endpoint   string `yaml:"endpoint" env:"SIGNER_ENDPOINT" optional:"true"`
+	CACert     string `yaml:"caCert" env:"SIGNER_CA_CERT" optional:"true"`
+	ClientCert string `yaml:"clientCert" env:"SIGNER_CLIENT_CERT" optional:"true"`
+	ClientKey  string `yaml:"clientKey" env:"SIGNER_CLIENT_KEY" optional:"true"`
+	// Address is the expected account of the signer, it is not checked when empty
+	Address Address `yaml:"address" env:"SIGNER_ADDRESS" optional:"true"`
+}
+
+// Retention sets how long completed and merged records stay in the live tables
+type Retention struct {
+	// KeepEpochs is how many end epochs, up to the last one, are not archived,
+	// it defaults to 720
+	KeepEpochs uint64 `yaml:"keepEpochs" env:"RETENTION_KEEP_EPOCHS" optional:"true"`
+	// ExportDir is where archived rows are exported as gzipped NDJSON files,
+	// nothing is exported when it is empty
+	ExportDir string `yaml:"exportDir" env:"RETENTION_EXPORT_DIR" optional:"true"`
+	// DeleteExported deletes the exported rows instead of keeping them in the
+	// archive tables
+	DeleteExported bool `yaml:"deleteExported" env:"RETENTION_DELETE_EXPORTED" optional:"true"`
+}
+
+// Vault is the legacy hermes vault account
+type Vault struct {
+	Password string `yaml:"password" env:"VAULT_PASSWORD" optional:"true"`
+}
+
+// Problem is a single invalid config field
+type Problem struct {
+	Field string
+	Env   string
+	Err   error
+}
+
+func (p Problem) Error() string {
+	return fmt.Sprintf("%s (%s): %v", p.Field, p.Env, p.Err)
+}
+
+// Problems is the list of every invalid config field
+type Problems []Problem
+
+func (p Problems) Error() string {
+	lines := make([]string, 0, len(p))
+	for _, v := range p {
+		lines = append(lines, v.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Load reads the config file at path, if any, and applies the env overrides
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open config file error: %v", err)
+		}
+		defer f.Close()
+		decoder := yaml.NewDecoder(f)
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("decode config file error: %v", err)
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "", func(v reflect.Value, field reflect.StructField, path string) {
+		env := field.Tag.Get("env")
+		s, ok := os.LookupEnv(env)
+		if env == "" || !ok {
+			return
+		}
+		if err := setValue(v, s); err != nil {
+			cfg.envProblems = append(cfg.envProblems, Problem{Field: path, Env: env, Err: err})
+		}
+	})
+	return cfg, nil
+}
+
+// Validate checks every field and returns Problems listing all invalid ones
+func (c *Config) Validate() error {
+	problems := append(Problems(nil), c.envProblems...)
+	walk(reflect.ValueOf(c).Elem(), "", func(v reflect.Value, field reflect.StructField, path string) {
+		err := checkValue(v)
+		if err == errNotSet && field.Tag.Get("optional") == "true" {
+			return
+		}
+		if err != nil {
+			problems = append(problems, Problem{Field: path, Env: field.Tag.Get("env"), Err: err})
+		}
+	})
+	// a zero chunk size is reported as not set
+	if c.Distribution.ChunkSize < 0 {
+		problems = append(problems, Problem{Field: "distribution.chunkSize", Env: "CHUNK_SIZE", Err: errors.New("must be positive")})
+	}
+	if c.Distribution.ChunksInFlight < 0 {
+		problems = append(problems, Problem{Field: "distribution.chunksInFlight", Env: "CHUNKS_IN_FLIGHT", Err: errors.New("must not be negative")})
+	}
+	switch c.Database.Dialect {
+	case "", DialectMySQL, DialectPostgres, DialectSQLite:
+	default:
+		problems = append(problems, Problem{Field: "database.dialect", Env: "DB_DIALECT", Err: errors.New("must be mysql, postgres or sqlite3")})
+	}
+	if c.Retention.DeleteExported && c.Retention.ExportDir == "" {
+		problems = append(problems, Problem{Field: "retention.deleteExported", Env: "RETENTION_DELETE_EXPORTED", Err: errors.New("requires retention.exportDir")})
+	}
+	problems = append(problems, c.Notify.problems()...)
+	problems = append(problems, c.Signer.problems()...)
+	if c.Gas.Multiplier < 0 {
+		problems = append(problems, Problem{Field: "gas.multiplier", Env: "GAS_PRICE_MULTIPLIER", Err: errors.New("must not be negative")})
+	}
+	if ceiling, floor := c.Gas.Ceiling.Int(), c.Gas.Price.Int(); ceiling != nil && floor != nil && ceiling.Cmp(floor) < 0 {
+		problems = append(problems, Problem{Field: "gas.ceiling", Env: "GAS_PRICE_CEILING", Err: errors.New("must not be below gas.price")})
+	}
+	if c.Gas.Margin < 0 {
+		problems = append(problems, Problem{Field: "gas.margin", Env: "GAS_MARGIN", Err: errors.New("must not be negative")})
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// Init loads and validates the config, and makes it the current one
+func Init(path string) error {
+	cfg, err := Load(path)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config:\n%v", err)
+	}
+	current = cfg
+	return nil
+}
+
+// Set makes cfg the current config
+func Set(cfg *Config) {
+	current = cfg
+}
+
+// Get returns the current config
+func Get() *Config {
+	if current == nil {
+		panic("config is not initialized")
+	}
+	return current
+}
+
+type checker interface {
+	check() error
+}
+
+type setter interface {
+	Set(string) error
+}
+
+func walk(v reflect.Value, prefix string, fn func(reflect.Value, reflect.StructField, string)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		fv := v.Field(i)
+		if _, ok := fv.Addr().Interface().(checker); !ok && fv.Kind() == reflect.Struct {
+			walk(fv, name, fn)
+			continue
+		}
+		fn(fv, field, name)
+	}
+}
+
+func setValue(v reflect.Value, s string) error {
+	if st, ok := v.Addr().Interface().(setter); ok {
+		// invalid values are kept and reported by Validate
+		st.Set(s)
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid bool %q", s)
+		}
+		v.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		v.SetInt(n)
+	case reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", s)
+		}
+		v.SetUint(n)
+	default:
+		return fmt.Errorf("unsupported config type %s", v.Type())
+	}
+	return nil
+}
+
+func checkValue(v reflect.Value) error {
+	if c, ok := v.Addr().Interface().(checker); ok {
+		return c.check()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		if v.String() == "" {
+			return errNotSet
+		}
+	case reflect.Int, reflect.Uint64:
+		if v.IsZero() {
+			return errNotSet
+		}
+	}
+	return nil
+}
+
+func (n Notify) problems() (problems []Problem) {
+	backends := 0
+	pair := func(field, env string, set bool, other string) {
+		if !set {
+			problems = append(problems, Problem{Field: field, Env: env, Err: fmt.Errorf("required with %s", other)})
+		}
+	}
+	if n.Lark.Endpoint != "" {
+		backends++
+		pair("notify.lark.key", "LARK_KEY", n.Lark.Key != "", "notify.lark.endpoint")
+	}
+	if n.Slack.WebhookURL != "" {
+		backends++
+	}
+	if n.Telegram.Token != "" {
+		backends++
+		pair("notify.telegram.chatID", "TELEGRAM_CHAT_ID", n.Telegram.ChatID != "", "notify.telegram.token")
+	}
+	if n.Webhook.URL != "" {
+		backends++
+	}
+	if n.SMTP.Addr != "" {
+		backends++
+		pair("notify.smtp.from", "SMTP_FROM", n.SMTP.From != "", "notify.smtp.addr")
+		pair("notify.smtp.to", "SMTP_TO", n.SMTP.To != "", "notify.smtp.addr")
+	}
+	if n.DedupeMinutes < 0 {
+		problems = append(problems, Problem{Field: "notify.dedupeMinutes", Env: "NOTIFY_DEDUPE_MINUTES", Err: errors.New("must be positive")})
+	}
+	if n.DigestMinutes < 0 {
+		problems = append(problems, Problem{Field: "notify.digestMinutes", Env: "NOTIFY_DIGEST_MINUTES", Err: errors.New("must be positive")})
+	}
+	if backends == 0 {
+		problems = append(problems, Problem{Field: "notify", Env: "LARK_ENDPOINT", Err: errors.New("no notifier is configured")})
+	}
+	return problems
+}
+
+func (s Signer) problems() (problems []Problem) {
+	if s.Endpoint == "" {
+		return nil
+	}
+	if !strings.HasPrefix(s.Endpoint, "https://") {
+		problems = append(problems, Problem{Field: "signer.endpoint", Env: "SIGNER_ENDPOINT", Err: errors.New("must be an https URL")})
+	}
+	for _, f := range []struct {
+		field, env string
+		set        bool
+	}{
+		{"signer.caCert", "SIGNER_CA_CERT", s.CACert != ""},
+		{"signer.clientCert", "SIGNER_CLIENT_CERT", s.ClientCert != ""},
+		{"signer.clientKey", "SIGNER_CLIENT_KEY", s.ClientKey != ""},
+	} {
+		if !f.set {
+			problems = append(problems, Problem{Field: f.field, Env: f.env, Err: errors.New("required with signer.endpoint")})
+		}
+	}
+	return problems
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `
+chain:
+  endpoint: api.iotex.one:443
+  tls: true
+database:
+  conn: user:pass@tcp(127.0.0.1:3306)/hermes
+  rsaPrivate: private
+  rsaPublic: public
+notify:
+  lark:
+    endpoint: https://open.larksuite.com/hook
+    key: secret
+contracts:
+  hermes: io1lvemm43lz6np0hzcqlpk0kpxxww623z5hs4mwu
+  multisend: io1lvemm43lz6np0hzcqlpk0kpxxww623z5hs4mwu
+  autoDeposit: io1lvemm43lz6np0hzcqlpk0kpxxww623z5hs4mwu
+analytics:
+  endpoint: https://analytics.iotexscan.io/query
+  token: token
+distribution:
+  vaultAddresses:
+    - io1lvemm43lz6np0hzcqlpk0kpxxww623z5hs4mwu
+  senderAddress: io1lvemm43lz6np0hzcqlpk0kpxxww623z5hs4mwu
+  chunkSize: 100
+  chargeFee: 1000000000000000000
+  minRewards: "5000000000000000000"
+  baseCharge: 0
+  chargePerRecipient: 0
+gas:
+  price: 1000000000000
+  limit: 5000000
+`
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	require := require.New(t)
+
+	cfg, err := Load(writeConfig(t, testConfig))
+	require.NoError(err)
+	require.NoError(cfg.Validate())
+	require.True(cfg.Chain.TLS)
+	require.Equal(100, cfg.Distribution.ChunkSize)
+	require.Equal("1000000000000000000", cfg.Distribution.ChargeFee.Int().String())
+	require.Equal("5000000000000000000", cfg.Distribution.MinRewards.Int().String())
+	require.Equal("io1lvemm43lz6np0hzcqlpk0kpxxww623z5hs4mwu", cfg.Contracts.Hermes.Address().String())
+	require.Len(cfg.Distribution.VaultAddresses.Strings(), 1)
+
+	// returned amounts are copies
+	cfg.Distribution.ChargeFee.Int().SetInt64(1)
+	require.Equal("1000000000000000000", cfg.Distribution.ChargeFee.Int().String())
+}
+
+func TestEnvOverride(t *testing.T) {
+	require := require.New(t)
+
+	t.Setenv("CHUNK_SIZE", "50")
+	t.Setenv("RPC_TLS", "false")
+	t.Setenv("VAULT_ADDRESS", "io1lvemm43lz6np0hzcqlpk0kpxxww623z5hs4mwu,io1lvemm43lz6np0hzcqlpk0kpxxww623z5hs4mwu")
+	cfg, err := Load(writeConfig(t, testConfig))
+	require.NoError(err)
+	require.NoError(cfg.Validate())
+	require.False(cfg.Chain.TLS)
+	require.Equal(50, cfg.Distribution.ChunkSize)
+	require.Len(cfg.Distribution.VaultAddresses.Strings(), 2)
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	require := require.New(t)
+
+	t.Setenv("CHUNK_SIZE", "abc")
+	t.Setenv("CHARGE_FEE", "-1")
+	t.Setenv("HERMES_CONTRACT_ADDRESS", "io1invalid")
+	t.Setenv("DB_CONN", "")
+	t.Setenv("DB_DIALECT", "oracle")
+	t.Setenv("RETENTION_DELETE_EXPORTED", "true")
+	cfg, err := Load(writeConfig(t, testConfig))
+	require.NoError(err)
+
+	err = cfg.Validate()
+	require.Error(err)
+	problems, ok := err.(Problems)
+	require.True(ok)
+	fields := make([]string, 0, len(problems))
+	for _, p := range problems {
+		fields = append(fields, p.Field)
+	}
+	require.ElementsMatch([]string{
+		"distribution.chunkSize",
+		"distribution.chargeFee",
+		"contracts.hermes",
+		"database.conn",
+		"database.dialect",
+		"retention.deleteExported",
+	}, fields)
+}
+
+func TestValidateNegative(t *testing.T) {
+	require := require.New(t)
+
+	// zero percentages take their default
+	t.Setenv("GAS_PRICE_MULTIPLIER", "0")
+	cfg, err := Load(writeConfig(t, testConfig))
+	require.NoError(err)
+	require.NoError(cfg.Validate())
+
+	t.Setenv("GAS_PRICE_MULTIPLIER", "-1")
+	cfg, err = Load(writeConfig(t, testConfig))
+	require.NoError(err)
+	require.EqualError(cfg.Validate(), "gas.multiplier (GAS_PRICE_MULTIPLIER): must not be negative")
+}
+
+func TestLoadUnknownField(t *testing.T) {
+	_, err := Load(writeConfig(t, "chain:\n  endpiont: localhost:14014\n"))
+	require.Error(t, err)
+}
+
+func TestValidateNotify(t *testing.T) {
+	require := require.New(t)
+
+	t.Setenv("LARK_ENDPOINT", "")
+	cfg, err := Load(writeConfig(t, testConfig))
+	require.NoError(err)
+	require.EqualError(cfg.Validate(), "notify (LARK_ENDPOINT): no notifier is configured")
+
+	t.Setenv("TELEGRAM_BOT_TOKEN", "token")
+	t.Setenv("SLACK_WEBHOOK_URL", "https://hooks.slack.com/services/T/B/X")
+	cfg, err = Load(writeConfig(t, testConfig))
+	require.NoError(err)
+	require.EqualError(cfg.Validate(), "notify.telegram.chatID (TELEGRAM_CHAT_ID): required with notify.telegram.token")
+}
+
+func TestValidateSigner(t *testing.T) {
+	require := require.New(t)
+
+	t.Setenv("SIGNER_ENDPOINT", "http://127.0.0.1:8443")
+	t.Setenv("SIGNER_CA_CERT", "ca.pem")
+	t.Setenv("SIGNER_CLIENT_CERT", "client.pem")
+	cfg, err := Load(writeConfig(t, testConfig))
+	require.NoError(err)
+	require.EqualError(cfg.Validate(), "signer.endpoint (SIGNER_ENDPOINT): must be an https URL\n"+
+		"signer.clientKey (SIGNER_CLIENT_KEY): required with signer.endpoint")
+
+	t.Setenv("SIGNER_ENDPOINT", "https://127.0.0.1:8443")
+	t.Setenv("SIGNER_CLIENT_KEY", "client-key.pem")
+	cfg, err = Load(writeConfig(t, testConfig))
+	require.NoError(err)
+	require.NoError(cfg.Validate())
+}
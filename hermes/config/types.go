@@ -0,0 +1,153 @@
+package config
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/iotexproject/iotex-address/address"
+	"gopkg.in/yaml.v3"
+)
+
+// Amount is a non-negative integer amount in Rau
+type Amount struct {
+	raw   string
+	value *big.Int
+}
+
+// NewAmount returns an amount with the given value
+func NewAmount(v *big.Int) Amount {
+	return Amount{raw: v.String(), value: new(big.Int).Set(v)}
+}
+
+// Set parses s as a decimal amount
+func (a *Amount) Set(s string) error {
+	a.raw = strings.TrimSpace(s)
+	a.value = nil
+	return a.check()
+}
+
+// Int returns a copy of the amount, nil if it is not set or invalid
+func (a Amount) Int() *big.Int {
+	if a.value == nil {
+		return nil
+	}
+	return new(big.Int).Set(a.value)
+}
+
+func (a Amount) String() string {
+	return a.raw
+}
+
+// UnmarshalYAML accepts both YAML numbers and strings
+func (a *Amount) UnmarshalYAML(node *yaml.Node) error {
+	a.Set(node.Value)
+	return nil
+}
+
+func (a *Amount) check() error {
+	if a.raw == "" {
+		return errNotSet
+	}
+	v, ok := new(big.Int).SetString(a.raw, 10)
+	if !ok {
+		return fmt.Errorf("invalid amount %q", a.raw)
+	}
+	if v.Sign() < 0 {
+		return fmt.Errorf("negative amount %q", a.raw)
+	}
+	a.value = v
+	return nil
+}
+
+// Address is an IoTeX address
+type Address struct {
+	raw   string
+	value address.Address
+}
+
+// Set parses s as an io address
+func (a *Address) Set(s string) error {
+	a.raw = strings.TrimSpace(s)
+	a.value = nil
+	return a.check()
+}
+
+// Address returns the parsed address, nil if it is not set or invalid
+func (a Address) Address() address.Address {
+	return a.value
+}
+
+func (a Address) String() string {
+	return a.raw
+}
+
+// UnmarshalYAML reads the address from a YAML string
+func (a *Address) UnmarshalYAML(node *yaml.Node) error {
+	a.Set(node.Value)
+	return nil
+}
+
+func (a *Address) check() error {
+	if a.raw == "" {
+		return errNotSet
+	}
+	v, err := address.FromString(a.raw)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %v", a.raw, err)
+	}
+	a.value = v
+	return nil
+}
+
+// Addresses is a list of IoTeX addresses, comma separated in env
+type Addresses struct {
+	raw    []string
+	values []address.Address
+}
+
+// Set parses s as a comma separated list of io addresses
+func (a *Addresses) Set(s string) error {
+	a.raw = nil
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			a.raw = append(a.raw, v)
+		}
+	}
+	return a.check()
+}
+
+// Strings returns the addresses in string form
+func (a Addresses) Strings() []string {
+	return append([]string(nil), a.raw...)
+}
+
+// UnmarshalYAML accepts a YAML list or a comma separated string
+func (a *Addresses) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.SequenceNode {
+		a.Set(node.Value)
+		return nil
+	}
+	values := make([]string, 0, len(node.Content))
+	for _, n := range node.Content {
+		values = append(values, n.Value)
+	}
+	a.Set(strings.Join(values, ","))
+	return nil
+}
+
+func (a *Addresses) check() error {
+	if len(a.raw) == 0 {
+		return errNotSet
+	}
+	a.values = make([]address.Address, 0, len(a.raw))
+	for _, v := range a.raw {
+		addr, err := address.FromString(v)
+		if err != nil {
+			a.values = nil
+			return fmt.Errorf("invalid address %q: %v", v, err)
+		}
+		a.values = append(a.values, addr)
+	}
+	return nil
+}
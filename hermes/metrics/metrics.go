@@ -0,0 +1,102 @@
+// Package metrics exposes the Prometheus metrics of the hermes daemons
+package metrics
+
+import (
+	"context"
+	"log"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// Records counts processed drop records by their final status
+	Records = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hermes_drop_records_total",
+		Help: "Drop records processed by the sender, by status.",
+	}, []string{"status"})
+	// Paid is the IOTX distributed by completed distributeRewards chunks
+	Paid = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "hermes_paid_iotx_total",
+		Help: "IOTX paid to voters by completed distribution chunks.",
+	})
+	// Compounded is the IOTX deposited to buckets or transferred by the sender
+	Compounded = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "hermes_compounded_iotx_total",
+		Help: "IOTX deposited or transferred to voters by the sender.",
+	})
+	// ChunkLatency is the time from sending a chunk to its receipt
+	ChunkLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "hermes_chunk_latency_seconds",
+		Help:    "Time from sending a distribution chunk to its receipt.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+	})
+	// ReceiptWait is the time spent polling for receipts
+	ReceiptWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "hermes_receipt_wait_seconds",
+		Help:    "Time spent waiting for action receipts.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+	})
+	// GRPCErrors counts failed node calls by gRPC code
+	GRPCErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "hermes_grpc_errors_total",
+		Help: "Failed IoTeX node calls, by method and gRPC code.",
+	}, []string{"method", "code"})
+	// Balance is the balance of the sending accounts
+	Balance = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "hermes_account_balance_iotx",
+		Help: "Balance of the sending accounts in IOTX.",
+	}, []string{"account"})
+	// QueueDepth is the number of drop records waiting for the sender
+	QueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "hermes_new_drop_records",
+		Help: "Drop records in status new.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(Records, Paid, Compounded, ChunkLatency, ReceiptWait, GRPCErrors, Balance, QueueDepth)
+}
+
+var oneIOTX = new(big.Float).SetInt(big.NewInt(1000000000000000000))
+
+// IOTX converts an amount in Rau to IOTX
+func IOTX(amount *big.Int) float64 {
+	if amount == nil {
+		return 0
+	}
+	v, _ := new(big.Float).Quo(new(big.Float).SetInt(amount), oneIOTX).Float64()
+	return v
+}
+
+// Since observes the seconds elapsed from start
+func Since(o prometheus.Observer, start time.Time) {
+	o.Observe(time.Since(start).Seconds())
+}
+
+// UnaryClientInterceptor counts the failed calls of a gRPC connection
+func UnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err != nil {
+		GRPCErrors.WithLabelValues(method, status.Code(err).String()).Inc()
+	}
+	return err
+}
+
+// Serve serves /metrics on addr in the background
+func Serve(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("serve metrics error: %v\n", err)
+		}
+	}()
+	return server
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIOTX(t *testing.T) {
+	require := require.New(t)
+
+	amount, _ := new(big.Int).SetString("1500000000000000000", 10)
+	require.Equal(1.5, IOTX(amount))
+	require.Equal(0.0, IOTX(nil))
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	require := require.New(t)
+
+	method := "/iotexapi.APIService/GetAccount"
+	failed := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.Unavailable, "node is down")
+	}
+	succeeded := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	require.Error(UnaryClientInterceptor(context.Background(), method, nil, nil, nil, failed))
+	require.NoError(UnaryClientInterceptor(context.Background(), method, nil, nil, nil, succeeded))
+	require.Equal(1.0, testutil.ToFloat64(GRPCErrors.WithLabelValues(method, "Unavailable")))
+}
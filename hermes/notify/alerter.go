@@ -0,0 +1,206 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a notification
+type Level int
+
+// Notification levels. Info is rolled into the digest, Error is sent once per
+// window for identical messages and Critical is always sent at once.
+const (
+	Info Level = iota
+	Error
+	Critical
+)
+
+func (l Level) String() string {
+	switch l {
+	case Info:
+		return "INFO"
+	case Error:
+		return "ERROR"
+	case Critical:
+		return "CRITICAL"
+	}
+	return fmt.Sprintf("LEVEL%d", int(l))
+}
+
+// maxDigestLines bounds the progress lines kept for one digest
+const maxDigestLines = 50
+
+// Alerter sends notifications by severity. Identical errors are sent once per
+// window, the repeats are counted in the digest along with the progress.
+// Methods of a nil Alerter do nothing.
+type Alerter struct {
+	n      Notifier
+	window time.Duration
+	now    func() time.Time
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time
+	since    time.Time
+	sent     int
+	total    *big.Int
+	failures map[string]int
+	progress []string
+	dropped  int
+}
+
+// NewAlerter returns an alerter sending to n, identical errors are sent once per window
+func NewAlerter(n Notifier, window time.Duration) *Alerter {
+	a := &Alerter{
+		n:        n,
+		window:   window,
+		now:      time.Now,
+		lastSent: make(map[string]time.Time),
+	}
+	a.reset()
+	return a
+}
+
+func (a *Alerter) reset() {
+	a.since = a.now()
+	a.sent = 0
+	a.total = big.NewInt(0)
+	a.failures = make(map[string]int)
+	a.progress = nil
+	a.dropped = 0
+}
+
+var variable = regexp.MustCompile(`0x[0-9a-fA-F]+|[0-9a-fA-F]{16,}|\d+`)
+
+// reason returns message without the hashes and numbers that vary between
+// otherwise identical errors
+func reason(message string) string {
+	return variable.ReplaceAllString(message, "#")
+}
+
+// Notify sends message according to level
+func (a *Alerter) Notify(level Level, message string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	switch level {
+	case Info:
+		if len(a.progress) < maxDigestLines {
+			a.progress = append(a.progress, message)
+		} else {
+			a.dropped++
+		}
+		a.mu.Unlock()
+		return
+	case Error:
+		key := reason(message)
+		a.failures[key]++
+		now := a.now()
+		if last, ok := a.lastSent[key]; ok && now.Sub(last) < a.window {
+			a.mu.Unlock()
+			return
+		}
+		a.lastSent[key] = now
+	}
+	a.mu.Unlock()
+	a.n.SendMessage(fmt.Sprintf("[%s] %s", level, message))
+}
+
+// Infof rolls a progress message into the digest
+func (a *Alerter) Infof(format string, args ...interface{}) {
+	a.Notify(Info, fmt.Sprintf(format, args...))
+}
+
+// Errorf sends an error unless an identical one was sent within the window
+func (a *Alerter) Errorf(format string, args ...interface{}) {
+	a.Notify(Error, fmt.Sprintf(format, args...))
+}
+
+// Criticalf sends a message at once
+func (a *Alerter) Criticalf(format string, args ...interface{}) {
+	a.Notify(Critical, fmt.Sprintf(format, args...))
+}
+
+// Sent counts records sent for the digest
+func (a *Alerter) Sent(records int, amount *big.Int) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sent += records
+	if amount != nil {
+		a.total.Add(a.total, amount)
+	}
+}
+
+// Flush sends the digest of everything since the last one, nothing is sent
+// if nothing happened
+func (a *Alerter) Flush() {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	message := a.digest()
+	a.reset()
+	a.mu.Unlock()
+	if message != "" {
+		a.n.SendMessage(message)
+	}
+}
+
+func (a *Alerter) digest() string {
+	if a.sent == 0 && len(a.failures) == 0 && len(a.progress) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "[DIGEST] since %s\n", a.since.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "records sent: %d, total: %s\n", a.sent, a.total.String())
+	if len(a.failures) > 0 {
+		reasons := make([]string, 0, len(a.failures))
+		for key := range a.failures {
+			reasons = append(reasons, key)
+		}
+		sort.Slice(reasons, func(i, j int) bool {
+			if a.failures[reasons[i]] != a.failures[reasons[j]] {
+				return a.failures[reasons[i]] > a.failures[reasons[j]]
+			}
+			return reasons[i] < reasons[j]
+		})
+		b.WriteString("failures:\n")
+		for _, key := range reasons {
+			fmt.Fprintf(&b, "  %d x %s\n", a.failures[key], key)
+		}
+	}
+	if len(a.progress) > 0 {
+		b.WriteString("progress:\n")
+		for _, line := range a.progress {
+			fmt.Fprintf(&b, "  %s\n", line)
+		}
+		if a.dropped > 0 {
+			fmt.Fprintf(&b, "  and %d more\n", a.dropped)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// Run sends a digest every interval until ctx is done
+func (a *Alerter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.Flush()
+		}
+	}
+}
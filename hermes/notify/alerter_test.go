@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type collector struct {
+	messages []string
+}
+
+func (c *collector) SendMessage(message string) error {
+	c.messages = append(c.messages, message)
+	return nil
+}
+
+func TestAlerter(t *testing.T) {
+	require := require.New(t)
+
+	c := &collector{}
+	a := NewAlerter(c, time.Hour)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+	a.reset()
+
+	// progress waits for the digest, criticals are sent at once
+	a.Infof("Begin send %d compound hermes rewards", 3)
+	a.Criticalf("Deposit %d error: insufficient funds", 1)
+	require.Equal([]string{"[CRITICAL] Deposit 1 error: insufficient funds"}, c.messages)
+
+	// identical errors are sent once per window
+	a.Errorf("Deposit %d error: add deposit staking failed: %x", 2, []byte{1, 2, 3, 4, 5, 6, 7, 8})
+	a.Errorf("Deposit %d error: add deposit staking failed: %x", 3, []byte{8, 7, 6, 5, 4, 3, 2, 1})
+	a.Errorf("Send rewards error %v", "timeout")
+	require.Len(c.messages, 3)
+	now = now.Add(2 * time.Hour)
+	a.Errorf("Deposit %d error: add deposit staking failed: %x", 4, []byte{1, 1, 1, 1, 1, 1, 1, 1})
+	require.Len(c.messages, 4)
+
+	a.Sent(2, big.NewInt(300))
+	a.Flush()
+	require.Len(c.messages, 5)
+	require.Equal(`[DIGEST] since 2024-01-01T00:00:00Z
+records sent: 2, total: 300
+failures:
+  3 x Deposit # error: add deposit staking failed: #
+  1 x Send rewards error timeout
+progress:
+  Begin send 3 compound hermes rewards`, c.messages[4])
+
+	// nothing happened since the last digest
+	a.Flush()
+	require.Len(c.messages, 5)
+
+	var nilAlerter *Alerter
+	nilAlerter.Criticalf("ignored")
+	nilAlerter.Flush()
+}
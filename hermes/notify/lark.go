@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+type larkText struct {
+	Text string `json:"text"`
+}
+
+type larkMessage struct {
+	Timestamp string   `json:"timestamp"`
+	Sign      string   `json:"sign"`
+	MsgType   string   `json:"msg_type"`
+	Content   larkText `json:"content"`
+}
+
+// Lark posts to a Lark custom bot with signature verification
+type Lark struct {
+	Endpoint string
+	Key      string
+}
+
+// NewLark returns a Lark notifier signing messages with key
+func NewLark(endpoint, key string) *Lark {
+	return &Lark{
+		Endpoint: endpoint,
+		Key:      key,
+	}
+}
+
+// GenSign returns the Lark signature of timestamp
+func GenSign(secret string, timestamp int64) (string, error) {
+	stringToSign := fmt.Sprintf("%v", timestamp) + "\n" + secret
+	var data []byte
+	h := hmac.New(sha256.New, []byte(stringToSign))
+	_, err := h.Write(data)
+	if err != nil {
+		return "", err
+	}
+	signature := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	return signature, nil
+}
+
+func (l *Lark) SendMessage(message string) error {
+	timestamp := time.Now().Unix()
+	signature, err := GenSign(l.Key, timestamp)
+	if err != nil {
+		return err
+	}
+	return postJSON(l.Endpoint, &larkMessage{
+		Timestamp: fmt.Sprintf("%d", timestamp),
+		Sign:      signature,
+		MsgType:   "text",
+		Content: larkText{
+			Text: message,
+		},
+	})
+}
@@ -0,0 +1,99 @@
+// Package notify posts operator messages to chat, webhook and email backends
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+// Notifier posts a text message to operators
+type Notifier interface {
+	SendMessage(message string) error
+}
+
+// New returns a notifier posting to every backend configured in cfg
+func New(cfg config.Notify) (Notifier, error) {
+	var multi Multi
+	if cfg.Lark.Endpoint != "" {
+		multi = append(multi, NewLark(cfg.Lark.Endpoint, cfg.Lark.Key))
+	}
+	if cfg.Slack.WebhookURL != "" {
+		multi = append(multi, NewSlack(cfg.Slack.WebhookURL))
+	}
+	if cfg.Telegram.Token != "" {
+		multi = append(multi, NewTelegram(cfg.Telegram.Token, cfg.Telegram.ChatID))
+	}
+	if cfg.Webhook.URL != "" {
+		multi = append(multi, NewWebhook(cfg.Webhook.URL, cfg.Webhook.Token))
+	}
+	if cfg.SMTP.Addr != "" {
+		multi = append(multi, NewSMTP(cfg.SMTP))
+	}
+	switch len(multi) {
+	case 0:
+		return nil, errors.New("no notifier is configured")
+	case 1:
+		return multi[0], nil
+	}
+	return multi, nil
+}
+
+// Multi fans a message out to several notifiers
+type Multi []Notifier
+
+// SendMessage sends message to every notifier, a failing one doesn't stop the
+// others and all errors are returned
+func (m Multi) SendMessage(message string) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.SendMessage(message); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// postJSON posts body as JSON to url, headers are key value pairs
+func postJSON(url string, body interface{}, headers ...string) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("new request error: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	for i := 0; i+1 < len(headers); i += 2 {
+		request.Header.Set(headers[i], headers[i+1])
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("post %s error: %s", redact(url), response.Status)
+	}
+	return nil
+}
+
+// redact drops the path of url, webhook paths often carry secrets
+func redact(url string) string {
+	if i := strings.Index(url, "://"); i >= 0 {
+		if j := strings.Index(url[i+3:], "/"); j >= 0 {
+			return url[:i+3+j]
+		}
+	}
+	return url
+}
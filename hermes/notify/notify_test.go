@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+// recorder is a webhook endpoint keeping the last request
+type recorder struct {
+	path   string
+	header http.Header
+	body   map[string]interface{}
+	status int
+}
+
+func newRecorder(t *testing.T) (*recorder, *httptest.Server) {
+	r := &recorder{status: http.StatusOK}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.path = req.URL.Path
+		r.header = req.Header
+		r.body = nil
+		json.NewDecoder(req.Body).Decode(&r.body)
+		w.WriteHeader(r.status)
+	}))
+	t.Cleanup(server.Close)
+	return r, server
+}
+
+func TestBackends(t *testing.T) {
+	require := require.New(t)
+	r, server := newRecorder(t)
+
+	require.NoError(NewLark(server.URL+"/lark", "secret").SendMessage("hello"))
+	require.Equal("text", r.body["msg_type"])
+	require.Equal(map[string]interface{}{"text": "hello"}, r.body["content"])
+	require.NotEmpty(r.body["sign"])
+
+	require.NoError(NewSlack(server.URL + "/slack").SendMessage("hello"))
+	require.Equal(map[string]interface{}{"text": "hello"}, r.body)
+
+	telegram := NewTelegram("token", "42")
+	telegram.api = server.URL
+	require.NoError(telegram.SendMessage("hello"))
+	require.Equal("/bottoken/sendMessage", r.path)
+	require.Equal("42", r.body["chat_id"])
+
+	require.NoError(NewWebhook(server.URL+"/hook", "token").SendMessage("hello"))
+	require.Equal("Bearer token", r.header.Get("Authorization"))
+	require.Equal("hello", r.body["message"])
+
+	r.status = http.StatusForbidden
+	err := NewSlack(server.URL + "/services/secret").SendMessage("hello")
+	require.Error(err)
+	require.NotContains(err.Error(), "secret")
+}
+
+func TestSMTP(t *testing.T) {
+	require := require.New(t)
+
+	var to []string
+	var msg string
+	sendMail = func(addr string, a smtp.Auth, from string, rcpt []string, data []byte) error {
+		to = rcpt
+		msg = string(data)
+		return nil
+	}
+	defer func() { sendMail = smtp.SendMail }()
+
+	s := NewSMTP(config.SMTP{Addr: "mail:25", From: "hermes@example.com", To: "a@example.com, b@example.com"})
+	require.NoError(s.SendMessage("distribution failed\ndetails"))
+	require.Equal([]string{"a@example.com", "b@example.com"}, to)
+	require.Contains(msg, "Subject: [hermes] distribution failed\r\n")
+	require.Contains(msg, "distribution failed\ndetails")
+}
+
+type failing struct {
+	sent int
+}
+
+func (f *failing) SendMessage(string) error {
+	f.sent++
+	return errors.New("down")
+}
+
+func TestMulti(t *testing.T) {
+	require := require.New(t)
+	r, server := newRecorder(t)
+
+	down := &failing{}
+	n, err := New(config.Notify{Slack: config.Slack{WebhookURL: server.URL}})
+	require.NoError(err)
+	multi := Multi{down, n}
+	require.EqualError(multi.SendMessage("hello"), "down")
+	require.Equal(1, down.sent)
+	require.Equal("hello", r.body["text"])
+
+	_, err = New(config.Notify{})
+	require.Error(err)
+}
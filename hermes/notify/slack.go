@@ -0,0 +1,15 @@
+package notify
+
+// Slack posts to a Slack incoming webhook
+type Slack struct {
+	WebhookURL string
+}
+
+// NewSlack returns a Slack notifier
+func NewSlack(webhookURL string) *Slack {
+	return &Slack{WebhookURL: webhookURL}
+}
+
+func (s *Slack) SendMessage(message string) error {
+	return postJSON(s.WebhookURL, map[string]string{"text": message})
+}
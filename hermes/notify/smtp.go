@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+// sendMail is replaced in tests
+var sendMail = smtp.SendMail
+
+// SMTP sends messages by email
+type SMTP struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// NewSMTP returns an email notifier
+func NewSMTP(cfg config.SMTP) *SMTP {
+	var to []string
+	for _, addr := range strings.Split(cfg.To, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	return &SMTP{
+		Addr:     cfg.Addr,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		From:     cfg.From,
+		To:       to,
+	}
+}
+
+func (s *SMTP) SendMessage(message string) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return fmt.Errorf("invalid smtp address %s: %v", s.Addr, err)
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	subject := message
+	if i := strings.IndexByte(subject, '\n'); i >= 0 {
+		subject = subject[:i]
+	}
+	if len(subject) > 80 {
+		subject = subject[:80]
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: [hermes] %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		s.From, strings.Join(s.To, ", "), subject, message)
+	if err := sendMail(s.Addr, auth, s.From, s.To, []byte(msg)); err != nil {
+		return fmt.Errorf("send mail error: %v", err)
+	}
+	return nil
+}
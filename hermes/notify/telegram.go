@@ -0,0 +1,37 @@
+package notify
+
+import (
+	"errors"
+	"strings"
+)
+
+const telegramAPI = "https://api.telegram.org"
+
+// Telegram posts with a Telegram bot to a chat
+type Telegram struct {
+	Token  string
+	ChatID string
+
+	api string
+}
+
+// NewTelegram returns a Telegram notifier
+func NewTelegram(token, chatID string) *Telegram {
+	return &Telegram{
+		Token:  token,
+		ChatID: chatID,
+		api:    telegramAPI,
+	}
+}
+
+func (t *Telegram) SendMessage(message string) error {
+	err := postJSON(t.api+"/bot"+t.Token+"/sendMessage", map[string]string{
+		"chat_id": t.ChatID,
+		"text":    message,
+	})
+	if err != nil {
+		// the token is part of the URL and may be echoed by the HTTP client
+		return errors.New(strings.ReplaceAll(err.Error(), t.Token, "***"))
+	}
+	return nil
+}
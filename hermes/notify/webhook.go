@@ -0,0 +1,29 @@
+package notify
+
+import "time"
+
+// Webhook posts messages as JSON to a generic endpoint
+type Webhook struct {
+	URL   string
+	Token string
+}
+
+// NewWebhook returns a webhook notifier, token is sent as bearer token if set
+func NewWebhook(url, token string) *Webhook {
+	return &Webhook{
+		URL:   url,
+		Token: token,
+	}
+}
+
+func (w *Webhook) SendMessage(message string) error {
+	body := map[string]string{
+		"source":    "hermes",
+		"message":   message,
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	}
+	if w.Token != "" {
+		return postJSON(w.URL, body, "Authorization", "Bearer "+w.Token)
+	}
+	return postJSON(w.URL, body)
+}
@@ -0,0 +1,192 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/iotexproject/go-pkgs/crypto"
+	"github.com/iotexproject/go-pkgs/hash"
+	"github.com/iotexproject/iotex-address/address"
+
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+// Paths of the signing service API
+const (
+	AccountPath = "/v1/account"
+	SignPath    = "/v1/sign"
+)
+
+// AccountResponse is the account held by a signing service
+type AccountResponse struct {
+	Address   string `json:"address"`
+	PublicKey string `json:"publicKey"`
+}
+
+// SignRequest asks a signing service to sign a 32 byte hash
+type SignRequest struct {
+	Hash string `json:"hash"`
+}
+
+// SignResponse is the signature of a SignRequest
+type SignResponse struct {
+	Signature string `json:"signature"`
+}
+
+// Remote signs with a signing service over HTTPS, authenticated by a client
+// certificate. Only action hashes leave the process.
+type Remote struct {
+	endpoint  string
+	client    *http.Client
+	address   address.Address
+	publicKey crypto.PublicKey
+}
+
+// NewRemote connects to the signing service of cfg and fetches its account,
+// which must be cfg.Address when that is set
+func NewRemote(ctx context.Context, cfg config.Signer) (*Remote, error) {
+	tlsConfig, err := ClientTLS(cfg.CACert, cfg.ClientCert, cfg.ClientKey)
+	if err != nil {
+		return nil, err
+	}
+	r := &Remote{
+		endpoint: strings.TrimSuffix(cfg.Endpoint, "/"),
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}
+	var resp AccountResponse
+	if err := r.call(ctx, http.MethodGet, AccountPath, nil, &resp); err != nil {
+		return nil, err
+	}
+	r.publicKey, err = crypto.HexStringToPublicKey(resp.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key of signer: %v", err)
+	}
+	r.address, err = address.FromBytes(r.publicKey.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if r.address.String() != resp.Address {
+		return nil, fmt.Errorf("signer address %s doesn't match its public key", resp.Address)
+	}
+	if expected := cfg.Address.Address(); expected != nil && expected.String() != r.address.String() {
+		return nil, fmt.Errorf("signer holds %s instead of %s", r.address, expected)
+	}
+	return r, nil
+}
+
+func (r *Remote) Address() address.Address {
+	return r.address
+}
+
+func (r *Remote) PublicKey() crypto.PublicKey {
+	return r.publicKey
+}
+
+// SignHash asks the service to sign h and checks the signature against its public key
+func (r *Remote) SignHash(ctx context.Context, h hash.Hash256) ([]byte, error) {
+	var resp SignResponse
+	if err := r.call(ctx, http.MethodPost, SignPath, &SignRequest{Hash: hex.EncodeToString(h[:])}, &resp); err != nil {
+		return nil, err
+	}
+	sig, err := hex.DecodeString(resp.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature from signer: %v", err)
+	}
+	if !r.publicKey.Verify(h[:], sig) {
+		return nil, errors.New("signature from signer doesn't verify")
+	}
+	return sig, nil
+}
+
+func (r *Remote) call(ctx context.Context, method, path string, request, response interface{}) error {
+	var body io.Reader
+	if request != nil {
+		data, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, r.endpoint+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("signer %s error: %v", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("signer %s status %d: %s", path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return fmt.Errorf("decode signer %s response error: %v", path, err)
+	}
+	return nil
+}
+
+// ClientTLS returns the TLS config of a signer client trusting the CA in
+// caFile and authenticating with the certificate in certFile and keyFile
+func ClientTLS(caFile, certFile, keyFile string) (*tls.Config, error) {
+	pool, err := loadPool(caFile)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load client certificate error: %v", err)
+	}
+	return &tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// ServerTLS returns the TLS config of a signing service with the certificate
+// in certFile and keyFile, requiring client certificates issued by the CA in
+// clientCAFile
+func ServerTLS(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	pool, err := loadPool(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load server certificate error: %v", err)
+	}
+	return &tls.Config{
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+func loadPool(caFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("read CA certificate error: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificate found in %s", caFile)
+	}
+	return pool, nil
+}
@@ -0,0 +1,78 @@
+package signer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/iotexproject/go-pkgs/hash"
+)
+
+// Server is a reference signing service holding one account, for local use
+// and tests. It signs any hash asked by a client holding a certificate
+// trusted by its TLS config.
+type Server struct {
+	signer Signer
+	mux    *http.ServeMux
+}
+
+// NewServer returns a signing service for s
+func NewServer(s Signer) *Server {
+	srv := &Server{signer: s, mux: http.NewServeMux()}
+	srv.mux.HandleFunc(AccountPath, srv.account)
+	srv.mux.HandleFunc(SignPath, srv.sign)
+	return srv
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) account(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, &AccountResponse{
+		Address:   s.signer.Address().String(),
+		PublicKey: s.signer.PublicKey().HexString(),
+	})
+}
+
+func (s *Server) sign(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req SignRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	data, err := hex.DecodeString(req.Hash)
+	if err != nil || len(data) != len(hash.ZeroHash256) {
+		http.Error(w, "hash must be 32 hex encoded bytes", http.StatusBadRequest)
+		return
+	}
+	sig, err := s.signer.SignHash(r.Context(), hash.BytesToHash256(data))
+	if err != nil {
+		log.Printf("sign %s error: %v\n", req.Hash, err)
+		http.Error(w, "sign error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("signed %s for %s\n", req.Hash, clientName(r))
+	writeJSON(w, &SignResponse{Signature: hex.EncodeToString(sig)})
+}
+
+func clientName(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return r.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	return r.RemoteAddr
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
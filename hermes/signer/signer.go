@@ -0,0 +1,111 @@
+package signer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/iotexproject/go-pkgs/crypto"
+	"github.com/iotexproject/go-pkgs/hash"
+	"github.com/iotexproject/iotex-address/address"
+	"github.com/iotexproject/iotex-antenna-go/v2/account"
+
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+// signTimeout bounds a signing requested through the account.Account interface,
+// which carries no context
+const signTimeout = 30 * time.Second
+
+// Signer signs action hashes for one account
+type Signer interface {
+	Address() address.Address
+	PublicKey() crypto.PublicKey
+	// SignHash signs the hash of a serialized action core
+	SignHash(ctx context.Context, h hash.Hash256) ([]byte, error)
+}
+
+// Local signs with a keystore decrypted in process
+type Local struct {
+	acc account.Account
+}
+
+// NewLocal returns a signer of the decrypted account acc
+func NewLocal(acc account.Account) *Local {
+	return &Local{acc: acc}
+}
+
+func (l *Local) Address() address.Address {
+	return l.acc.Address()
+}
+
+func (l *Local) PublicKey() crypto.PublicKey {
+	return l.acc.PublicKey()
+}
+
+func (l *Local) SignHash(ctx context.Context, h hash.Hash256) ([]byte, error) {
+	return l.acc.PrivateKey().Sign(h[:])
+}
+
+// Account adapts s to the account.Account the chain client sends actions
+// with. The private key of a remote signer is never in process, so
+// PrivateKey returns nil and Zero does nothing.
+func Account(s Signer) account.Account {
+	if l, ok := s.(*Local); ok {
+		return l.acc
+	}
+	return &signerAccount{s: s}
+}
+
+type signerAccount struct {
+	s Signer
+}
+
+func (a *signerAccount) Address() address.Address {
+	return a.s.Address()
+}
+
+func (a *signerAccount) PrivateKey() crypto.PrivateKey {
+	return nil
+}
+
+func (a *signerAccount) PublicKey() crypto.PublicKey {
+	return a.s.PublicKey()
+}
+
+func (a *signerAccount) Sign(data []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), signTimeout)
+	defer cancel()
+	return a.s.SignHash(ctx, hash.Hash256b(data))
+}
+
+func (a *signerAccount) Verify(data []byte, sig []byte) bool {
+	h := hash.Hash256b(data)
+	return a.s.PublicKey().Verify(h[:], sig)
+}
+
+func (a *signerAccount) Zero() {}
+
+func (a *signerAccount) SignMessage(data []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), signTimeout)
+	defer cancel()
+	return a.s.SignHash(ctx, account.HashMessage(data))
+}
+
+// Load returns the account actions are sent from. It is signed by the remote
+// signer of cfg when one is configured, and by the keystore account returned
+// by local otherwise.
+func Load(ctx context.Context, cfg config.Signer, local func() (account.Account, error)) (account.Account, error) {
+	if cfg.Endpoint == "" {
+		if local == nil {
+			return nil, errors.New("no remote signer is configured")
+		}
+		return local()
+	}
+	remote, err := NewRemote(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("connect remote signer error: %v", err)
+	}
+	return Account(remote), nil
+}
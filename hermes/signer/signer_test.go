@@ -0,0 +1,151 @@
+package signer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iotexproject/go-pkgs/hash"
+	"github.com/iotexproject/iotex-antenna-go/v2/account"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ququzone/hermes-patch/hermes/config"
+)
+
+const testPrivateKey = "a000000000000000000000000000000000000000000000000000000000000000"
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+}
+
+// newTestCA writes a self signed CA to dir as name.pem
+func newTestCA(t *testing.T, dir, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	writePEM(t, filepath.Join(dir, name+".pem"), "CERTIFICATE", der)
+	return &testCA{cert: cert, key: key, dir: dir}
+}
+
+// issue writes a certificate signed by ca to name.pem and its key to name-key.pem
+func (ca *testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certFile, keyFile := filepath.Join(ca.dir, name+".pem"), filepath.Join(ca.dir, name+"-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, path, kind string, der []byte) {
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0600))
+}
+
+func TestRemoteSigner(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	acc, err := account.HexStringToAccount(testPrivateKey)
+	require.NoError(err)
+	serverCA := newTestCA(t, dir, "server-ca")
+	serverCert, serverKey := serverCA.issue(t, "signer", x509.ExtKeyUsageServerAuth)
+	clientCA := newTestCA(t, dir, "client-ca")
+	clientCert, clientKey := clientCA.issue(t, "hermes", x509.ExtKeyUsageClientAuth)
+	otherCA := newTestCA(t, dir, "other-ca")
+	otherCert, otherKey := otherCA.issue(t, "intruder", x509.ExtKeyUsageClientAuth)
+
+	tlsConfig, err := ServerTLS(serverCert, serverKey, filepath.Join(dir, "client-ca.pem"))
+	require.NoError(err)
+	server := httptest.NewUnstartedServer(NewServer(NewLocal(acc)))
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+
+	cfg := config.Signer{
+		Endpoint:   server.URL,
+		CACert:     filepath.Join(dir, "server-ca.pem"),
+		ClientCert: clientCert,
+		ClientKey:  clientKey,
+	}
+	require.NoError(cfg.Address.Set(acc.Address().String()))
+	remote, err := NewRemote(ctx, cfg)
+	require.NoError(err)
+	require.Equal(acc.Address().String(), remote.Address().String())
+
+	h := hash.Hash256b([]byte("action"))
+	sig, err := remote.SignHash(ctx, h)
+	require.NoError(err)
+	require.True(acc.PublicKey().Verify(h[:], sig))
+
+	// the adapted account signs actions like the local one
+	remoteAcc, err := Load(ctx, cfg, nil)
+	require.NoError(err)
+	require.Nil(remoteAcc.PrivateKey())
+	remoteSig, err := remoteAcc.Sign([]byte("core"))
+	require.NoError(err)
+	require.True(acc.Verify([]byte("core"), remoteSig))
+	require.True(remoteAcc.Verify([]byte("core"), remoteSig))
+
+	t.Run("expected address", func(t *testing.T) {
+		other, err := account.NewAccount()
+		require.NoError(err)
+		cfg := cfg
+		require.NoError(cfg.Address.Set(other.Address().String()))
+		_, err = NewRemote(ctx, cfg)
+		require.ErrorContains(err, "instead of "+other.Address().String())
+	})
+
+	t.Run("client certificate of another CA", func(t *testing.T) {
+		cfg := cfg
+		cfg.ClientCert, cfg.ClientKey = otherCert, otherKey
+		_, err := NewRemote(ctx, cfg)
+		require.Error(err)
+	})
+
+	t.Run("local keystore without endpoint", func(t *testing.T) {
+		local, err := Load(ctx, config.Signer{}, func() (account.Account, error) { return acc, nil })
+		require.NoError(err)
+		require.Equal(acc, local)
+		_, err = Load(ctx, config.Signer{}, nil)
+		require.Error(err)
+	})
+}
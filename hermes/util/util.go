@@ -7,11 +7,12 @@
 package util
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	ethCrypto "github.com/ethereum/go-ethereum/crypto"
@@ -19,15 +20,6 @@ import (
 	"github.com/iotexproject/iotex-antenna-go/v2/account"
 )
 
-// MustFetchNonEmptyParam must fetch a nonempty environment variable
-func MustFetchNonEmptyParam(key string) string {
-	str := os.Getenv(key)
-	if len(str) == 0 {
-		log.Fatalf("%s is not defined in env\n", key)
-	}
-	return str
-}
-
 // GetVaultAccount returns the vault account given the password
 func GetVaultAccount(pwd string) (account.Account, error) {
 	// load the keystore file
@@ -88,3 +80,15 @@ func ReadAccount(password string) (account.Account, error) {
 	}
 	return account.PrivateKeyToAccount(pk)
 }
+
+// Sleep waits for d, it returns the error of ctx if ctx is done first
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
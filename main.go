@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/urfave/cli/v2"
 
@@ -21,10 +24,21 @@ func main() {
 		HelpName:  "hermes-patc",
 		Usage:     "IoTeX hermes patch",
 		UsageText: "hermes-patch <SUBCOMMAND>",
-		Commands:  commands.Commonds(),
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "config",
+				Usage:   "config file path, env variables override its values",
+				EnvVars: []string{"HERMES_CONFIG"},
+			},
+		},
+		Commands: commands.Commonds(),
 	}
 
-	if err := app.Run(os.Args); err != nil {
+	// daemons stop taking new work on SIGINT or SIGTERM and exit once the work
+	// in progress is settled
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := app.RunContext(ctx, os.Args); err != nil {
 		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err.Error())
 	}
 }